@@ -20,6 +20,8 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("REGISTRY_CONFIG_PATH environment variable is not set")
 	}
 
+	viper.SetDefault("db.auto-migrate", true)
+
 	viper.SetConfigFile(configPath)
 	err = viper.ReadInConfig()
 	if err != nil {
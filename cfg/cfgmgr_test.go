@@ -2,6 +2,7 @@ package cfg
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -14,12 +15,14 @@ func TestLoadConfig(t *testing.T) {
 	assert.NoError(t, err, "LoadConfig should not return an error")
 
 	// Validate the loaded configuration
+	assert.Equal(t, "postgres", config.DB.Driver, "DB driver should be 'postgres'")
 	assert.Equal(t, "localhost", config.DB.Host, "DB host should be 'localhost'")
 	assert.Equal(t, 5432, config.DB.Port, "DB port should be 5432")
 	assert.Equal(t, "dba", config.DB.User, "DB user should be 'dba'")
 	assert.Equal(t, "start123", config.DB.Password, "DB password should be 'start123'")
 	assert.Equal(t, "svcregistry", config.DB.DBName, "DB name should be 'svcregistry'")
 	assert.Equal(t, "disable", config.DB.SSLMode, "DB SSL mode should be 'disable'")
+	assert.True(t, config.DB.AutoMigrate, "DB auto-migrate should default to true")
 
 	assert.Equal(t, "localhost", config.Server.Host, "Server host should be 'localhost'")
 	assert.Equal(t, 8080, config.Server.Port, "Server port should be 8080")
@@ -27,4 +30,9 @@ func TestLoadConfig(t *testing.T) {
 	assert.Equal(t, "cert.pem", config.Server.SSLCert, "Server SSL cert should be 'cert.pem'")
 	assert.Equal(t, "key.pem", config.Server.SSLKey, "Server SSL key should be 'key.pem'")
 
+	assert.True(t, config.Health.Enabled, "Health checks should be enabled")
+	assert.Equal(t, 30*time.Second, config.Health.Interval, "Health interval should be 30s")
+	assert.Equal(t, 5*time.Second, config.Health.Timeout, "Health timeout should be 5s")
+	assert.Equal(t, 64, config.Health.Concurrency, "Health concurrency should be 64")
+
 }
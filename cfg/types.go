@@ -1,14 +1,19 @@
 package cfg
 
+import "time"
+
 // Config struct to hold database connection info
 type Config struct {
 	DB struct {
-		Host     string `mapstructure:"host"`
-		Port     int    `mapstructure:"port"`
-		User     string `mapstructure:"user"`
-		Password string `mapstructure:"password"`
-		DBName   string `mapstructure:"dbname"`
-		SSLMode  string `mapstructure:"sslmode"`
+		Driver      string `mapstructure:"driver"`
+		Host        string `mapstructure:"host"`
+		Port        int    `mapstructure:"port"`
+		User        string `mapstructure:"user"`
+		Password    string `mapstructure:"password"`
+		DBName      string `mapstructure:"dbname"`
+		SSLMode     string `mapstructure:"sslmode"`
+		Path        string `mapstructure:"path"`         // SQLite database file
+		AutoMigrate bool   `mapstructure:"auto-migrate"` // apply pending migrations on connect
 	} `mapstructure:"db"`
 	Server struct {
 		Host       string `mapstructure:"host"`
@@ -16,5 +21,22 @@ type Config struct {
 		SSLEnabled string `mapstructure:"ssl-enabled"`
 		SSLCert    string `mapstructure:"ssl-cert"`
 		SSLKey     string `mapstructure:"ssl-key"`
+
+		MaxBodyBytes int64 `mapstructure:"max-body-bytes"` // request body limit; 0 uses the default
 	} `mapstructure:"server"`
+	Health struct {
+		Enabled            bool          `mapstructure:"enabled"`
+		Interval           time.Duration `mapstructure:"interval"`
+		Timeout            time.Duration `mapstructure:"timeout"`
+		HealthyThreshold   int           `mapstructure:"healthy-threshold"`
+		UnhealthyThreshold int           `mapstructure:"unhealthy-threshold"`
+		Concurrency        int           `mapstructure:"concurrency"` // maximum probes in flight
+	} `mapstructure:"health"`
+	Lease struct {
+		DefaultTTL   time.Duration `mapstructure:"default-ttl"`   // lease for instances registered without lease_ttl; 0 disables
+		ReapInterval time.Duration `mapstructure:"reap-interval"` // how often expired leases are reaped
+	} `mapstructure:"lease"`
+	Watch struct {
+		Buffer int `mapstructure:"buffer"` // changes retained for watchers that reconnect
+	} `mapstructure:"watch"`
 }
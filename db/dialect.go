@@ -0,0 +1,58 @@
+package db
+
+import (
+	"DirectoryService/labels"
+	"fmt"
+	"strings"
+)
+
+// sqlDialect holds what differs between the SQL of the Postgres and SQLite
+// backends in dynamically built queries.
+type sqlDialect struct {
+	// placeholder returns the placeholder of the nth argument.
+	placeholder func(n int) string
+
+	// label returns the value of the label whose key is the argument key in
+	// the JSON labels column, or NULL when the label is missing.
+	label func(key string) string
+}
+
+var postgresDialect = sqlDialect{
+	placeholder: func(n int) string { return fmt.Sprintf("$%d", n) },
+	label:       func(key string) string { return "labels->>" + key + "::text" },
+}
+
+var sqliteDialect = sqlDialect{
+	placeholder: func(n int) string { return fmt.Sprintf("?%d", n) },
+	label: func(key string) string {
+		// label keys have no double quotes to escape in the path
+		return `json_extract(labels, '$."' || ` + key + ` || '"')`
+	},
+}
+
+// labelConditions returns the SQL conditions that select the rows whose
+// labels satisfy the selector. arg adds an argument and returns its
+// placeholder.
+func labelConditions(d sqlDialect, selector labels.Selector, arg func(v any) string) []string {
+	conditions := make([]string, 0, len(selector))
+	for _, r := range selector {
+		value := d.label(arg(r.Key))
+		placeholders := make([]string, len(r.Values))
+		for i, v := range r.Values {
+			placeholders[i] = arg(v)
+		}
+		values := strings.Join(placeholders, ", ")
+
+		switch r.Operator {
+		case labels.Exists:
+			conditions = append(conditions, value+" IS NOT NULL")
+		case labels.DoesNotExist:
+			conditions = append(conditions, value+" IS NULL")
+		case labels.Equals, labels.In:
+			conditions = append(conditions, value+" IN ("+values+")")
+		case labels.NotEquals, labels.NotIn:
+			conditions = append(conditions, "("+value+" IS NULL OR "+value+" NOT IN ("+values+"))")
+		}
+	}
+	return conditions
+}
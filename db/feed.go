@@ -0,0 +1,144 @@
+package db
+
+import (
+	"DirectoryService/models"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultFeedCapacity is the number of events a ChangeFeed retains when no
+// capacity is configured.
+const DefaultFeedCapacity = 1024
+
+// ChangeFeed retains the most recent registry changes, in the order of their
+// registry index, so that watchers can catch up after a reconnect. A feed
+// holds the changes made through this registry node only.
+type ChangeFeed struct {
+	mu       sync.Mutex
+	capacity int
+	index    uint64
+	events   []models.Event
+	changed  chan struct{} // closed and replaced on every publish
+}
+
+// NewChangeFeed creates a ChangeFeed that retains capacity events. A
+// non-positive capacity uses DefaultFeedCapacity.
+func NewChangeFeed(capacity int) *ChangeFeed {
+	if capacity <= 0 {
+		capacity = DefaultFeedCapacity
+	}
+
+	return &ChangeFeed{
+		capacity: capacity,
+		changed:  make(chan struct{}),
+	}
+}
+
+// Index returns the index of the latest change, or 0 before the first one.
+func (f *ChangeFeed) Index() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.index
+}
+
+// SkipTo moves the feed to index, the persisted registry index, without
+// publishing events: the changes up to index were made before the registry
+// started or through another registry node, and watchers behind it must
+// start over. An index behind the feed means the registry index was reset,
+// and the retained events are dropped.
+func (f *ChangeFeed) SkipTo(index uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if index == f.index {
+		return
+	}
+	if index < f.index {
+		f.events = nil
+	}
+	f.index = index
+
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// Publish retains events, in index order, and wakes every waiter. Events
+// keep the index the registry assigned them; those without one are numbered
+// after the latest change. An event numbered behind the feed means the
+// registry index was reset, and the events retained before it are dropped.
+func (f *ChangeFeed) Publish(events ...models.Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, event := range events {
+		if event.Index == 0 {
+			event.Index = f.index + 1
+		}
+		if event.Index <= f.index {
+			f.events = nil
+		}
+		f.index = event.Index
+		if event.Time.IsZero() {
+			event.Time = now
+		}
+		f.events = append(f.events, event)
+	}
+	if len(f.events) > f.capacity {
+		f.events = append([]models.Event(nil), f.events[len(f.events)-f.capacity:]...)
+	}
+
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// Since returns the retained events after index, oldest first. It reports
+// false when the feed does not hold every change after index: when some have
+// been discarded, were made through another registry node, or when index is
+// ahead of the feed (for instance after the registry's database was
+// restored). The caller has then missed changes and must start over from a
+// full listing.
+func (f *ChangeFeed) Since(index uint64) ([]models.Event, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if index > f.index {
+		return nil, false
+	}
+	if index == f.index {
+		return nil, true
+	}
+
+	i := sort.Search(len(f.events), func(i int) bool { return f.events[i].Index > index })
+	if uint64(len(f.events)-i) != f.index-index {
+		return nil, false
+	}
+	return append([]models.Event(nil), f.events[i:]...), true
+}
+
+// Wait blocks until the feed moves past index or ctx is done, and returns
+// the current index.
+func (f *ChangeFeed) Wait(ctx context.Context, index uint64) uint64 {
+	for {
+		f.mu.Lock()
+		current, changed := f.index, f.changed
+		f.mu.Unlock()
+
+		if current != index {
+			return current
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return current
+		}
+	}
+}
@@ -0,0 +1,490 @@
+package db
+
+import (
+	"DirectoryService/models"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemStore is an in-memory, thread-safe implementation of Store. It is meant
+// for tests and single-node development where no database is available.
+type MemStore struct {
+	mu        *sync.RWMutex
+	inTx      bool // set when the store is scoped to a transaction holding mu
+	services  map[uuid.UUID]models.Service
+	instances map[uuid.UUID]models.ServiceInstance
+	history   []models.ServiceInstanceHistory
+	reviews   map[reviewKey]models.Review
+	index     uint64 // the registry index
+}
+
+// reviewKey identifies the one review a reviewer may have of a service.
+type reviewKey struct {
+	serviceID uuid.UUID
+	reviewer  string
+}
+
+// MemStore must satisfy Store.
+var _ Store = (*MemStore)(nil)
+
+// NewMemStore creates an empty MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{
+		mu:        &sync.RWMutex{},
+		services:  make(map[uuid.UUID]models.Service),
+		instances: make(map[uuid.UUID]models.ServiceInstance),
+		reviews:   make(map[reviewKey]models.Review),
+	}
+}
+
+// lock acquires the write lock unless the store is scoped to a transaction,
+// which already holds it. It returns the matching unlock function.
+func (m *MemStore) lock() func() {
+	if m.inTx {
+		return func() {}
+	}
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+// rlock is the read-lock counterpart of lock.
+func (m *MemStore) rlock() func() {
+	if m.inTx {
+		return func() {}
+	}
+	m.mu.RLock()
+	return m.mu.RUnlock
+}
+
+// WithTx runs fn while holding the write lock. Changes fn makes are discarded
+// when it returns an error. Calls nested inside fn join the outer transaction.
+func (m *MemStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if m.inTx {
+		return fn(m)
+	}
+
+	defer m.lock()()
+
+	services := make(map[uuid.UUID]models.Service, len(m.services))
+	for id, service := range m.services {
+		services[id] = service
+	}
+	instances := make(map[uuid.UUID]models.ServiceInstance, len(m.instances))
+	for id, instance := range m.instances {
+		instances[id] = instance
+	}
+	reviews := make(map[reviewKey]models.Review, len(m.reviews))
+	for key, review := range m.reviews {
+		reviews[key] = review
+	}
+
+	tx := &MemStore{
+		mu:        m.mu,
+		inTx:      true,
+		services:  services,
+		instances: instances,
+		history:   append([]models.ServiceInstanceHistory(nil), m.history...),
+		reviews:   reviews,
+		index:     m.index,
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	m.services, m.instances, m.history, m.reviews = tx.services, tx.instances, tx.history, tx.reviews
+	m.index = tx.index
+
+	return nil
+}
+
+// Close is a no-op for the in-memory store.
+func (m *MemStore) Close() {}
+
+// RegisterService inserts a new service under a new ServiceID and returns
+// the inserted service.
+func (m *MemStore) RegisterService(ctx context.Context, service models.Service) (
+	*models.Service, error,
+) {
+	service.ServiceID = uuid.New()
+	service = withCategory(service)
+	service.Tags = normalizeTags(service.Tags)
+	service.ReviewCount = 0
+	service.CreatedAt = time.Now().UTC()
+	service.UpdatedAt = service.CreatedAt
+
+	defer m.lock()()
+
+	if _, ok := m.services[service.ServiceID]; ok {
+		return nil, fmt.Errorf(
+			"failed to insert service: %w: service %s already exists", ErrConflict,
+			service.ServiceID,
+		)
+	}
+	m.services[service.ServiceID] = service
+
+	return &service, nil
+}
+
+// UpdateService updates the service details and returns the updated service.
+func (m *MemStore) UpdateService(ctx context.Context, service models.Service) (
+	*models.Service, error,
+) {
+	service = withCategory(service)
+
+	defer m.lock()()
+
+	existing, ok := m.services[service.ServiceID]
+	if !ok {
+		return nil, fmt.Errorf("failed to update service: %w", ErrNotFound)
+	}
+
+	existing.Name = service.Name
+	existing.Description = service.Description
+	existing.OwnerInfo = service.OwnerInfo
+	existing.IndustryCategory = service.IndustryCategory
+	existing.IndustryCategoryName = service.IndustryCategoryName
+	existing.Tags = normalizeTags(service.Tags)
+	existing.Labels = service.Labels
+	existing.HealthCheck = service.HealthCheck
+	existing.UpdatedAt = time.Now().UTC()
+	m.services[existing.ServiceID] = existing
+
+	return &existing, nil
+}
+
+// GetService retrieves a service by ID.
+func (m *MemStore) GetService(ctx context.Context, serviceID uuid.UUID) (*models.Service, error) {
+	defer m.rlock()()
+
+	service, ok := m.services[serviceID]
+	if !ok {
+		return nil, fmt.Errorf("failed to retrieve service: %w", ErrNotFound)
+	}
+
+	return &service, nil
+}
+
+// GetServiceForUpdate retrieves a service by ID. WithTx already holds the write
+// lock until the transaction ends.
+func (m *MemStore) GetServiceForUpdate(ctx context.Context, serviceID uuid.UUID) (
+	*models.Service, error,
+) {
+	return m.GetService(ctx, serviceID)
+}
+
+// ListServices retrieves the services selected by query.
+func (m *MemStore) ListServices(ctx context.Context, query ServiceQuery) ([]models.Service, error) {
+	if err := query.check(); err != nil {
+		return nil, fmt.Errorf("failed to query services: %w", err)
+	}
+
+	defer m.rlock()()
+
+	services := make([]models.Service, 0, len(m.services))
+	for _, service := range m.services {
+		if !query.Matches(service) {
+			continue
+		}
+		if query.After != nil && query.compare(service, query.After.cursorService()) <= 0 {
+			continue
+		}
+		services = append(services, service)
+	}
+	slices.SortFunc(services, query.compare)
+	if query.Limit > 0 && len(services) > query.Limit {
+		services = services[:query.Limit]
+	}
+
+	return services, nil
+}
+
+// SearchServices ranks the services matching text by relevance.
+func (m *MemStore) SearchServices(ctx context.Context, text string, limit int) (
+	[]models.SearchResult, error,
+) {
+	terms, err := parseSearch(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search services: %w", err)
+	}
+
+	defer m.rlock()()
+
+	services := make([]models.Service, 0, len(m.services))
+	for _, service := range m.services {
+		services = append(services, service)
+	}
+
+	return rankServices(services, terms, limit), nil
+}
+
+// DeleteService deletes a service by ID. Like the foreign key on
+// service_instances, it refuses to delete a service that still has instances.
+func (m *MemStore) DeleteService(ctx context.Context, serviceID uuid.UUID) error {
+	defer m.lock()()
+
+	if _, ok := m.services[serviceID]; !ok {
+		return fmt.Errorf("failed to delete service: %w", ErrNotFound)
+	}
+
+	for _, instance := range m.instances {
+		if instance.ServiceID == serviceID {
+			return fmt.Errorf(
+				"failed to delete service: %w: service still has instances", ErrConflict,
+			)
+		}
+	}
+
+	delete(m.services, serviceID)
+	for key := range m.reviews {
+		if key.serviceID == serviceID {
+			delete(m.reviews, key)
+		}
+	}
+
+	return nil
+}
+
+// PutReview stores the review and recomputes the service's client rating.
+func (m *MemStore) PutReview(ctx context.Context, review models.Review) (*models.Review, error) {
+	defer m.lock()()
+
+	service, ok := m.services[review.ServiceID]
+	if !ok {
+		return nil, fmt.Errorf("failed to store review: %w", ErrNotFound)
+	}
+
+	key := reviewKey{review.ServiceID, review.Reviewer}
+	now := time.Now().UTC()
+	if existing, ok := m.reviews[key]; ok {
+		review.ReviewID, review.CreatedAt = existing.ReviewID, existing.CreatedAt
+	} else {
+		review.ReviewID, review.CreatedAt = uuid.New(), now
+	}
+	review.UpdatedAt = now
+	m.reviews[key] = review
+
+	var stars, count int64
+	for key, r := range m.reviews {
+		if key.serviceID == review.ServiceID {
+			stars += int64(r.Rating)
+			count++
+		}
+	}
+	service.ClientRating, service.ReviewCount = clientRating(stars, count), count
+	m.services[service.ServiceID] = service
+
+	return &review, nil
+}
+
+// ListReviews retrieves the reviews of a service, most recently updated first.
+func (m *MemStore) ListReviews(ctx context.Context, serviceID uuid.UUID) ([]models.Review, error) {
+	defer m.rlock()()
+
+	reviews := make([]models.Review, 0)
+	for key, review := range m.reviews {
+		if key.serviceID == serviceID {
+			reviews = append(reviews, review)
+		}
+	}
+	slices.SortFunc(reviews, compareReviews)
+
+	return reviews, nil
+}
+
+// CreateServiceInstance creates a new ServiceInstance for an existing service.
+func (m *MemStore) CreateServiceInstance(
+	ctx context.Context, instance models.ServiceInstance,
+) (*models.ServiceInstance, error) {
+	if err := validateInstance(instance); err != nil {
+		return nil, fmt.Errorf("failed to create service instance: %w", err)
+	}
+
+	instance = withEndpoints(instance)
+	instance.InstanceID = uuid.New()
+	instance.HealthStatus = models.HealthStarting
+	instance.CreatedAt = time.Now().UTC()
+	instance.LastChecked = time.Now().UTC()
+	instance.LeaseExpiresAt = leaseExpiry(instance.LeaseTTL, instance.CreatedAt)
+
+	defer m.lock()()
+
+	if _, ok := m.services[instance.ServiceID]; !ok {
+		return nil, fmt.Errorf(
+			"failed to create service instance: %w: service %s does not exist", ErrReference,
+			instance.ServiceID,
+		)
+	}
+
+	m.instances[instance.InstanceID] = instance
+
+	return &instance, nil
+}
+
+// GetServiceInstance retrieves a service instance by ID.
+func (m *MemStore) GetServiceInstance(
+	ctx context.Context, instanceID uuid.UUID,
+) (*models.ServiceInstance, error) {
+	defer m.rlock()()
+
+	instance, ok := m.instances[instanceID]
+	if !ok {
+		return nil, fmt.Errorf("failed to retrieve service instance: %w", ErrNotFound)
+	}
+
+	return &instance, nil
+}
+
+// ListServiceInstances retrieves the instances matching filter, oldest first.
+func (m *MemStore) ListServiceInstances(
+	ctx context.Context, filter InstanceFilter,
+) ([]models.ServiceInstance, error) {
+	defer m.rlock()()
+
+	instances := make([]models.ServiceInstance, 0)
+	for _, instance := range m.instances {
+		if filter.Matches(instance) {
+			instances = append(instances, instance)
+		}
+	}
+	sort.Slice(
+		instances, func(i, j int) bool {
+			if !instances[i].CreatedAt.Equal(instances[j].CreatedAt) {
+				return instances[i].CreatedAt.Before(instances[j].CreatedAt)
+			}
+			return instances[i].InstanceID.String() < instances[j].InstanceID.String()
+		},
+	)
+
+	return instances, nil
+}
+
+// UpdateServiceInstanceHealth records the outcome of a health check.
+func (m *MemStore) UpdateServiceInstanceHealth(
+	ctx context.Context, instanceID uuid.UUID, status models.HealthStatus, checkedAt time.Time,
+) (*models.ServiceInstance, error) {
+	defer m.lock()()
+
+	instance, ok := m.instances[instanceID]
+	if !ok {
+		return nil, fmt.Errorf("failed to update service instance health: %w", ErrNotFound)
+	}
+
+	instance.HealthStatus = status
+	instance.LastChecked = checkedAt.UTC()
+	m.instances[instanceID] = instance
+
+	return &instance, nil
+}
+
+// RemoveServiceInstance copies the instance to the history and then deletes it.
+func (m *MemStore) RemoveServiceInstance(ctx context.Context, instanceID uuid.UUID) error {
+	defer m.lock()()
+
+	return m.removeServiceInstance(instanceID, ReasonDeregistered)
+}
+
+// RenewLease extends the instance's lease to now plus its TTL.
+func (m *MemStore) RenewLease(
+	ctx context.Context, instanceID uuid.UUID, ttl time.Duration, now time.Time,
+) (*models.ServiceInstance, error) {
+	if ttl < 0 {
+		return nil, fmt.Errorf("failed to renew lease: %w: ttl must not be negative", ErrInvalid)
+	}
+
+	defer m.lock()()
+
+	instance, ok := m.instances[instanceID]
+	if !ok {
+		return nil, fmt.Errorf("failed to renew lease: %w", ErrNotFound)
+	}
+	if ttl > 0 {
+		instance.LeaseTTL = models.Duration(ttl)
+	}
+	if instance.LeaseTTL <= 0 {
+		return nil, fmt.Errorf("failed to renew lease: %w: instance has no lease ttl", ErrInvalid)
+	}
+
+	instance.LeaseExpiresAt = leaseExpiry(instance.LeaseTTL, now.UTC())
+	m.instances[instanceID] = instance
+
+	return &instance, nil
+}
+
+// ExpireLeases archives every instance whose lease expired before now.
+func (m *MemStore) ExpireLeases(ctx context.Context, now time.Time) (
+	[]models.ServiceInstance, error,
+) {
+	defer m.lock()()
+
+	expired := make([]models.ServiceInstance, 0)
+	for _, instance := range m.instances {
+		if instance.LeaseExpiresAt != nil && instance.LeaseExpiresAt.Before(now) {
+			expired = append(expired, instance)
+		}
+	}
+	sort.Slice(
+		expired, func(i, j int) bool {
+			return expired[i].LeaseExpiresAt.Before(*expired[j].LeaseExpiresAt)
+		},
+	)
+
+	for _, instance := range expired {
+		if err := m.removeServiceInstance(instance.InstanceID, ReasonLeaseExpired); err != nil {
+			return nil, err
+		}
+	}
+
+	return expired, nil
+}
+
+// Index returns the registry index.
+func (m *MemStore) Index(ctx context.Context) (uint64, error) {
+	defer m.rlock()()
+
+	return m.index, nil
+}
+
+// AdvanceIndex adds n to the registry index and returns the new value.
+func (m *MemStore) AdvanceIndex(ctx context.Context, n uint64) (uint64, error) {
+	defer m.lock()()
+
+	m.index += n
+	return m.index, nil
+}
+
+// removeServiceInstance archives and deletes the instance, recording reason in the history
+// metrics; the caller holds the lock.
+func (m *MemStore) removeServiceInstance(instanceID uuid.UUID, reason string) error {
+	instance, ok := m.instances[instanceID]
+	if !ok {
+		return fmt.Errorf("failed to get service instance: %w", ErrNotFound)
+	}
+
+	m.history = append(
+		m.history, models.ServiceInstanceHistory{
+			HistoryID:  uuid.New(),
+			InstanceID: instance.InstanceID,
+			ServiceID:  instance.ServiceID,
+			Version:    instance.Version,
+			Url:        instance.Url,
+			Metrics:    archiveMetrics(instance, reason),
+			StartedAt:  instance.CreatedAt,
+			StoppedAt:  time.Now().UTC(),
+		},
+	)
+	delete(m.instances, instanceID)
+
+	return nil
+}
+
+// History returns the archived service instances in the order they were removed.
+func (m *MemStore) History() []models.ServiceInstanceHistory {
+	defer m.rlock()()
+
+	return append([]models.ServiceInstanceHistory(nil), m.history...)
+}
@@ -0,0 +1,238 @@
+package db
+
+import (
+	"DirectoryService/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemStoreServiceLifecycle(t *testing.T) {
+	ms := NewMemStore()
+	ctx := context.Background()
+
+	service := models.Service{
+		Name:             "Test Service",
+		Description:      "A test service",
+		OwnerInfo:        "Test Owner",
+		IndustryCategory: "Test Category",
+		ClientRating:     4.5,
+		TransactionCount: 10,
+	}
+
+	inserted, err := ms.RegisterService(ctx, service)
+	assert.NoError(t, err, "RegisterService should not return an error")
+	assert.NotEqual(t, uuid.Nil, inserted.ServiceID, "ServiceID should be assigned")
+
+	inserted.Name = "Updated Service"
+	inserted.TransactionCount = 99 // the registry keeps the statistics, so this is ignored
+	updated, err := ms.UpdateService(ctx, *inserted)
+	assert.NoError(t, err, "UpdateService should not return an error")
+	assert.Equal(t, inserted.ServiceID, updated.ServiceID, "ServiceID should match")
+	assert.Equal(t, "Updated Service", updated.Name, "Name should match")
+	assert.Equal(t, int64(10), updated.TransactionCount, "TransactionCount should be unchanged")
+
+	services, err := ms.ListServices(ctx, ServiceQuery{})
+	assert.NoError(t, err, "ListServices should not return an error")
+	assert.Len(t, services, 1, "ListServices should return the registered service")
+
+	err = ms.DeleteService(ctx, inserted.ServiceID)
+	assert.NoError(t, err, "DeleteService should not return an error")
+
+	_, err = ms.GetService(ctx, inserted.ServiceID)
+	assert.True(t, errors.Is(err, ErrNotFound), "GetService should return ErrNotFound")
+}
+
+func TestMemStoreRemoveServiceInstanceArchivesHistory(t *testing.T) {
+	ms := NewMemStore()
+	ctx := context.Background()
+
+	service, err := ms.RegisterService(ctx, models.Service{Name: "Test Service"})
+	assert.NoError(t, err, "RegisterService should not return an error")
+
+	instance, err := ms.CreateServiceInstance(
+		ctx, models.ServiceInstance{
+			ServiceID: service.ServiceID,
+			Version:   "1.0.0",
+			Host:      "localhost",
+			Port:      8080,
+			Url:       "http://localhost:8080",
+		},
+	)
+	assert.NoError(t, err, "CreateServiceInstance should not return an error")
+	assert.Equal(t, models.HealthStarting, instance.HealthStatus)
+
+	err = ms.DeleteService(ctx, service.ServiceID)
+	assert.Error(t, err, "DeleteService should refuse a service with instances")
+
+	err = ms.RemoveServiceInstance(ctx, instance.InstanceID)
+	assert.NoError(t, err, "RemoveServiceInstance should not return an error")
+
+	_, err = ms.GetServiceInstance(ctx, instance.InstanceID)
+	assert.True(t, errors.Is(err, ErrNotFound), "instance should be deleted")
+
+	history := ms.History()
+	assert.Len(t, history, 1, "instance should be archived")
+	assert.Equal(t, instance.InstanceID, history[0].InstanceID, "InstanceID should match")
+	assert.Equal(t, "1.0.0", history[0].Version, "Version should match")
+}
+
+func TestMemStoreCreateServiceInstanceUnknownService(t *testing.T) {
+	ms := NewMemStore()
+
+	_, err := ms.CreateServiceInstance(
+		context.Background(), models.ServiceInstance{ServiceID: uuid.New(), Version: "1.0.0"},
+	)
+	assert.ErrorIs(t, err, ErrReference, "unknown service should return ErrReference")
+}
+
+func TestMemStoreDerivesInstanceURL(t *testing.T) {
+	ms := NewMemStore()
+	ctx := context.Background()
+	service, err := ms.RegisterService(ctx, models.Service{Name: "Test Service"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		instance  models.ServiceInstance
+		url       string
+		endpoints *models.Endpoints
+	}{
+		{
+			models.ServiceInstance{Host: "10.0.0.7", Port: 8080},
+			"http://10.0.0.7:8080", &models.Endpoints{HTTP: "http://10.0.0.7:8080"},
+		},
+		{
+			models.ServiceInstance{Scheme: "https", Host: "api.example.com", Port: 443, BasePath: "v1/"},
+			"https://api.example.com/v1", &models.Endpoints{HTTPS: "https://api.example.com/v1"},
+		},
+		{
+			models.ServiceInstance{Host: "::1", Port: 9000},
+			"http://[::1]:9000", &models.Endpoints{HTTP: "http://[::1]:9000"},
+		},
+		{
+			models.ServiceInstance{
+				Url: "https://given.example.com", Host: "ignored", Port: 1,
+				Endpoints: &models.Endpoints{GRPC: "grpc://given.example.com:9090"},
+			},
+			"https://given.example.com", &models.Endpoints{
+				HTTPS: "https://given.example.com", GRPC: "grpc://given.example.com:9090",
+			},
+		},
+		{models.ServiceInstance{}, "", nil},
+	}
+	for _, tt := range tests {
+		tt.instance.ServiceID, tt.instance.Version = service.ServiceID, "1.0.0"
+		created, err := ms.CreateServiceInstance(ctx, tt.instance)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.url, created.Url)
+		assert.Equal(t, tt.endpoints, created.Endpoints)
+	}
+}
+
+func TestMemStoreWithTxRollsBackOnError(t *testing.T) {
+	ms := NewMemStore()
+	ctx := context.Background()
+
+	errAbort := errors.New("abort")
+	err := ms.WithTx(
+		ctx, func(tx Store) error {
+			if _, err := tx.RegisterService(ctx, models.Service{Name: "Rolled Back"}); err != nil {
+				return err
+			}
+			return errAbort
+		},
+	)
+	assert.True(t, errors.Is(err, errAbort), "WithTx should return the error from fn")
+
+	services, err := ms.ListServices(ctx, ServiceQuery{})
+	assert.NoError(t, err, "ListServices should not return an error")
+	assert.Empty(t, services, "the service should have been rolled back")
+
+	err = ms.WithTx(
+		ctx, func(tx Store) error {
+			_, err := tx.RegisterService(ctx, models.Service{Name: "Committed"})
+			return err
+		},
+	)
+	assert.NoError(t, err, "WithTx should not return an error")
+
+	services, err = ms.ListServices(ctx, ServiceQuery{})
+	assert.NoError(t, err, "ListServices should not return an error")
+	assert.Len(t, services, 1, "the service should have been committed")
+}
+
+func TestMemStoreIndex(t *testing.T) {
+	ms := NewMemStore()
+	ctx := context.Background()
+
+	index, err := ms.AdvanceIndex(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), index)
+
+	err = ms.WithTx(
+		ctx, func(tx Store) error {
+			_, err := tx.AdvanceIndex(ctx, 3)
+			assert.NoError(t, err)
+			return errors.New("abort")
+		},
+	)
+	assert.Error(t, err)
+
+	index, err = ms.Index(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), index, "a rolled back advance should be discarded")
+}
+
+func TestMemStoreLeaseExpiry(t *testing.T) {
+	ms := NewMemStore()
+	ctx := context.Background()
+
+	service, err := ms.RegisterService(ctx, models.Service{Name: "Test Service"})
+	assert.NoError(t, err, "RegisterService should not return an error")
+
+	leased, err := ms.CreateServiceInstance(
+		ctx, models.ServiceInstance{
+			ServiceID: service.ServiceID,
+			Version:   "1.0.0",
+			LeaseTTL:  models.Duration(10 * time.Second),
+		},
+	)
+	assert.NoError(t, err, "CreateServiceInstance should not return an error")
+	assert.NotNil(t, leased.LeaseExpiresAt, "leased instance should have an expiry")
+
+	unleased, err := ms.CreateServiceInstance(
+		ctx, models.ServiceInstance{ServiceID: service.ServiceID, Version: "1.0.0"},
+	)
+	assert.NoError(t, err, "CreateServiceInstance should not return an error")
+	assert.Nil(t, unleased.LeaseExpiresAt, "instance without a TTL should not expire")
+
+	_, err = ms.RenewLease(ctx, unleased.InstanceID, 0, time.Now())
+	assert.ErrorIs(t, err, ErrInvalid, "renewing without a TTL should be rejected")
+
+	renewAt := leased.CreatedAt.Add(8 * time.Second)
+	renewed, err := ms.RenewLease(ctx, leased.InstanceID, 0, renewAt)
+	assert.NoError(t, err, "RenewLease should not return an error")
+	assert.Equal(t, renewAt.Add(10*time.Second), *renewed.LeaseExpiresAt)
+
+	expired, err := ms.ExpireLeases(ctx, renewAt.Add(5*time.Second))
+	assert.NoError(t, err, "ExpireLeases should not return an error")
+	assert.Empty(t, expired, "a renewed lease should not expire")
+
+	expired, err = ms.ExpireLeases(ctx, renewAt.Add(11*time.Second))
+	assert.NoError(t, err, "ExpireLeases should not return an error")
+	assert.Len(t, expired, 1, "the lapsed lease should expire")
+	assert.Equal(t, leased.InstanceID, expired[0].InstanceID)
+
+	_, err = ms.GetServiceInstance(ctx, leased.InstanceID)
+	assert.ErrorIs(t, err, ErrNotFound, "expired instance should be deleted")
+	_, err = ms.GetServiceInstance(ctx, unleased.InstanceID)
+	assert.NoError(t, err, "instance without a lease should remain")
+
+	history := ms.History()
+	assert.Len(t, history, 1, "expired instance should be archived")
+	assert.Equal(t, ReasonLeaseExpired, history[0].Metrics["reason"])
+}
@@ -0,0 +1,438 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration is one versioned schema change. Files are named
+// NNNN_description.up.sql and NNNN_description.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// migrationTx is a transaction on the database being migrated.
+type migrationTx interface {
+	// exec executes a statement in the transaction.
+	exec(ctx context.Context, query string, args ...any) error
+	// applied returns the applied versions and when they were applied.
+	applied(ctx context.Context) (map[int]time.Time, error)
+}
+
+// migrationTarget abstracts the database specific parts of the migrator.
+type migrationTarget interface {
+	// ensureTable creates the schema_migrations table if needed.
+	ensureTable(ctx context.Context) error
+	// inTx runs fn in a transaction that is serialised against other migrators.
+	inTx(ctx context.Context, fn func(tx migrationTx) error) error
+	// table returns the qualified name of the schema_migrations table.
+	table() string
+	// placeholder returns the bind parameter syntax for the i-th argument.
+	placeholder(i int) string
+}
+
+// Migrator applies the embedded migrations for one database dialect.
+type Migrator struct {
+	target     migrationTarget
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator for store. The in-memory store has no schema
+// and therefore cannot be migrated.
+func NewMigrator(store Store) (*Migrator, error) {
+	switch st := store.(type) {
+	case *DbCtx:
+		return newMigrator(pgMigrationTarget{st.Pool}, DriverPostgres)
+	case *SQLiteStore:
+		return newMigrator(sqliteMigrationTarget{st.DB}, DriverSQLite)
+	default:
+		return nil, fmt.Errorf("store %T does not support migrations", store)
+	}
+}
+
+func newMigrator(target migrationTarget, dialect string) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles, path.Join("migrations", dialect))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{target: target, migrations: migrations}, nil
+}
+
+// migrateUp applies all pending migrations to store.
+func migrateUp(ctx context.Context, store Store) error {
+	migrator, err := NewMigrator(store)
+	if err != nil {
+		return err
+	}
+
+	_, err = migrator.Up(ctx)
+	return err
+}
+
+// loadMigrations reads and orders the migrations found in dir.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %q", fileName)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", fileName, err)
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", fileName, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("conflicting names for migration %d", version)
+		}
+
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(
+		migrations, func(i, j int) bool {
+			return migrations[i].Version < migrations[j].Version
+		},
+	)
+
+	return migrations, nil
+}
+
+// Up applies all pending migrations in order and returns the ones applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if err := m.target.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		applied := false
+		err := m.target.inTx(
+			ctx, func(tx migrationTx) error {
+				versions, err := tx.applied(ctx)
+				if err != nil {
+					return err
+				}
+				if _, ok := versions[migration.Version]; ok {
+					return nil
+				}
+
+				if err := tx.exec(ctx, migration.Up); err != nil {
+					return err
+				}
+				applied = true
+
+				return tx.exec(
+					ctx, fmt.Sprintf(
+						"INSERT INTO %s (version, name, applied_at) VALUES (%s, %s, %s)",
+						m.target.table(), m.target.placeholder(1), m.target.placeholder(2),
+						m.target.placeholder(3),
+					), migration.Version, migration.Name, time.Now().UTC(),
+				)
+			},
+		)
+		if err != nil {
+			return done, fmt.Errorf(
+				"failed to apply migration %04d_%s: %w", migration.Version, migration.Name, err,
+			)
+		}
+		if applied {
+			done = append(done, migration)
+		}
+	}
+
+	return done, nil
+}
+
+// Down rolls back the most recently applied migration. It returns nil when
+// there is nothing to roll back.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	if err := m.target.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var rolledBack *Migration
+	err := m.target.inTx(
+		ctx, func(tx migrationTx) error {
+			versions, err := tx.applied(ctx)
+			if err != nil {
+				return err
+			}
+
+			for i := len(m.migrations) - 1; i >= 0; i-- {
+				migration := m.migrations[i]
+				if _, ok := versions[migration.Version]; !ok {
+					continue
+				}
+				if migration.Down == "" {
+					return fmt.Errorf(
+						"migration %04d_%s is irreversible", migration.Version, migration.Name,
+					)
+				}
+
+				if err := tx.exec(ctx, migration.Down); err != nil {
+					return err
+				}
+				rolledBack = &migration
+
+				return tx.exec(
+					ctx, fmt.Sprintf(
+						"DELETE FROM %s WHERE version = %s", m.target.table(), m.target.placeholder(1),
+					), migration.Version,
+				)
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to roll back migration: %w", err)
+	}
+
+	return rolledBack, nil
+}
+
+// Status lists every known migration and when it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.target.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var versions map[int]time.Time
+	err := m.target.inTx(
+		ctx, func(tx migrationTx) error {
+			var err error
+			versions, err = tx.applied(ctx)
+			return err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if appliedAt, ok := versions[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// pgMigrationTarget runs migrations against PostgreSQL.
+type pgMigrationTarget struct {
+	pool *pgxpool.Pool
+}
+
+// migrationLockID is the advisory lock key that serialises registry
+// instances migrating the same database.
+const migrationLockID = 0x72656769737472 // "registr"
+
+func (t pgMigrationTarget) ensureTable(ctx context.Context) error {
+	_, err := t.pool.Exec(
+		ctx, `
+		CREATE SCHEMA IF NOT EXISTS r1;
+		CREATE TABLE IF NOT EXISTS r1.schema_migrations
+		(
+			version    INTEGER PRIMARY KEY,
+			name       VARCHAR     NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return nil
+}
+
+func (t pgMigrationTarget) inTx(ctx context.Context, fn func(tx migrationTx) error) error {
+	return pgx.BeginFunc(
+		ctx, t.pool, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+				return fmt.Errorf("failed to acquire migration lock: %w", err)
+			}
+
+			return fn(pgMigrationTx{tx})
+		},
+	)
+}
+
+func (t pgMigrationTarget) table() string {
+	return "r1.schema_migrations"
+}
+
+func (t pgMigrationTarget) placeholder(i int) string {
+	return "$" + strconv.Itoa(i)
+}
+
+// pgMigrationTx is a migration transaction on PostgreSQL.
+type pgMigrationTx struct {
+	tx pgx.Tx
+}
+
+func (t pgMigrationTx) exec(ctx context.Context, query string, args ...any) error {
+	_, err := t.tx.Exec(ctx, query, args...)
+	return err
+}
+
+func (t pgMigrationTx) applied(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := t.tx.Query(ctx, `SELECT version, applied_at FROM r1.schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		versions[version] = appliedAt
+	}
+
+	return versions, rows.Err()
+}
+
+// sqliteMigrationTarget runs migrations against SQLite.
+type sqliteMigrationTarget struct {
+	db *sql.DB
+}
+
+func (t sqliteMigrationTarget) ensureTable(ctx context.Context) error {
+	_, err := t.db.ExecContext(
+		ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations
+		(
+			version    INTEGER PRIMARY KEY,
+			name       TEXT      NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return nil
+}
+
+func (t sqliteMigrationTarget) inTx(ctx context.Context, fn func(tx migrationTx) error) error {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(sqliteMigrationTx{tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (t sqliteMigrationTarget) table() string {
+	return "schema_migrations"
+}
+
+func (t sqliteMigrationTarget) placeholder(int) string {
+	return "?"
+}
+
+// sqliteMigrationTx is a migration transaction on SQLite.
+type sqliteMigrationTx struct {
+	tx *sql.Tx
+}
+
+func (t sqliteMigrationTx) exec(ctx context.Context, query string, args ...any) error {
+	_, err := t.tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (t sqliteMigrationTx) applied(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := t.tx.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		versions[version] = appliedAt
+	}
+
+	return versions, rows.Err()
+}
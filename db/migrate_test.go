@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMigrationsOrdersByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_add_index.up.sql":   {Data: []byte("CREATE INDEX x ON t (a);")},
+		"m/0002_add_index.down.sql": {Data: []byte("DROP INDEX x;")},
+		"m/0001_create_t.up.sql":    {Data: []byte("CREATE TABLE t (a INTEGER);")},
+		"m/README.md":               {Data: []byte("ignored")},
+	}
+
+	migrations, err := loadMigrations(fsys, "m")
+	assert.NoError(t, err, "loadMigrations should not return an error")
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, 1, migrations[0].Version)
+	assert.Equal(t, "create_t", migrations[0].Name)
+	assert.Empty(t, migrations[0].Down, "0001 has no down script")
+	assert.Equal(t, 2, migrations[1].Version)
+	assert.Equal(t, "DROP INDEX x;", migrations[1].Down)
+}
+
+func TestLoadMigrationsRejectsMissingUp(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0001_create_t.down.sql": {Data: []byte("DROP TABLE t;")},
+	}
+
+	_, err := loadMigrations(fsys, "m")
+	assert.Error(t, err, "a migration without an up script should be rejected")
+}
+
+func TestSQLiteMigrateUpDownStatus(t *testing.T) {
+	ss := setupTestSQLite(t)
+	ctx := context.Background()
+
+	migrator, err := NewMigrator(ss)
+	assert.NoError(t, err, "NewMigrator should not return an error")
+
+	// setupTestSQLite already migrated the database
+	applied, err := migrator.Up(ctx)
+	assert.NoError(t, err, "Up should not return an error")
+	assert.Empty(t, applied, "Up should be idempotent")
+
+	statuses, err := migrator.Status(ctx)
+	assert.NoError(t, err, "Status should not return an error")
+	for _, st := range statuses {
+		assert.NotNil(t, st.AppliedAt, "migration %d should be applied", st.Version)
+	}
+
+	latest := statuses[len(statuses)-1]
+	rolledBack, err := migrator.Down(ctx)
+	assert.NoError(t, err, "Down should not return an error")
+	assert.Equal(t, latest.Version, rolledBack.Version, "Down should roll back the latest")
+
+	statuses, err = migrator.Status(ctx)
+	assert.NoError(t, err, "Status should not return an error")
+	assert.Nil(t, statuses[len(statuses)-1].AppliedAt, "latest migration should be pending")
+
+	applied, err = migrator.Up(ctx)
+	assert.NoError(t, err, "Up should not return an error")
+	assert.Len(t, applied, 1, "Up should re-apply the rolled back migration")
+}
+
+func TestNewMigratorRejectsMemStore(t *testing.T) {
+	_, err := NewMigrator(NewMemStore())
+	assert.Error(t, err, "the in-memory store has no schema to migrate")
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS r1.registry_group;
+DROP TABLE IF EXISTS r1.registries;
+DROP TABLE IF EXISTS r1.service_reviews;
+DROP TABLE IF EXISTS r1.service_instance_history;
+DROP TABLE IF EXISTS r1.service_instances;
+DROP TABLE IF EXISTS r1.services;
@@ -1,8 +1,6 @@
-CREATE SCHEMA r1;
+CREATE SCHEMA IF NOT EXISTS r1;
 
-SET search_path TO r1, public;
-
-CREATE TABLE services
+CREATE TABLE IF NOT EXISTS r1.services
 (
     service_id        UUID PRIMARY KEY,
     name              VARCHAR NOT NULL,
@@ -14,10 +12,10 @@ CREATE TABLE services
     updated_at        TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 );
 
-CREATE TABLE service_instances
+CREATE TABLE IF NOT EXISTS r1.service_instances
 (
     instance_id   UUID PRIMARY KEY,
-    service_id    UUID REFERENCES services (service_id),
+    service_id    UUID REFERENCES r1.services (service_id),
     version       VARCHAR NOT NULL,
     host          VARCHAR NOT NULL,
     port          INTEGER NOT NULL,
@@ -30,20 +28,22 @@ CREATE TABLE service_instances
     last_checked  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 );
 
-CREATE TABLE service_instance_history
+CREATE TABLE IF NOT EXISTS r1.service_instance_history
 (
-    history_id  UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
-    instance_id UUID PRIMARY KEY,
-    service_id  UUID REFERENCES services (service_id),
+    history_id  UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    instance_id UUID NOT NULL,
+    service_id  UUID REFERENCES r1.services (service_id),
     version     VARCHAR NOT NULL,
     url         VARCHAR NOT NULL,
     metrics     JSON,
     started_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
-    stopped_at   TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    stopped_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE INDEX IF NOT EXISTS service_instance_history_instance_id_idx
+    ON r1.service_instance_history (instance_id);
 
-CREATE TABLE registries
+CREATE TABLE IF NOT EXISTS r1.registries
 (
     registry_id UUID PRIMARY KEY,
     url         VARCHAR NOT NULL,
@@ -51,20 +51,20 @@ CREATE TABLE registries
     updated_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 );
 
-CREATE TABLE service_reviews
+CREATE TABLE IF NOT EXISTS r1.service_reviews
 (
     review_id  UUID PRIMARY KEY,
-    service_id UUID REFERENCES services (service_id),
+    service_id UUID REFERENCES r1.services (service_id),
     rating     INTEGER NOT NULL,
     review     TEXT,
     created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 );
 
-CREATE TABLE registry_group
+CREATE TABLE IF NOT EXISTS r1.registry_group
 (
     group_id    UUID PRIMARY KEY,
-    registry_id UUID REFERENCES registries (registry_id),
+    registry_id UUID REFERENCES r1.registries (registry_id),
     created_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
     updated_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
-);
\ No newline at end of file
+);
@@ -0,0 +1,3 @@
+ALTER TABLE r1.service_instance_history
+    ADD CONSTRAINT service_instance_history_service_id_fkey
+        FOREIGN KEY (service_id) REFERENCES r1.services (service_id) NOT VALID;
@@ -0,0 +1,3 @@
+-- Archived instances are kept after their service is deleted.
+ALTER TABLE r1.service_instance_history
+    DROP CONSTRAINT IF EXISTS service_instance_history_service_id_fkey;
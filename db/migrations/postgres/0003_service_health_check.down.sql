@@ -0,0 +1,2 @@
+ALTER TABLE r1.services
+    DROP COLUMN IF EXISTS health_check;
@@ -0,0 +1,2 @@
+ALTER TABLE r1.services
+    ADD COLUMN IF NOT EXISTS health_check JSONB;
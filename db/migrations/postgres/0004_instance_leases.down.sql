@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS r1.service_instances_lease_expires_at_idx;
+
+ALTER TABLE r1.service_instances
+    DROP COLUMN IF EXISTS lease_expires_at,
+    DROP COLUMN IF EXISTS lease_ttl_ms;
@@ -0,0 +1,7 @@
+ALTER TABLE r1.service_instances
+    ADD COLUMN IF NOT EXISTS lease_ttl_ms     BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS service_instances_lease_expires_at_idx
+    ON r1.service_instances (lease_expires_at)
+    WHERE lease_expires_at IS NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE r1.service_instances
+    DROP COLUMN IF EXISTS endpoints,
+    DROP COLUMN IF EXISTS base_path,
+    DROP COLUMN IF EXISTS scheme;
@@ -0,0 +1,4 @@
+ALTER TABLE r1.service_instances
+    ADD COLUMN IF NOT EXISTS scheme    TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS base_path TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS endpoints JSONB;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS r1.services_transaction_count_idx;
+DROP INDEX IF EXISTS r1.services_created_at_idx;
+DROP INDEX IF EXISTS r1.services_rating_idx;
+DROP INDEX IF EXISTS r1.services_name_idx;
+
+ALTER TABLE r1.services
+    DROP COLUMN IF EXISTS avg_response_time,
+    DROP COLUMN IF EXISTS transaction_count;
@@ -0,0 +1,13 @@
+ALTER TABLE r1.services
+    ADD COLUMN IF NOT EXISTS transaction_count BIGINT           NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS avg_response_time DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- Keyset pagination reads each ordering from an index
+CREATE INDEX IF NOT EXISTS services_name_idx
+    ON r1.services (name, service_id);
+CREATE INDEX IF NOT EXISTS services_rating_idx
+    ON r1.services ((COALESCE(client_rating, 0)), service_id);
+CREATE INDEX IF NOT EXISTS services_created_at_idx
+    ON r1.services (created_at, service_id);
+CREATE INDEX IF NOT EXISTS services_transaction_count_idx
+    ON r1.services (transaction_count, service_id);
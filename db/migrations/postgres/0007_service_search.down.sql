@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS r1.services_search_trgm_idx;
+DROP INDEX IF EXISTS r1.services_search_idx;
+
+ALTER TABLE r1.services
+    DROP COLUMN IF EXISTS search_text,
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS tags;
+
+DROP FUNCTION IF EXISTS r1.tags_text(TEXT[]);
+
+-- pg_trgm stays installed: other schemas in the database may use it
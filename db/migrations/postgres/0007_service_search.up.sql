@@ -0,0 +1,33 @@
+ALTER TABLE r1.services
+    ADD COLUMN IF NOT EXISTS tags TEXT[];
+
+-- Trigram similarity lets search tolerate typos
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- array_to_string is only STABLE, but generated columns need IMMUTABLE expressions
+CREATE OR REPLACE FUNCTION r1.tags_text(tags TEXT[]) RETURNS TEXT
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE
+AS $$ SELECT COALESCE(array_to_string(tags, ' '), '') $$;
+
+-- The weights match the scorer of the other backends: name and tags rank
+-- first, then industry category, owner and description
+ALTER TABLE r1.services
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+        setweight(to_tsvector('english', r1.tags_text(tags)), 'A') ||
+        setweight(to_tsvector('english', COALESCE(industry_category, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(owner_info, '')), 'C') ||
+        setweight(to_tsvector('english', COALESCE(description, '')), 'D')
+    ) STORED,
+    ADD COLUMN IF NOT EXISTS search_text TEXT GENERATED ALWAYS AS (
+        lower(
+            COALESCE(name, '') || ' ' || r1.tags_text(tags) || ' ' ||
+            COALESCE(industry_category, '') || ' ' || COALESCE(owner_info, '') || ' ' ||
+            COALESCE(description, '')
+        )
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS services_search_idx
+    ON r1.services USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS services_search_trgm_idx
+    ON r1.services USING GIN (search_text gin_trgm_ops);
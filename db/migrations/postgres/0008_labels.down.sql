@@ -0,0 +1,5 @@
+ALTER TABLE r1.service_instances
+    DROP COLUMN IF EXISTS labels;
+
+ALTER TABLE r1.services
+    DROP COLUMN IF EXISTS labels;
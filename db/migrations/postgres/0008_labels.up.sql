@@ -0,0 +1,5 @@
+ALTER TABLE r1.services
+    ADD COLUMN IF NOT EXISTS labels JSONB;
+
+ALTER TABLE r1.service_instances
+    ADD COLUMN IF NOT EXISTS labels JSONB;
@@ -0,0 +1,11 @@
+ALTER TABLE r1.services
+    DROP COLUMN IF EXISTS review_count;
+
+ALTER TABLE r1.service_reviews
+    DROP CONSTRAINT IF EXISTS service_reviews_service_id_reviewer_key,
+    DROP CONSTRAINT IF EXISTS service_reviews_rating_check,
+    DROP CONSTRAINT IF EXISTS service_reviews_service_id_fkey,
+    ADD CONSTRAINT service_reviews_service_id_fkey
+        FOREIGN KEY (service_id) REFERENCES r1.services (service_id),
+    ALTER COLUMN service_id DROP NOT NULL,
+    DROP COLUMN IF EXISTS reviewer;
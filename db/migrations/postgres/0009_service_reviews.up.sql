@@ -0,0 +1,19 @@
+-- Each reviewer has one review of a service, and reviews go with their
+-- service. The table was unused until now, so it holds no rows to backfill.
+ALTER TABLE r1.service_reviews
+    ADD COLUMN IF NOT EXISTS reviewer TEXT NOT NULL,
+    ALTER COLUMN service_id SET NOT NULL,
+    DROP CONSTRAINT IF EXISTS service_reviews_service_id_fkey,
+    ADD CONSTRAINT service_reviews_service_id_fkey
+        FOREIGN KEY (service_id) REFERENCES r1.services (service_id) ON DELETE CASCADE,
+    ADD CONSTRAINT service_reviews_rating_check CHECK (rating BETWEEN 1 AND 5),
+    ADD CONSTRAINT service_reviews_service_id_reviewer_key UNIQUE (service_id, reviewer);
+
+-- client_rating is now computed from the reviews
+ALTER TABLE r1.services
+    ADD COLUMN IF NOT EXISTS review_count BIGINT NOT NULL DEFAULT 0;
+
+-- services have no reviews yet, so ratings clients set for themselves go
+UPDATE r1.services
+SET client_rating = NULL
+WHERE review_count = 0;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS r1.registry_index;
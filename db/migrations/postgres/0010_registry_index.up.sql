@@ -0,0 +1,10 @@
+-- The registry index counts the changes published to watchers. It lives in
+-- the database so that it survives restarts and every registry node sharing
+-- the database numbers changes the same way.
+CREATE TABLE IF NOT EXISTS r1.registry_index (
+    id    BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    value BIGINT  NOT NULL
+);
+
+INSERT INTO r1.registry_index (id, value) VALUES (TRUE, 0)
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS r1.services_lower_name_idx;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS registry_group;
+DROP TABLE IF EXISTS registries;
+DROP TABLE IF EXISTS service_reviews;
+DROP TABLE IF EXISTS service_instance_history;
+DROP TABLE IF EXISTS service_instances;
+DROP TABLE IF EXISTS services;
@@ -0,0 +1,70 @@
+-- SQLite mirror of the postgres schema. UUIDs are stored as TEXT and JSON as TEXT.
+
+CREATE TABLE IF NOT EXISTS services
+(
+    service_id        TEXT PRIMARY KEY,
+    name              TEXT NOT NULL,
+    description       TEXT,
+    owner_info        TEXT,
+    industry_category TEXT,
+    client_rating     REAL,
+    created_at        TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at        TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS service_instances
+(
+    instance_id   TEXT PRIMARY KEY,
+    service_id    TEXT REFERENCES services (service_id),
+    version       TEXT    NOT NULL,
+    host          TEXT    NOT NULL,
+    port          INTEGER NOT NULL,
+    url           TEXT    NOT NULL,
+    api_spec      TEXT,
+    latitude      REAL,
+    longitude     REAL,
+    health_status TEXT,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_checked  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS service_instance_history
+(
+    history_id  TEXT PRIMARY KEY,
+    instance_id TEXT NOT NULL,
+    service_id  TEXT REFERENCES services (service_id),
+    version     TEXT NOT NULL,
+    url         TEXT NOT NULL,
+    metrics     TEXT,
+    started_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    stopped_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS service_instance_history_instance_id_idx
+    ON service_instance_history (instance_id);
+
+CREATE TABLE IF NOT EXISTS registries
+(
+    registry_id TEXT PRIMARY KEY,
+    url         TEXT NOT NULL,
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS service_reviews
+(
+    review_id  TEXT PRIMARY KEY,
+    service_id TEXT REFERENCES services (service_id),
+    rating     INTEGER NOT NULL,
+    review     TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS registry_group
+(
+    group_id    TEXT PRIMARY KEY,
+    registry_id TEXT REFERENCES registries (registry_id),
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
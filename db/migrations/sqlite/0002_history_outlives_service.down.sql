@@ -0,0 +1,23 @@
+CREATE TABLE service_instance_history_old
+(
+    history_id  TEXT PRIMARY KEY,
+    instance_id TEXT NOT NULL,
+    service_id  TEXT REFERENCES services (service_id),
+    version     TEXT NOT NULL,
+    url         TEXT NOT NULL,
+    metrics     TEXT,
+    started_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    stopped_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO service_instance_history_old
+SELECT history_id, instance_id, service_id, version, url, metrics, started_at, stopped_at
+FROM service_instance_history
+WHERE service_id IS NULL OR service_id IN (SELECT service_id FROM services);
+
+DROP TABLE service_instance_history;
+
+ALTER TABLE service_instance_history_old RENAME TO service_instance_history;
+
+CREATE INDEX IF NOT EXISTS service_instance_history_instance_id_idx
+    ON service_instance_history (instance_id);
@@ -0,0 +1,24 @@
+-- Archived instances are kept after their service is deleted. SQLite cannot
+-- drop a constraint, so the table is rebuilt without it.
+CREATE TABLE service_instance_history_new
+(
+    history_id  TEXT PRIMARY KEY,
+    instance_id TEXT NOT NULL,
+    service_id  TEXT,
+    version     TEXT NOT NULL,
+    url         TEXT NOT NULL,
+    metrics     TEXT,
+    started_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    stopped_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO service_instance_history_new
+SELECT history_id, instance_id, service_id, version, url, metrics, started_at, stopped_at
+FROM service_instance_history;
+
+DROP TABLE service_instance_history;
+
+ALTER TABLE service_instance_history_new RENAME TO service_instance_history;
+
+CREATE INDEX IF NOT EXISTS service_instance_history_instance_id_idx
+    ON service_instance_history (instance_id);
@@ -0,0 +1,2 @@
+ALTER TABLE services
+    ADD COLUMN health_check TEXT;
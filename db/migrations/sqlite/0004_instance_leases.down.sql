@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS service_instances_lease_expires_at_idx;
+
+ALTER TABLE service_instances
+    DROP COLUMN lease_expires_at;
+
+ALTER TABLE service_instances
+    DROP COLUMN lease_ttl_ms;
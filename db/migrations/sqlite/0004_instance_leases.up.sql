@@ -0,0 +1,8 @@
+ALTER TABLE service_instances
+    ADD COLUMN lease_ttl_ms INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE service_instances
+    ADD COLUMN lease_expires_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS service_instances_lease_expires_at_idx
+    ON service_instances (lease_expires_at);
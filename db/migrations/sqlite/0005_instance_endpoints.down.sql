@@ -0,0 +1,8 @@
+ALTER TABLE service_instances
+    DROP COLUMN endpoints;
+
+ALTER TABLE service_instances
+    DROP COLUMN base_path;
+
+ALTER TABLE service_instances
+    DROP COLUMN scheme;
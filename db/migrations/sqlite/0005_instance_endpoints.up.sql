@@ -0,0 +1,8 @@
+ALTER TABLE service_instances
+    ADD COLUMN scheme TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE service_instances
+    ADD COLUMN base_path TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE service_instances
+    ADD COLUMN endpoints TEXT;
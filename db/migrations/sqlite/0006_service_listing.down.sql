@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS services_transaction_count_idx;
+DROP INDEX IF EXISTS services_created_at_idx;
+DROP INDEX IF EXISTS services_rating_idx;
+DROP INDEX IF EXISTS services_name_idx;
+
+ALTER TABLE services
+    DROP COLUMN avg_response_time;
+
+ALTER TABLE services
+    DROP COLUMN transaction_count;
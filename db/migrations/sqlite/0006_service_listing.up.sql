@@ -0,0 +1,15 @@
+ALTER TABLE services
+    ADD COLUMN transaction_count INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE services
+    ADD COLUMN avg_response_time REAL NOT NULL DEFAULT 0;
+
+-- Keyset pagination reads each ordering from an index
+CREATE INDEX IF NOT EXISTS services_name_idx
+    ON services (name, service_id);
+CREATE INDEX IF NOT EXISTS services_rating_idx
+    ON services (COALESCE(client_rating, 0), service_id);
+CREATE INDEX IF NOT EXISTS services_created_at_idx
+    ON services (created_at, service_id);
+CREATE INDEX IF NOT EXISTS services_transaction_count_idx
+    ON services (transaction_count, service_id);
@@ -0,0 +1,3 @@
+-- Tags are stored as a JSON array; search scores services in Go
+ALTER TABLE services
+    ADD COLUMN tags TEXT;
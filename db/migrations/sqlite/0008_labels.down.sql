@@ -0,0 +1,5 @@
+ALTER TABLE service_instances
+    DROP COLUMN labels;
+
+ALTER TABLE services
+    DROP COLUMN labels;
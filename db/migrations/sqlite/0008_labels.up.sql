@@ -0,0 +1,6 @@
+-- Labels are stored as JSON objects
+ALTER TABLE services
+    ADD COLUMN labels TEXT;
+
+ALTER TABLE service_instances
+    ADD COLUMN labels TEXT;
@@ -0,0 +1,14 @@
+ALTER TABLE services
+    DROP COLUMN review_count;
+
+DROP TABLE service_reviews;
+
+CREATE TABLE service_reviews
+(
+    review_id  TEXT PRIMARY KEY,
+    service_id TEXT REFERENCES services (service_id),
+    rating     INTEGER NOT NULL,
+    review     TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
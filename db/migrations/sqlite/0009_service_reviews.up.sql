@@ -0,0 +1,25 @@
+-- Each reviewer has one review of a service, and reviews go with their
+-- service. SQLite cannot alter constraints, so the table, unused until now,
+-- is recreated.
+DROP TABLE service_reviews;
+
+CREATE TABLE service_reviews
+(
+    review_id  TEXT PRIMARY KEY,
+    service_id TEXT    NOT NULL REFERENCES services (service_id) ON DELETE CASCADE,
+    reviewer   TEXT    NOT NULL,
+    rating     INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    review     TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (service_id, reviewer)
+);
+
+-- client_rating is now computed from the reviews
+ALTER TABLE services
+    ADD COLUMN review_count INTEGER NOT NULL DEFAULT 0;
+
+-- services have no reviews yet, so ratings clients set for themselves go
+UPDATE services
+SET client_rating = NULL
+WHERE review_count = 0;
@@ -0,0 +1 @@
+DROP TABLE registry_index;
@@ -0,0 +1,8 @@
+-- The registry index counts the changes published to watchers. It lives in
+-- the database so that it survives restarts.
+CREATE TABLE registry_index (
+    id    INTEGER PRIMARY KEY CHECK (id = 1),
+    value INTEGER NOT NULL
+);
+
+INSERT INTO registry_index (id, value) VALUES (1, 0);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS services_lower_name_idx;
@@ -0,0 +1,3 @@
+-- Discovery looks services up by name, ignoring case
+CREATE INDEX IF NOT EXISTS services_lower_name_idx
+    ON services (lower(name));
@@ -0,0 +1,248 @@
+package db
+
+import (
+	"DirectoryService/labels"
+	"DirectoryService/models"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ServiceSort is an order ListServices can return services in. Ties are
+// broken by service ID so that every ordering is total.
+type ServiceSort string
+
+const (
+	SortByName             ServiceSort = "name"
+	SortByRating           ServiceSort = "rating"
+	SortByCreatedAt        ServiceSort = "created_at"
+	SortByTransactionCount ServiceSort = "transaction_count"
+)
+
+// serviceSortColumns maps each ServiceSort to the SQL expression it orders by.
+var serviceSortColumns = map[ServiceSort]string{
+	SortByName:             "name",
+	SortByRating:           "COALESCE(client_rating, 0)",
+	SortByCreatedAt:        "created_at",
+	SortByTransactionCount: "transaction_count",
+}
+
+// ServiceQuery filters, orders and pages the services returned by
+// ListServices. The zero value returns every service ordered by name.
+type ServiceQuery struct {
+	Name             string   // exact match, ignoring case
+	IndustryCategory string   // exact match, ignoring case
+	Categories       []string // any of these industry categories, exactly
+	Owner            string   // substring of owner_info, ignoring case
+	MinRating        float64  // minimum client_rating
+	Labels           labels.Selector
+
+	Sort       ServiceSort // defaults to SortByName
+	Descending bool
+
+	// After continues a listing after the service the cursor was taken from.
+	After *ServiceCursor
+
+	// Limit bounds the number of services returned; zero means no limit.
+	Limit int
+}
+
+// ServiceCursor is a position in a ListServices ordering: the sort key and ID
+// of the last service a page returned.
+type ServiceCursor struct {
+	Sort       ServiceSort `json:"s"`
+	Descending bool        `json:"d,omitempty"`
+	ServiceID  uuid.UUID   `json:"id"`
+
+	// The key of the cursor's sort order; the others are zero.
+	Name             string    `json:"n,omitempty"`
+	ClientRating     float64   `json:"r,omitempty"`
+	CreatedAt        time.Time `json:"c,omitempty"`
+	TransactionCount int64     `json:"t,omitempty"`
+}
+
+// CursorAfter returns the cursor that continues q after service.
+func (q ServiceQuery) CursorAfter(service models.Service) ServiceCursor {
+	c := ServiceCursor{Sort: q.sort(), Descending: q.Descending, ServiceID: service.ServiceID}
+	switch c.Sort {
+	case SortByRating:
+		c.ClientRating = service.ClientRating
+	case SortByCreatedAt:
+		c.CreatedAt = service.CreatedAt
+	case SortByTransactionCount:
+		c.TransactionCount = service.TransactionCount
+	default:
+		c.Name = service.Name
+	}
+	return c
+}
+
+// Encode returns the cursor as an opaque URL-safe string.
+func (c ServiceCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeServiceCursor parses a cursor returned by ServiceCursor.Encode.
+func DecodeServiceCursor(s string) (*ServiceCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalid)
+	}
+
+	var c ServiceCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalid)
+	}
+	if _, ok := serviceSortColumns[c.Sort]; !ok {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalid)
+	}
+
+	return &c, nil
+}
+
+// sort returns the query's order, defaulting to SortByName.
+func (q ServiceQuery) sort() ServiceSort {
+	if q.Sort == "" {
+		return SortByName
+	}
+	return q.Sort
+}
+
+// check rejects an unknown order, a negative limit and a cursor taken from a
+// different order.
+func (q ServiceQuery) check() error {
+	if _, ok := serviceSortColumns[q.sort()]; !ok {
+		return fmt.Errorf("%w: unknown sort %q", ErrInvalid, q.Sort)
+	}
+	if q.Limit < 0 {
+		return fmt.Errorf("%w: limit must not be negative", ErrInvalid)
+	}
+	if q.After != nil && (q.After.Sort != q.sort() || q.After.Descending != q.Descending) {
+		return fmt.Errorf("%w: cursor belongs to a different sort order", ErrInvalid)
+	}
+	return nil
+}
+
+// Matches reports whether service passes the query's filters.
+func (q ServiceQuery) Matches(service models.Service) bool {
+	return (q.Name == "" || strings.EqualFold(service.Name, q.Name)) &&
+		(q.IndustryCategory == "" || strings.EqualFold(service.IndustryCategory, q.IndustryCategory)) &&
+		(len(q.Categories) == 0 || slices.Contains(q.Categories, service.IndustryCategory)) &&
+		(q.Owner == "" || strings.Contains(strings.ToLower(service.OwnerInfo), strings.ToLower(q.Owner))) &&
+		service.ClientRating >= q.MinRating &&
+		q.Labels.Matches(service.Labels)
+}
+
+// compare orders two services by the query's sort, then by ID.
+func (q ServiceQuery) compare(a, b models.Service) int {
+	var c int
+	switch q.sort() {
+	case SortByRating:
+		c = cmp.Compare(a.ClientRating, b.ClientRating)
+	case SortByCreatedAt:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	case SortByTransactionCount:
+		c = cmp.Compare(a.TransactionCount, b.TransactionCount)
+	default:
+		c = strings.Compare(a.Name, b.Name)
+	}
+	if c == 0 {
+		c = strings.Compare(a.ServiceID.String(), b.ServiceID.String())
+	}
+	if q.Descending {
+		return -c
+	}
+	return c
+}
+
+// cursorService returns a service holding the cursor's key, for compare.
+func (c ServiceCursor) cursorService() models.Service {
+	return models.Service{
+		ServiceID:        c.ServiceID,
+		Name:             c.Name,
+		ClientRating:     c.ClientRating,
+		CreatedAt:        c.CreatedAt,
+		TransactionCount: c.TransactionCount,
+	}
+}
+
+// key returns the cursor's sort key as an SQL argument.
+func (c ServiceCursor) key() any {
+	switch c.Sort {
+	case SortByRating:
+		return c.ClientRating
+	case SortByCreatedAt:
+		return c.CreatedAt
+	case SortByTransactionCount:
+		return c.TransactionCount
+	default:
+		return c.Name
+	}
+}
+
+// sql returns the WHERE (possibly empty) and ORDER BY/LIMIT clauses that
+// implement the query in the dialect, with arguments numbered from 1.
+func (q ServiceQuery) sql(d sqlDialect) (where, order string, args []any) {
+	var conditions []string
+	arg := func(v any) string {
+		args = append(args, v)
+		return d.placeholder(len(args))
+	}
+
+	if q.Name != "" {
+		conditions = append(conditions, "lower(name) = lower("+arg(q.Name)+")")
+	}
+	if q.IndustryCategory != "" {
+		conditions = append(conditions, "lower(industry_category) = lower("+arg(q.IndustryCategory)+")")
+	}
+	if len(q.Categories) > 0 {
+		placeholders := make([]string, len(q.Categories))
+		for i, category := range q.Categories {
+			placeholders[i] = arg(category)
+		}
+		conditions = append(conditions, "industry_category IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if q.Owner != "" {
+		conditions = append(
+			conditions,
+			`lower(owner_info) LIKE '%' || lower(`+arg(escapeLike(q.Owner))+`) || '%' ESCAPE '\'`,
+		)
+	}
+	if q.MinRating != 0 {
+		conditions = append(conditions, "COALESCE(client_rating, 0) >= "+arg(q.MinRating))
+	}
+	conditions = append(conditions, labelConditions(d, q.Labels, arg)...)
+
+	column, direction, cmpOp := serviceSortColumns[q.sort()], "", ">"
+	if q.Descending {
+		direction, cmpOp = " DESC", "<"
+	}
+	if q.After != nil {
+		conditions = append(
+			conditions,
+			fmt.Sprintf("(%s, service_id) %s (%s, %s)", column, cmpOp, arg(q.After.key()), arg(q.After.ServiceID)),
+		)
+	}
+
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	order = fmt.Sprintf("ORDER BY %s%s, service_id%s", column, direction, direction)
+	if q.Limit > 0 {
+		order += " LIMIT " + arg(q.Limit)
+	}
+
+	return where, order, args
+}
+
+// escapeLike escapes the LIKE wildcards in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
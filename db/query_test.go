@@ -0,0 +1,229 @@
+package db
+
+import (
+	"DirectoryService/industry"
+	"DirectoryService/labels"
+	"DirectoryService/models"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testListServices checks filtering, sorting and cursor paging of a store's
+// ListServices.
+func testListServices(t *testing.T, store Store) {
+	ctx := context.Background()
+	for _, service := range []models.Service{
+		{Name: "Delta", OwnerInfo: "Acme Corp", IndustryCategory: "Finance", ClientRating: 4.5, TransactionCount: 10},
+		{Name: "Alpha", OwnerInfo: "acme labs", IndustryCategory: "finance", ClientRating: 3, TransactionCount: 30},
+		{Name: "Charlie", OwnerInfo: "Initech", IndustryCategory: "Retail", ClientRating: 5, TransactionCount: 20},
+		{Name: "Bravo", OwnerInfo: "100%_owner", IndustryCategory: "Retail", TransactionCount: 40},
+	} {
+		_, err := store.RegisterService(ctx, service)
+		require.NoError(t, err)
+	}
+
+	names := func(query ServiceQuery) []string {
+		t.Helper()
+		services, err := store.ListServices(ctx, query)
+		require.NoError(t, err)
+		var names []string
+		for _, service := range services {
+			names = append(names, service.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"Alpha", "Bravo", "Charlie", "Delta"}, names(ServiceQuery{}))
+	assert.Equal(t, []string{"Charlie", "Delta", "Alpha", "Bravo"}, names(ServiceQuery{Sort: SortByRating, Descending: true}))
+	assert.Equal(t, []string{"Delta", "Charlie", "Alpha", "Bravo"}, names(ServiceQuery{Sort: SortByTransactionCount}))
+	assert.Equal(t, []string{"Delta", "Alpha", "Charlie", "Bravo"}, names(ServiceQuery{Sort: SortByCreatedAt}))
+
+	// filters ignore case; the owner filter matches substrings literally
+	assert.Equal(t, []string{"Charlie"}, names(ServiceQuery{Name: "charlie"}))
+	assert.Empty(t, names(ServiceQuery{Name: "Char"}), "names match exactly")
+	assert.Equal(t, []string{"Alpha", "Delta"}, names(ServiceQuery{IndustryCategory: "FINANCE"}))
+	assert.Equal(t, []string{"Alpha", "Delta"}, names(ServiceQuery{Owner: "ACME"}))
+	assert.Equal(t, []string{"Bravo"}, names(ServiceQuery{Owner: "%_"}))
+	assert.Equal(t, []string{"Charlie", "Delta"}, names(ServiceQuery{MinRating: 4}))
+
+	// paging through the listing visits every service once
+	for _, sort := range []ServiceSort{SortByName, SortByRating, SortByCreatedAt, SortByTransactionCount} {
+		for _, descending := range []bool{false, true} {
+			query := ServiceQuery{Sort: sort, Descending: descending, Limit: 3}
+			all := names(ServiceQuery{Sort: sort, Descending: descending})
+
+			var paged []string
+			for {
+				page, err := store.ListServices(ctx, query)
+				require.NoError(t, err)
+				for _, service := range page {
+					paged = append(paged, service.Name)
+				}
+				if len(page) < query.Limit {
+					break
+				}
+				cursor, err := DecodeServiceCursor(query.CursorAfter(page[len(page)-1]).Encode())
+				require.NoError(t, err)
+				query.After = cursor
+			}
+			assert.Equal(t, all, paged, "sort %s, descending %v", sort, descending)
+		}
+	}
+
+	// a cursor only continues the order it was taken from
+	cursor := ServiceQuery{Sort: SortByRating}.CursorAfter(models.Service{Name: "Alpha"})
+	_, err := store.ListServices(ctx, ServiceQuery{After: &cursor})
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	_, err = store.ListServices(ctx, ServiceQuery{Sort: "popularity"})
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestMemStoreListServices(t *testing.T) {
+	testListServices(t, NewMemStore())
+}
+
+func TestSQLiteListServices(t *testing.T) {
+	testListServices(t, setupTestSQLite(t))
+}
+
+func TestDecodeServiceCursorRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"", "not base64!", "bm90IGpzb24", "eyJzIjoicG9wdWxhcml0eSJ9"} {
+		_, err := DecodeServiceCursor(s)
+		assert.ErrorIs(t, err, ErrInvalid, "cursor %q", s)
+	}
+}
+
+// testLabelSelectors checks that a store's ListServices and
+// ListServiceInstances apply label selectors, including to unlabelled rows.
+func testLabelSelectors(t *testing.T, store Store) {
+	ctx := context.Background()
+	var serviceID uuid.UUID
+	for name, serviceLabels := range map[string]map[string]string{
+		"Payments": {"team": "payments", "env": "prod"},
+		"Ledger":   {"team": "finance", "env": "staging", "example.com/tier": "gold"},
+		"Legacy":   nil,
+	} {
+		service, err := store.RegisterService(ctx, models.Service{Name: name, Labels: serviceLabels})
+		require.NoError(t, err)
+		if name == "Payments" {
+			serviceID = service.ServiceID
+		}
+	}
+
+	for selector, want := range map[string][]string{
+		"":                                    {"Ledger", "Legacy", "Payments"},
+		"env=prod":                            {"Payments"},
+		"env!=prod":                           {"Ledger", "Legacy"},
+		"env in (prod,staging)":               {"Ledger", "Payments"},
+		"team notin (finance)":                {"Legacy", "Payments"},
+		"example.com/tier":                    {"Ledger"},
+		"!example.com/tier":                   {"Legacy", "Payments"},
+		"env,!example.com/tier,team=payments": {"Payments"},
+	} {
+		parsed, err := labels.Parse(selector)
+		require.NoError(t, err)
+		services, err := store.ListServices(ctx, ServiceQuery{Labels: parsed})
+		require.NoError(t, err)
+
+		var names []string
+		for _, service := range services {
+			names = append(names, service.Name)
+		}
+		assert.Equal(t, want, names, selector)
+	}
+
+	for _, instanceLabels := range []map[string]string{
+		{"region": "eu-west"}, {"region": "eu-north", "canary": ""}, {"region": "us-east"}, nil,
+	} {
+		_, err := store.CreateServiceInstance(
+			ctx, models.ServiceInstance{
+				ServiceID: serviceID, Version: "1.0.0", Host: "localhost", Labels: instanceLabels,
+			},
+		)
+		require.NoError(t, err)
+	}
+
+	parsed, err := labels.Parse("region in (eu-west,eu-north),!canary")
+	require.NoError(t, err)
+	instances, err := store.ListServiceInstances(ctx, InstanceFilter{ServiceID: serviceID, Labels: parsed})
+	require.NoError(t, err)
+	if assert.Len(t, instances, 1) {
+		assert.Equal(t, map[string]string{"region": "eu-west"}, instances[0].Labels)
+	}
+}
+
+func TestMemStoreLabelSelectors(t *testing.T) {
+	testLabelSelectors(t, NewMemStore())
+}
+
+func TestSQLiteLabelSelectors(t *testing.T) {
+	testLabelSelectors(t, setupTestSQLite(t))
+}
+
+// testIndustryCategories checks that a store keeps industry categories in
+// canonical form with their display names, and lists the services of a
+// category and the categories below it.
+func testIndustryCategories(t *testing.T, store Store) {
+	ctx := context.Background()
+	var clearing *models.Service
+	for name, category := range map[string]string{
+		"Clearing":  "522320",
+		"Cards":     "naics:522210",
+		"Brokerage": "NAICS:523",
+		"Platform":  "isic:6201",
+		"Legacy":    "Finance",
+	} {
+		service, err := store.RegisterService(ctx, models.Service{Name: name, IndustryCategory: category})
+		require.NoError(t, err)
+		if name == "Clearing" {
+			clearing = service
+		}
+	}
+
+	assert.Equal(t, "NAICS:522320", clearing.IndustryCategory)
+	assert.Equal(
+		t, "Financial Transactions Processing, Reserve, and Clearinghouse Activities",
+		clearing.IndustryCategoryName,
+	)
+	got, err := store.GetService(ctx, clearing.ServiceID)
+	require.NoError(t, err)
+	assert.Equal(t, clearing.IndustryCategoryName, got.IndustryCategoryName)
+
+	got.IndustryCategory = "isic:k"
+	got, err = store.UpdateService(ctx, *got)
+	require.NoError(t, err)
+	assert.Equal(t, "ISIC:K", got.IndustryCategory)
+	assert.Equal(t, "Financial and insurance activities", got.IndustryCategoryName)
+
+	names := func(category string) []string {
+		t.Helper()
+		c, err := industry.Lookup(category)
+		require.NoError(t, err)
+		services, err := store.ListServices(ctx, ServiceQuery{Categories: industry.Subtree(c)})
+		require.NoError(t, err)
+		var names []string
+		for _, service := range services {
+			names = append(names, service.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"Brokerage", "Cards"}, names("NAICS:52"))
+	assert.Equal(t, []string{"Cards"}, names("NAICS:522"))
+	assert.Equal(t, []string{"Clearing"}, names("ISIC:K"))
+	assert.Equal(t, []string{"Platform"}, names("ISIC:J"))
+	assert.Empty(t, names("NAICS:11"))
+}
+
+func TestMemStoreIndustryCategories(t *testing.T) {
+	testIndustryCategories(t, NewMemStore())
+}
+
+func TestSQLiteIndustryCategories(t *testing.T) {
+	testIndustryCategories(t, setupTestSQLite(t))
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"DirectoryService/models"
+	"math"
+	"strings"
+)
+
+// A client rating is a Bayesian average: ratingPriorWeight imaginary reviews
+// of ratingPrior stars are counted with the real ones, so that a few reviews
+// move the rating of a service only part of the way towards their average.
+const (
+	ratingPrior       = 3
+	ratingPriorWeight = 5
+)
+
+// clientRating returns the client rating of a service whose count reviews
+// add up to stars, rounded to two decimals, or 0 for a service without
+// reviews.
+func clientRating(stars, count int64) float64 {
+	if count == 0 {
+		return 0
+	}
+	rating := float64(ratingPrior*ratingPriorWeight+stars) / float64(ratingPriorWeight+count)
+	return math.Round(rating*100) / 100
+}
+
+// compareReviews orders reviews most recently updated first, then by ID.
+func compareReviews(a, b models.Review) int {
+	if c := b.UpdatedAt.Compare(a.UpdatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ReviewID.String(), b.ReviewID.String())
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"DirectoryService/models"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientRating(t *testing.T) {
+	assert.Zero(t, clientRating(0, 0), "no reviews, no rating")
+	assert.Equal(t, 3.33, clientRating(5, 1), "one review moves the rating a little")
+	assert.Equal(t, 4.95, clientRating(5*200, 200), "many reviews outweigh the prior")
+	assert.Equal(t, 1.05, clientRating(1*200, 200))
+}
+
+// testReviews checks that a store keeps one review per reviewer and keeps the
+// client rating and review count of the service in step with its reviews.
+func testReviews(t *testing.T, store Store) {
+	ctx := context.Background()
+	service, err := store.RegisterService(ctx, models.Service{Name: "Payments", ClientRating: 4.5})
+	require.NoError(t, err)
+	assert.Zero(t, service.ReviewCount)
+
+	rating := func() (float64, int64) {
+		t.Helper()
+		service, err := store.GetService(ctx, service.ServiceID)
+		require.NoError(t, err)
+		return service.ClientRating, service.ReviewCount
+	}
+
+	first, err := store.PutReview(
+		ctx, models.Review{ServiceID: service.ServiceID, Reviewer: "alice", Rating: 5},
+	)
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, first.ReviewID)
+	assert.Equal(t, "alice", first.Reviewer)
+	r, n := rating()
+	assert.Equal(t, 3.33, r, "the claimed rating is replaced by the reviews")
+	assert.Equal(t, int64(1), n)
+
+	_, err = store.PutReview(ctx, models.Review{ServiceID: service.ServiceID, Reviewer: "bob", Rating: 1})
+	require.NoError(t, err)
+	r, n = rating()
+	assert.Equal(t, 3.0, r)
+	assert.Equal(t, int64(2), n)
+
+	// Reviewing again edits the reviewer's review
+	edited, err := store.PutReview(
+		ctx, models.Review{ServiceID: service.ServiceID, Reviewer: "alice", Rating: 2, Comment: "Flaky"},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, first.ReviewID, edited.ReviewID)
+	assert.True(t, edited.CreatedAt.Equal(first.CreatedAt))
+	assert.False(t, edited.UpdatedAt.Before(first.UpdatedAt))
+	r, n = rating()
+	assert.Equal(t, 2.57, r)
+	assert.Equal(t, int64(2), n)
+
+	reviews, err := store.ListReviews(ctx, service.ServiceID)
+	require.NoError(t, err)
+	if assert.Len(t, reviews, 2) {
+		assert.Equal(t, "alice", reviews[0].Reviewer, "most recently updated first")
+		assert.Equal(t, 2, reviews[0].Rating)
+		assert.Equal(t, "Flaky", reviews[0].Comment)
+		assert.Equal(t, "bob", reviews[1].Reviewer)
+	}
+
+	// Updating the service leaves its rating to the reviews
+	service.ClientRating = 5
+	updated, err := store.UpdateService(ctx, *service)
+	require.NoError(t, err)
+	assert.Equal(t, 2.57, updated.ClientRating)
+	assert.Equal(t, int64(2), updated.ReviewCount)
+
+	_, err = store.PutReview(ctx, models.Review{ServiceID: uuid.New(), Reviewer: "alice", Rating: 4})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Reviews go with their service
+	require.NoError(t, store.DeleteService(ctx, service.ServiceID))
+	reviews, err = store.ListReviews(ctx, service.ServiceID)
+	require.NoError(t, err)
+	assert.Empty(t, reviews)
+}
+
+func TestMemStoreReviews(t *testing.T) {
+	testReviews(t, NewMemStore())
+}
+
+func TestSQLiteReviews(t *testing.T) {
+	testReviews(t, setupTestSQLite(t))
+}
@@ -2,25 +2,69 @@ package db
 
 import (
 	"DirectoryService/cfg"
+	"DirectoryService/industry"
 	"DirectoryService/models"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"math"
 	"time"
 )
 
+// querier is the subset of pgxpool.Pool and pgx.Tx used by DbCtx.
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 // DbCtx represents the database layer for the registry service.
 type DbCtx struct {
 	Pool *pgxpool.Pool
+	tx   pgx.Tx // set when the DbCtx is scoped to a transaction
 }
 
 // NewDbCtx creates a new DbCtx instance.
 func NewDbCtx(pool *pgxpool.Pool) *DbCtx {
-	return &DbCtx{pool}
+	return &DbCtx{Pool: pool}
+}
+
+// db returns the transaction when there is one, otherwise the pool.
+func (s *DbCtx) db() querier {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.Pool
 }
 
-// ConnectDB creates a connection pool to the PostgreSQL database
+// WithTx runs fn in a transaction. The transaction is committed when fn returns
+// nil and rolled back otherwise. Calls nested inside fn join the outer transaction.
+func (s *DbCtx) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	return pgx.BeginFunc(
+		ctx, s.Pool, func(tx pgx.Tx) error {
+			return fn(&DbCtx{Pool: s.Pool, tx: tx})
+		},
+	)
+}
+
+// Close closes the underlying connection pool. It is a no-op inside a transaction.
+func (s *DbCtx) Close() {
+	if s.tx != nil {
+		return
+	}
+	s.Pool.Close()
+}
+
+// ConnectDB creates a connection pool to the PostgreSQL database and, when
+// config.DB.AutoMigrate is set, applies any pending migrations.
 func ConnectDB(config *cfg.Config) (*pgxpool.Pool, error) {
 	connStr := fmt.Sprintf(
 		"postgres://%s:%s@%s:%d/%s?sslmode=%s",
@@ -33,146 +77,348 @@ func ConnectDB(config *cfg.Config) (*pgxpool.Pool, error) {
 		return nil, fmt.Errorf("unable to connect to database: %w", err)
 	}
 
+	if config.DB.AutoMigrate {
+		if err := migrateUp(context.Background(), NewDbCtx(pool)); err != nil {
+			pool.Close()
+			return nil, err
+		}
+	}
+
 	return pool, nil
 }
 
+// pgErr translates pgx errors into the Store's sentinel errors, which leave
+// the PostgreSQL message out.
+func pgErr(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pgError *pgconn.PgError
+	if errors.As(err, &pgError) {
+		switch pgError.Code {
+		case "23505": // unique_violation
+			return &dbError{ErrConflict, err}
+		case "23503": // foreign_key_violation
+			return &dbError{ErrReference, err}
+		}
+	}
+
+	return err
+}
+
+// isForeignKeyViolation reports whether err is a PostgreSQL foreign_key_violation.
+func isForeignKeyViolation(err error) bool {
+	var pgError *pgconn.PgError
+	return errors.As(err, &pgError) && pgError.Code == "23503"
+}
+
+// serviceColumns lists the r1.services columns in the order scanService reads them.
+const serviceColumns = `service_id, name, description, owner_info, industry_category, client_rating,
+	health_check, created_at, updated_at, transaction_count, avg_response_time, tags, labels,
+	review_count`
+
+// scanService scans a row selected with serviceColumns.
+func scanService(row pgx.Row) (*models.Service, error) {
+	var service models.Service
+	var healthCheck, labels []byte
+
+	err := row.Scan(
+		&service.ServiceID, &service.Name, &service.Description, &service.OwnerInfo,
+		&service.IndustryCategory, &service.ClientRating, &healthCheck, &service.CreatedAt,
+		&service.UpdatedAt, &service.TransactionCount, &service.AvgResponseTime, &service.Tags,
+		&labels, &service.ReviewCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decodeJSON(healthCheck, &service.HealthCheck); err != nil {
+		return nil, err
+	}
+	if err := decodeJSON(labels, &service.Labels); err != nil {
+		return nil, err
+	}
+	service.IndustryCategoryName = industry.Title(service.IndustryCategory)
+
+	return &service, nil
+}
+
 // RegisterService inserts a new service into the database and returns the inserted service.
+// A new ServiceID is always generated, replacing any the caller set.
 func (s *DbCtx) RegisterService(ctx context.Context, service models.Service) (
 	*models.Service, error,
 ) {
 	service.ServiceID = uuid.New()
+	service = withCategory(service)
+	service.Tags = normalizeTags(service.Tags)
 	service.CreatedAt = time.Now().UTC()
+	service.UpdatedAt = service.CreatedAt
+
+	healthCheck, err := encodeJSON(service.HealthCheck)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert service: %w", err)
+	}
+	labels, err := encodeJSON(service.Labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert service: %w", err)
+	}
 
 	query := `
 		INSERT INTO r1.services (service_id, name, description, owner_info, industry_category, 
-								 client_rating, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING service_id, name, description, owner_info, industry_category, client_rating, 
-				  created_at, updated_at
-	`
-
-	var newService models.Service
-	err := s.Pool.QueryRow(
-		ctx, query, service.ServiceID, service.Name, service.Description,
-		service.OwnerInfo, service.IndustryCategory, service.ClientRating, service.CreatedAt,
-		service.UpdatedAt,
-	).Scan(
-		&newService.ServiceID, &newService.Name, &newService.Description, &newService.OwnerInfo,
-		&newService.IndustryCategory, &newService.ClientRating, &newService.CreatedAt,
-		&newService.UpdatedAt,
+								 client_rating, health_check, created_at, updated_at,
+								 transaction_count, avg_response_time, tags, labels)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING ` + serviceColumns
+
+	newService, err := scanService(
+		s.db().QueryRow(
+			ctx, query, service.ServiceID, service.Name, service.Description,
+			service.OwnerInfo, service.IndustryCategory, service.ClientRating, healthCheck,
+			service.CreatedAt, service.UpdatedAt, service.TransactionCount, service.AvgResponseTime,
+			service.Tags, labels,
+		),
 	)
-
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert service: %w", err)
+		return nil, fmt.Errorf("failed to insert service: %w", pgErr(err))
 	}
 
-	return &newService, nil
+	return newService, nil
 }
 
 // UpdateService updates the service details and returns the updated service.
 func (s *DbCtx) UpdateService(ctx context.Context, service models.Service) (
 	*models.Service, error,
 ) {
-	service.ServiceID = uuid.New()
-	service.CreatedAt = time.Now().UTC()
+	service = withCategory(service)
+
+	healthCheck, err := encodeJSON(service.HealthCheck)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update service: %w", err)
+	}
+	labels, err := encodeJSON(service.Labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update service: %w", err)
+	}
 
 	query := `
 		UPDATE r1.services
-		SET name = $1, description = $2, owner_info = $3, industry_category = $4, client_rating = $5, updated_at = CURRENT_TIMESTAMP
-		WHERE service_id = $6
-		RETURNING service_id, name, description, owner_info, industry_category, client_rating, created_at, updated_at
-	`
-
-	var updatedService models.Service
-	err := s.Pool.QueryRow(
-		ctx, query, service.Name, service.Description, service.OwnerInfo, service.IndustryCategory,
-		service.ClientRating, service.ServiceID,
-	).Scan(
-		&updatedService.ServiceID, &updatedService.Name, &updatedService.Description,
-		&updatedService.OwnerInfo,
-		&updatedService.IndustryCategory, &updatedService.ClientRating, &updatedService.CreatedAt,
-		&updatedService.UpdatedAt,
+		SET name = $1, description = $2, owner_info = $3, industry_category = $4, health_check = $5,
+			tags = $6, labels = $7, updated_at = CURRENT_TIMESTAMP
+		WHERE service_id = $8
+		RETURNING ` + serviceColumns
+
+	updatedService, err := scanService(
+		s.db().QueryRow(
+			ctx, query, service.Name, service.Description, service.OwnerInfo,
+			service.IndustryCategory, healthCheck, normalizeTags(service.Tags), labels,
+			service.ServiceID,
+		),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update service: %w", err)
+		return nil, fmt.Errorf("failed to update service: %w", pgErr(err))
 	}
 
-	return &updatedService, nil
+	return updatedService, nil
 }
 
 // GetService retrieves a service by ID.
 func (s *DbCtx) GetService(ctx context.Context, serviceID uuid.UUID) (*models.Service, error) {
 	query := `
-		SELECT service_id, name, description, owner_info, industry_category, client_rating
+		SELECT ` + serviceColumns + `
 		FROM r1.services
 		WHERE service_id = $1
 	`
 
-	var service models.Service
-
-	err := s.Pool.QueryRow(ctx, query, serviceID).Scan(
-		&service.ServiceID, &service.Name, &service.Description, &service.OwnerInfo,
-		&service.IndustryCategory, &service.ClientRating,
-	)
+	service, err := scanService(s.db().QueryRow(ctx, query, serviceID))
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve service: %w", err)
+		return nil, fmt.Errorf("failed to retrieve service: %w", pgErr(err))
 	}
 
-	return &service, nil
+	return service, nil
 }
 
-// GetAllServices retrieves all services from the database.
-func (s *DbCtx) GetAllServices(ctx context.Context) ([]models.Service, error) {
+// GetServiceForUpdate retrieves a service by ID, locking its row until the
+// transaction ends.
+func (s *DbCtx) GetServiceForUpdate(ctx context.Context, serviceID uuid.UUID) (
+	*models.Service, error,
+) {
 	query := `
-		SELECT service_id, name, description, owner_info, industry_category, client_rating
+		SELECT ` + serviceColumns + `
 		FROM r1.services
+		WHERE service_id = $1
+		FOR UPDATE
 	`
 
-	rows, err := s.Pool.Query(ctx, query)
+	service, err := scanService(s.db().QueryRow(ctx, query, serviceID))
 	if err != nil {
-		return nil, fmt.Errorf("failed to query services: %w", err)
+		return nil, fmt.Errorf("failed to retrieve service: %w", pgErr(err))
 	}
-	defer rows.Close()
 
-	var services []models.Service
-	for rows.Next() {
-		var service models.Service
-		if err := rows.Scan(
-			&service.ServiceID, &service.Name, &service.Description, &service.OwnerInfo,
-			&service.IndustryCategory, &service.ClientRating,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
-		}
-
-		services = append(services, service)
-	}
+	return service, nil
+}
 
-	return services, nil
+// GetAllServices retrieves all services from the database.
+//
+// Deprecated: use ListServices with a zero ServiceQuery.
+func (s *DbCtx) GetAllServices(ctx context.Context) ([]models.Service, error) {
+	return s.ListServices(ctx, ServiceQuery{})
 }
 
-// DeleteService deletes a service by ID.
+// DeleteService deletes a service by ID. It returns ErrNotFound when there is no such
+// service and ErrConflict while the service still has instances.
 func (s *DbCtx) DeleteService(ctx context.Context, serviceID uuid.UUID) error {
 	query := `
 		DELETE FROM r1.services
 		WHERE service_id = $1
 	`
 
-	_, err := s.Pool.Exec(ctx, query, serviceID)
+	tag, err := s.db().Exec(ctx, query, serviceID)
+	if isForeignKeyViolation(err) {
+		return fmt.Errorf("failed to delete service: %w: service still has instances", ErrConflict)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to delete service: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete service: %w", ErrNotFound)
+	}
 
 	return nil
 }
 
-// ListServices retrieves all services from the database.
-func (s *DbCtx) ListServices(ctx context.Context) ([]models.Service, error) {
+// reviewColumns lists the r1.service_reviews columns in the order scanReview
+// reads them.
+const reviewColumns = `review_id, service_id, reviewer, rating, COALESCE(review, ''), created_at,
+	updated_at`
+
+// scanReview scans a row selected with reviewColumns.
+func scanReview(row pgx.Row) (*models.Review, error) {
+	var review models.Review
+	err := row.Scan(
+		&review.ReviewID, &review.ServiceID, &review.Reviewer, &review.Rating, &review.Comment,
+		&review.CreatedAt, &review.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &review, nil
+}
+
+// PutReview stores the review and recomputes the service's client rating in
+// one transaction.
+func (s *DbCtx) PutReview(ctx context.Context, review models.Review) (*models.Review, error) {
+	var stored *models.Review
+	err := s.WithTx(
+		ctx, func(tx Store) error {
+			var err error
+			stored, err = tx.(*DbCtx).putReview(ctx, review)
+			return err
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store review: %w", err)
+	}
+
+	return stored, nil
+}
+
+func (s *DbCtx) putReview(ctx context.Context, review models.Review) (*models.Review, error) {
+	// lock the service row so concurrent reviews of it serialise
+	var serviceID uuid.UUID
+	err := s.db().QueryRow(
+		ctx, `SELECT service_id FROM r1.services WHERE service_id = $1 FOR UPDATE`, review.ServiceID,
+	).Scan(&serviceID)
+	if err != nil {
+		return nil, pgErr(err)
+	}
+
 	query := `
-		SELECT service_id, name, description, owner_info, industry_category, client_rating
-		FROM r1.services
+		INSERT INTO r1.service_reviews (review_id, service_id, reviewer, rating, review, created_at,
+										updated_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (service_id, reviewer) DO UPDATE
+		SET rating = excluded.rating, review = excluded.review, updated_at = CURRENT_TIMESTAMP
+		RETURNING ` + reviewColumns
+
+	stored, err := scanReview(
+		s.db().QueryRow(
+			ctx, query, uuid.New(), review.ServiceID, review.Reviewer, review.Rating, review.Comment,
+		),
+	)
+	if err != nil {
+		return nil, pgErr(err)
+	}
+
+	var stars, count int64
+	err = s.db().QueryRow(
+		ctx, `SELECT COALESCE(SUM(rating), 0), COUNT(*) FROM r1.service_reviews WHERE service_id = $1`,
+		review.ServiceID,
+	).Scan(&stars, &count)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db().Exec(
+		ctx, `UPDATE r1.services SET client_rating = $1, review_count = $2 WHERE service_id = $3`,
+		clientRating(stars, count), count, review.ServiceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// ListReviews retrieves the reviews of a service, most recently updated first.
+func (s *DbCtx) ListReviews(ctx context.Context, serviceID uuid.UUID) ([]models.Review, error) {
+	query := `
+		SELECT ` + reviewColumns + `
+		FROM r1.service_reviews
+		WHERE service_id = $1
+		ORDER BY updated_at DESC, review_id
 	`
 
-	rows, err := s.Pool.Query(ctx, query)
+	rows, err := s.db().Query(ctx, query, serviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reviews: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := make([]models.Review, 0)
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan review: %w", err)
+		}
+		reviews = append(reviews, *review)
+	}
+
+	return reviews, rows.Err()
+}
+
+// ListServices retrieves the services selected by query from the database.
+func (s *DbCtx) ListServices(ctx context.Context, query ServiceQuery) ([]models.Service, error) {
+	if err := query.check(); err != nil {
+		return nil, fmt.Errorf("failed to query services: %w", err)
+	}
+
+	where, order, args := query.sql(postgresDialect)
+	return s.queryServices(
+		ctx, `
+		SELECT `+serviceColumns+`
+		FROM r1.services
+		`+where+`
+		`+order, args...,
+	)
+}
+
+// queryServices runs a query selecting serviceColumns and collects the rows.
+func (s *DbCtx) queryServices(ctx context.Context, query string, args ...any) (
+	[]models.Service, error,
+) {
+	rows, err := s.db().Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query services: %w", err)
 	}
@@ -180,57 +426,160 @@ func (s *DbCtx) ListServices(ctx context.Context) ([]models.Service, error) {
 
 	var services []models.Service
 	for rows.Next() {
-		var service models.Service
-		if err := rows.Scan(
-			&service.ServiceID, &service.Name, &service.Description, &service.OwnerInfo,
-			&service.IndustryCategory, &service.ClientRating,
-		); err != nil {
+		service, err := scanService(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		services = append(services, service)
+		services = append(services, *service)
 	}
 
-	return services, nil
+	return services, rows.Err()
+}
+
+// SearchServices ranks the services matching text by relevance: the rank of
+// their search_vector against the words of text, plus the trigram similarity
+// of text to their search_text, which catches typos. The highlights come from
+// the scorer of the other backends.
+func (s *DbCtx) SearchServices(ctx context.Context, text string, limit int) (
+	[]models.SearchResult, error,
+) {
+	terms, err := parseSearch(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search services: %w", err)
+	}
+
+	// plainto_tsquery requires every word; any of them is a match
+	query := `
+		WITH search AS (
+			SELECT replace(plainto_tsquery('english', $1)::text, '&', '|')::tsquery AS words,
+				   lower($1) AS phrase
+		)
+		SELECT ` + serviceColumns + `,
+			ts_rank(search_vector, search.words) + word_similarity(search.phrase, search_text) AS score
+		FROM r1.services, search
+		WHERE search_vector @@ search.words OR search.phrase <% search_text
+		ORDER BY score DESC, name, service_id`
+	args := []any{text}
+	if limit > 0 {
+		query += " LIMIT $2"
+		args = append(args, limit)
+	}
+
+	rows, err := s.db().Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search services: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	for rows.Next() {
+		var score float64
+		service, err := scanService(scoredRow{rows, &score})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		_, highlights := scoreService(*service, terms)
+		results = append(
+			results, models.SearchResult{
+				Service: *service, Score: math.Round(score*1000) / 1000, Highlights: highlights,
+			},
+		)
+	}
+
+	return results, rows.Err()
+}
+
+// scoredRow scans a row selected with serviceColumns followed by a score.
+type scoredRow struct {
+	pgx.Row
+	score *float64
+}
+
+func (r scoredRow) Scan(dest ...any) error {
+	return r.Row.Scan(append(dest, r.score)...)
+}
+
+// instanceColumns lists the r1.service_instances columns in the order scanInstance reads them.
+const instanceColumns = `service_id, instance_id, version, host, port, url, api_spec, latitude,
+	longitude, health_status, created_at, last_checked, lease_ttl_ms, lease_expires_at, scheme,
+	base_path, endpoints, labels`
+
+// scanInstance scans a row selected with instanceColumns.
+func scanInstance(row pgx.Row) (*models.ServiceInstance, error) {
+	var serviceInstance models.ServiceInstance
+	var leaseTTLMs int64
+	var endpoints, labels []byte
+
+	err := row.Scan(
+		&serviceInstance.ServiceID, &serviceInstance.InstanceID, &serviceInstance.Version,
+		&serviceInstance.Host,
+		&serviceInstance.Port, &serviceInstance.Url, &serviceInstance.ApiSpec,
+		&serviceInstance.Latitude, &serviceInstance.Longitude,
+		&serviceInstance.HealthStatus, &serviceInstance.CreatedAt, &serviceInstance.LastChecked,
+		&leaseTTLMs, &serviceInstance.LeaseExpiresAt, &serviceInstance.Scheme,
+		&serviceInstance.BasePath, &endpoints, &labels,
+	)
+	if err != nil {
+		return nil, err
+	}
+	serviceInstance.LeaseTTL = models.Duration(time.Duration(leaseTTLMs) * time.Millisecond)
+	if err := decodeJSON(endpoints, &serviceInstance.Endpoints); err != nil {
+		return nil, err
+	}
+	if err := decodeJSON(labels, &serviceInstance.Labels); err != nil {
+		return nil, err
+	}
+
+	return &serviceInstance, nil
 }
 
 // Create a new ServiceInstance in the database.
 func (s *DbCtx) CreateServiceInstance(
 	ctx context.Context, instance models.ServiceInstance,
 ) (*models.ServiceInstance, error) {
+	if err := validateInstance(instance); err != nil {
+		return nil, fmt.Errorf("failed to create service instance: %w", err)
+	}
+
+	instance = withEndpoints(instance)
 	instance.InstanceID = uuid.New()
-	instance.HealthStatus = models.HealthStatus("starting")
+	instance.HealthStatus = models.HealthStarting
 	instance.CreatedAt = time.Now().UTC()
 	instance.LastChecked = time.Now().UTC()
+	instance.LeaseExpiresAt = leaseExpiry(instance.LeaseTTL, instance.CreatedAt)
+
+	endpoints, err := encodeJSON(instance.Endpoints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service instance: %w", err)
+	}
+	labels, err := encodeJSON(instance.Labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service instance: %w", err)
+	}
 
 	query := `
 		INSERT INTO r1.service_instances (
-			service_id, instance_id, version, host, port, url, api_spec, latitude, longitude, health_status, created_at, last_checked
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-		RETURNING service_id, instance_id, version, host, port, url, api_spec, latitude, longitude, health_status, created_at, last_checked
-	`
-
-	var newInstance models.ServiceInstance
-	var urlString string
-
-	err := s.Pool.QueryRow(
-		ctx, query, instance.ServiceID, instance.InstanceID, instance.Version, instance.Host,
-		instance.Port,
-		urlString,
-		instance.ApiSpec, instance.Latitude,
-		instance.Longitude,
-		instance.HealthStatus, instance.CreatedAt, instance.LastChecked,
-	).Scan(
-		&newInstance.ServiceID, &newInstance.InstanceID, &newInstance.Version, &newInstance.Host,
-		&newInstance.Port, &newInstance.Url, &newInstance.ApiSpec, &newInstance.Latitude,
-		&newInstance.Longitude, &newInstance.HealthStatus, &newInstance.CreatedAt,
-		&newInstance.LastChecked,
+			service_id, instance_id, version, host, port, url, api_spec, latitude, longitude, health_status, created_at, last_checked,
+			lease_ttl_ms, lease_expires_at, scheme, base_path, endpoints, labels
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		RETURNING ` + instanceColumns
+
+	newInstance, err := scanInstance(
+		s.db().QueryRow(
+			ctx, query, instance.ServiceID, instance.InstanceID, instance.Version, instance.Host,
+			instance.Port, instance.Url, instance.ApiSpec, instance.Latitude, instance.Longitude,
+			instance.HealthStatus, instance.CreatedAt, instance.LastChecked,
+			time.Duration(instance.LeaseTTL).Milliseconds(), instance.LeaseExpiresAt,
+			instance.Scheme, instance.BasePath, endpoints, labels,
+		),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create service instance: %w", err)
+		return nil, fmt.Errorf("failed to create service instance: %w", pgErr(err))
 	}
 
-	return &newInstance, nil
+	return newInstance, nil
 }
 
 // GetServiceInstance retrieves a instanceID.
@@ -239,50 +588,226 @@ func (s *DbCtx) GetServiceInstance(
 ) (*models.ServiceInstance, error) {
 	// create query to get service instance using InstanceID
 	query := `
-		SELECT service_id, instance_id, version, host, port, url, api_spec, latitude, longitude, health_status, created_at, last_checked
+		SELECT ` + instanceColumns + `
 		FROM r1.service_instances
 		WHERE instance_id = $1
 	`
 
-	var serviceInstance models.ServiceInstance
+	serviceInstance, err := scanInstance(s.db().QueryRow(ctx, query, instanceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve service instance: %w", pgErr(err))
+	}
 
-	err := s.Pool.QueryRow(ctx, query, instanceID).Scan(
-		&serviceInstance.ServiceID, &serviceInstance.InstanceID, &serviceInstance.Version,
-		&serviceInstance.Host,
-		&serviceInstance.Port, &serviceInstance.Url, &serviceInstance.ApiSpec,
-		&serviceInstance.Latitude, &serviceInstance.Longitude,
-		&serviceInstance.HealthStatus, &serviceInstance.CreatedAt, &serviceInstance.LastChecked,
-	)
+	return serviceInstance, nil
+}
 
+// ListServiceInstances retrieves the instances matching filter, oldest first.
+func (s *DbCtx) ListServiceInstances(
+	ctx context.Context, filter InstanceFilter,
+) ([]models.ServiceInstance, error) {
+	query := `
+		SELECT ` + instanceColumns + `
+		FROM r1.service_instances
+		WHERE ($1::uuid IS NULL OR service_id = $1)
+		  AND ($2 = '' OR version = $2)
+		  AND ($3 = '' OR health_status = $3)
+		  AND ($4 = '' OR host = $4)
+	`
+
+	var serviceID *uuid.UUID
+	if filter.ServiceID != uuid.Nil {
+		serviceID = &filter.ServiceID
+	}
+	args := []any{serviceID, filter.Version, string(filter.HealthStatus), filter.Host}
+	query += filter.labelSQL(postgresDialect, &args) + `
+		ORDER BY created_at, instance_id`
+
+	rows, err := s.db().Query(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve service: %w", err)
+		return nil, fmt.Errorf("failed to query service instances: %w", err)
 	}
+	defer rows.Close()
 
-	return &serviceInstance, nil
+	instances := make([]models.ServiceInstance, 0)
+	for rows.Next() {
+		serviceInstance, err := scanInstance(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		instances = append(instances, *serviceInstance)
+	}
+
+	return instances, rows.Err()
 }
 
-// RemoveServiceInstance - copies pertinent columns from ServiceInstance to service_instance_history and inserts new row before it deletes the service instance by ID.
+// UpdateServiceInstanceHealth records the outcome of a health check.
+func (s *DbCtx) UpdateServiceInstanceHealth(
+	ctx context.Context, instanceID uuid.UUID, status models.HealthStatus, checkedAt time.Time,
+) (*models.ServiceInstance, error) {
+	query := `
+		UPDATE r1.service_instances
+		SET health_status = $1, last_checked = $2
+		WHERE instance_id = $3
+		RETURNING ` + instanceColumns
+
+	serviceInstance, err := scanInstance(
+		s.db().QueryRow(ctx, query, string(status), checkedAt, instanceID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update service instance health: %w", pgErr(err))
+	}
+
+	return serviceInstance, nil
+}
+
+// RemoveServiceInstance - copies pertinent columns from ServiceInstance to service_instance_history
+// and inserts new row before it deletes the service instance by ID. Both steps run in one
+// transaction with the instance row locked, so a crash cannot leave duplicates or orphans.
 func (s *DbCtx) RemoveServiceInstance(ctx context.Context, instanceID uuid.UUID) error {
+	return s.WithTx(
+		ctx, func(tx Store) error {
+			return tx.(*DbCtx).removeServiceInstance(ctx, instanceID, ReasonDeregistered)
+		},
+	)
+}
+
+// RenewLease extends the instance's lease to now plus its TTL.
+func (s *DbCtx) RenewLease(
+	ctx context.Context, instanceID uuid.UUID, ttl time.Duration, now time.Time,
+) (*models.ServiceInstance, error) {
+	if ttl < 0 {
+		return nil, fmt.Errorf("failed to renew lease: %w: ttl must not be negative", ErrInvalid)
+	}
 
-	// call GetServiceInstance to retrieve the service instance by ID
-	serviceInstance, err := s.GetServiceInstance(ctx, instanceID)
+	// a zero ttl keeps the stored TTL; instances without one are left untouched
+	query := `
+		UPDATE r1.service_instances
+		SET lease_ttl_ms = COALESCE(NULLIF($1::bigint, 0), lease_ttl_ms),
+		    lease_expires_at = $2::timestamptz +
+		        COALESCE(NULLIF($1::bigint, 0), lease_ttl_ms) * interval '1 millisecond'
+		WHERE instance_id = $3 AND COALESCE(NULLIF($1::bigint, 0), lease_ttl_ms) > 0
+		RETURNING ` + instanceColumns
+
+	serviceInstance, err := scanInstance(
+		s.db().QueryRow(ctx, query, ttl.Milliseconds(), now, instanceID),
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := s.GetServiceInstance(ctx, instanceID); err != nil {
+			return nil, fmt.Errorf("failed to renew lease: %w", err)
+		}
+		return nil, fmt.Errorf("failed to renew lease: %w: instance has no lease ttl", ErrInvalid)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get service instance: %w", err)
+		return nil, fmt.Errorf("failed to renew lease: %w", pgErr(err))
 	}
 
-	// User serviceIntance to insert into service_instance_history
+	return serviceInstance, nil
+}
+
+// ExpireLeases archives every instance whose lease expired before now. Rows
+// locked by a concurrent heartbeat or removal are skipped until the next pass.
+func (s *DbCtx) ExpireLeases(ctx context.Context, now time.Time) (
+	[]models.ServiceInstance, error,
+) {
+	expired := make([]models.ServiceInstance, 0)
+	err := s.WithTx(
+		ctx, func(tx Store) error {
+			pg := tx.(*DbCtx)
+			rows, err := pg.db().Query(
+				ctx, `
+				SELECT instance_id FROM r1.service_instances
+				WHERE lease_expires_at < $1
+				ORDER BY lease_expires_at
+				FOR UPDATE SKIP LOCKED`, now,
+			)
+			if err != nil {
+				return err
+			}
+			ids, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+			if err != nil {
+				return err
+			}
+
+			for _, id := range ids {
+				instance, err := pg.GetServiceInstance(ctx, id)
+				if err != nil {
+					return err
+				}
+				if err := pg.removeServiceInstance(ctx, id, ReasonLeaseExpired); err != nil {
+					return err
+				}
+				expired = append(expired, *instance)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire leases: %w", err)
+	}
+
+	return expired, nil
+}
+
+// Index returns the registry index.
+func (s *DbCtx) Index(ctx context.Context) (uint64, error) {
+	var index uint64
+	err := s.db().QueryRow(ctx, `SELECT value FROM r1.registry_index`).Scan(&index)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read registry index: %w", pgErr(err))
+	}
+
+	return index, nil
+}
+
+// AdvanceIndex adds n to the registry index and returns the new value. The
+// row stays locked until the transaction ends, so concurrent writers number
+// their changes in commit order.
+func (s *DbCtx) AdvanceIndex(ctx context.Context, n uint64) (uint64, error) {
+	var index uint64
+	err := s.db().QueryRow(
+		ctx, `UPDATE r1.registry_index SET value = value + $1 RETURNING value`, n,
+	).Scan(&index)
+	if err != nil {
+		return 0, fmt.Errorf("failed to advance registry index: %w", pgErr(err))
+	}
+
+	return index, nil
+}
+
+// removeServiceInstance archives and deletes the instance, recording reason in the history
+// metrics; the caller provides the transaction.
+func (s *DbCtx) removeServiceInstance(
+	ctx context.Context, instanceID uuid.UUID, reason string,
+) error {
+	// lock the service instance row so concurrent removals serialise
 	query := `
+		SELECT service_id, instance_id, version, url, health_status, created_at
+		FROM r1.service_instances
+		WHERE instance_id = $1
+		FOR UPDATE
+	`
+
+	var serviceInstance models.ServiceInstance
+	err := s.db().QueryRow(ctx, query, instanceID).Scan(
+		&serviceInstance.ServiceID, &serviceInstance.InstanceID, &serviceInstance.Version,
+		&serviceInstance.Url, &serviceInstance.HealthStatus, &serviceInstance.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get service instance: %w", pgErr(err))
+	}
+
+	// User serviceIntance to insert into service_instance_history
+	query = `
 	  INSERT INTO r1.service_instance_history (		
 		service_id, instance_id, version, url, metrics, started_at, stopped_at
 	  ) VALUES ($1, $2, $3, $4, $5, $6, $7)
 	  RETURNING history_id
 `
 	var historyID uuid.UUID
-	// create a map to hold the Metrics
-	metrics := make(map[string]interface{})
-	metrics["health_status"] = serviceInstance.HealthStatus
+	metrics := archiveMetrics(serviceInstance, reason)
 
-	err = s.Pool.QueryRow(
+	err = s.db().QueryRow(
 		ctx, query, serviceInstance.ServiceID, serviceInstance.InstanceID,
 		serviceInstance.Version, serviceInstance.Url, metrics, serviceInstance.CreatedAt,
 		time.Now(),
@@ -298,7 +823,7 @@ func (s *DbCtx) RemoveServiceInstance(ctx context.Context, instanceID uuid.UUID)
   WHERE instance_id = $1
  `
 
-	_, err = s.Pool.Exec(ctx, deleteQuery, instanceID)
+	_, err = s.db().Exec(ctx, deleteQuery, instanceID)
 	if err != nil {
 		return fmt.Errorf("failed to delete service instance: %w", err)
 	}
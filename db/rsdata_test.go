@@ -2,10 +2,11 @@ package db
 
 import (
 	"DirectoryService/cfg"
+	"DirectoryService/labels"
 	"DirectoryService/models"
 	"context"
-	"net/url"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -50,7 +51,7 @@ func TestInsertService(t *testing.T) {
 	insertedService, err := rs.RegisterService(context.Background(), service)
 	assert.NoError(t, err, "RegisterService should not return an error")
 	assert.NotNil(t, insertedService, "RegisterService should return the inserted service")
-	assert.Equal(t, service.ServiceID, insertedService.ServiceID, "ServiceID should match")
+	assert.NotEqual(t, service.ServiceID, insertedService.ServiceID, "ServiceID should be generated")
 }
 
 func TestUpdateService(t *testing.T) {
@@ -73,7 +74,8 @@ func TestUpdateService(t *testing.T) {
 	insertedService.Description = "An updated test service"
 	insertedService.OwnerInfo = "Updated Owner"
 	insertedService.IndustryCategory = "Updated Category"
-	insertedService.ClientRating = 4.8
+	insertedService.ClientRating = 4.8   // reviews own the rating, so this is ignored
+	insertedService.TransactionCount = 7 // and the registry the statistics
 
 	updatedService, err := rs.UpdateService(context.Background(), *insertedService)
 	assert.NoError(t, err, "UpdateService should not return an error")
@@ -87,7 +89,8 @@ func TestUpdateService(t *testing.T) {
 	assert.Equal(
 		t, "Updated Category", updatedService.IndustryCategory, "IndustryCategory should match",
 	)
-	assert.Equal(t, 4.8, updatedService.ClientRating, "ClientRating should match")
+	assert.Equal(t, 4.5, updatedService.ClientRating, "ClientRating should be unchanged")
+	assert.Zero(t, updatedService.TransactionCount, "TransactionCount should be unchanged")
 }
 
 // test to GetAllServices
@@ -116,6 +119,49 @@ func TestGetService(t *testing.T) {
 	assert.Equal(t, serviceID, service.ServiceID, "ServiceID should match")
 }
 
+func TestGetServiceForUpdate(t *testing.T) {
+	rs := setupTestDB(t)
+	defer rs.Pool.Close()
+
+	ctx := context.Background()
+	service, err := rs.RegisterService(ctx, models.Service{Name: "Locked Service"})
+	assert.NoError(t, err)
+	defer rs.DeleteService(ctx, service.ServiceID)
+
+	// A second transaction waits for the lock of the first
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_ = rs.WithTx(
+			ctx, func(tx Store) error {
+				_, err := tx.GetServiceForUpdate(ctx, service.ServiceID)
+				close(locked)
+				<-release
+				return err
+			},
+		)
+	}()
+	<-locked
+
+	waitCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	err = rs.WithTx(
+		waitCtx, func(tx Store) error {
+			_, err := tx.GetServiceForUpdate(waitCtx, service.ServiceID)
+			return err
+		},
+	)
+	assert.Error(t, err, "GetServiceForUpdate should wait for the other transaction")
+	close(release)
+
+	stored, err := rs.GetServiceForUpdate(ctx, service.ServiceID)
+	assert.NoError(t, err)
+	assert.Equal(t, service.ServiceID, stored.ServiceID)
+
+	_, err = rs.GetServiceForUpdate(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
 func TestDeleteService(t *testing.T) {
 	rs := setupTestDB(t)
 	defer rs.Pool.Close()
@@ -123,18 +169,49 @@ func TestDeleteService(t *testing.T) {
 	serviceID := uuid.New()
 
 	err := rs.DeleteService(context.Background(), serviceID)
-	assert.NoError(t, err, "DeleteService should not return an error")
+	assert.ErrorIs(t, err, ErrNotFound, "DeleteService should report an unknown service")
 }
 
 func TestListServices(t *testing.T) {
 	rs := setupTestDB(t)
 	defer rs.Pool.Close()
 
-	services, err := rs.ListServices(context.Background())
+	services, err := rs.ListServices(context.Background(), ServiceQuery{})
 	assert.NoError(t, err, "ListServices should not return an error")
 	assert.NotEmpty(t, services, "ListServices should return a list of services")
 }
 
+func TestListServicesLabelSelector(t *testing.T) {
+	rs := setupTestDB(t)
+	defer rs.Pool.Close()
+
+	team := uuid.NewString()
+	inserted, err := rs.RegisterService(
+		context.Background(), models.Service{Name: "Labelled Service", Labels: map[string]string{"team": team}},
+	)
+	assert.NoError(t, err, "RegisterService should not return an error")
+	defer rs.DeleteService(context.Background(), inserted.ServiceID)
+
+	selector, err := labels.Parse("team=" + team + ",!canary")
+	assert.NoError(t, err)
+	services, err := rs.ListServices(context.Background(), ServiceQuery{Labels: selector})
+	assert.NoError(t, err, "ListServices should not return an error")
+	if assert.Len(t, services, 1, "ListServices should select the labelled service") {
+		assert.Equal(t, inserted.ServiceID, services[0].ServiceID)
+	}
+}
+
+func TestSearchServices(t *testing.T) {
+	rs := setupTestDB(t)
+	defer rs.Pool.Close()
+
+	_, err := rs.SearchServices(context.Background(), "service", 10)
+	assert.NoError(t, err, "SearchServices should not return an error")
+
+	_, err = rs.SearchServices(context.Background(), "the", 10)
+	assert.ErrorIs(t, err, ErrInvalid, "SearchServices should reject a search without words")
+}
+
 func TestCreateServiceInstance(t *testing.T) {
 	rs := setupTestDB(t)
 	defer rs.Pool.Close()
@@ -158,10 +235,7 @@ func TestCreateServiceInstance(t *testing.T) {
 		Host:      "localhost",
 		Port:      8080,
 		Version:   "1.0.0",
-		Url: url.URL{
-			Scheme: "http",
-			Host:   "localhost:8080",
-		},
+		Url:       "http://localhost:8080",
 		Latitude:  0.0,
 		Longitude: 0.0,
 		ApiSpec:   "test",
@@ -171,4 +245,42 @@ func TestCreateServiceInstance(t *testing.T) {
 	assert.NoError(t, err, "CreateServiceInstance should not return an error")
 	assert.NotNil(t, newInstance, "CreateServiceInstance should return an instance")
 	assert.Equal(t, insertedService.ServiceID, newInstance.ServiceID, "ServiceID should match")
+	assert.Equal(t, "http://localhost:8080", newInstance.Url, "Url should be stored")
+	assert.Equal(t, "http://localhost:8080", newInstance.Endpoints.HTTP, "the URL is the http endpoint")
+}
+
+func TestCreateServiceInstanceUnknownService(t *testing.T) {
+	rs := setupTestDB(t)
+	defer rs.Pool.Close()
+
+	_, err := rs.CreateServiceInstance(
+		context.Background(), models.ServiceInstance{ServiceID: uuid.New(), Version: "1.0.0"},
+	)
+	assert.ErrorIs(t, err, ErrReference, "unknown service should return ErrReference")
+	assert.NotContains(t, err.Error(), "violates", "the PostgreSQL message stays out of the error")
+	assert.ErrorContains(t, Cause(err), "violates", "the cause keeps it for logging")
+}
+
+func TestReviews(t *testing.T) {
+	rs := setupTestDB(t)
+	defer rs.Pool.Close()
+
+	testReviews(t, rs)
+}
+
+func TestRegistryIndex(t *testing.T) {
+	rs := setupTestDB(t)
+	defer rs.Pool.Close()
+	ctx := context.Background()
+
+	before, err := rs.Index(ctx)
+	assert.NoError(t, err, "Index should not return an error")
+
+	index, err := rs.AdvanceIndex(ctx, 2)
+	assert.NoError(t, err, "AdvanceIndex should not return an error")
+	assert.Equal(t, before+2, index)
+
+	after, err := rs.Index(ctx)
+	assert.NoError(t, err, "Index should not return an error")
+	assert.Equal(t, index, after)
 }
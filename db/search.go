@@ -0,0 +1,296 @@
+package db
+
+import (
+	"DirectoryService/models"
+	"cmp"
+	"fmt"
+	"html"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchField is a service field that search looks at. The weights mirror
+// the default ts_rank weights of the tsvector the Postgres backend ranks by:
+// A = 1, B = 0.4, C = 0.2 and D = 0.1.
+type searchField struct {
+	name   string // JSON name, the key of the field's highlight
+	weight float64
+	value  func(models.Service) string
+}
+
+var searchFields = []searchField{
+	{"name", 1, func(s models.Service) string { return s.Name }},
+	{"tags", 1, func(s models.Service) string { return strings.Join(s.Tags, ", ") }},
+	{"industry_category", 0.4, func(s models.Service) string { return s.IndustryCategory }},
+	{"owner_info", 0.2, func(s models.Service) string { return s.OwnerInfo }},
+	{"description", 0.1, func(s models.Service) string { return s.Description }},
+}
+
+// snippetWords bounds the number of words in a highlight.
+const snippetWords = 24
+
+// stopWords are left out of searches, as Postgres leaves them out of its
+// English tsvectors.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "do": true, "does": true, "for": true, "from": true, "has": true,
+	"have": true, "how": true, "i": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "that": true, "the": true, "this": true, "to": true,
+	"was": true, "what": true, "which": true, "with": true,
+}
+
+// stemSuffixes are stripped, first match only, to reduce a word to its stem.
+var stemSuffixes = []string{
+	"ations", "ation", "ates", "ated", "ate", "ings", "ing", "ies", "ied", "es", "ed",
+	"ers", "er", "ly", "s",
+}
+
+// searchTerm is a word of a search.
+type searchTerm struct {
+	word, stem string
+}
+
+// searchToken is a word of a field and its byte offsets in the field.
+type searchToken struct {
+	word       string // lower case
+	start, end int
+}
+
+// parseSearch returns the distinct words of a search, leaving out stop
+// words. It fails with ErrInvalid when nothing is left to search for.
+func parseSearch(text string) ([]searchTerm, error) {
+	var terms []searchTerm
+	for _, token := range tokenize(text) {
+		term := searchTerm{word: token.word, stem: stem(token.word)}
+		if stopWords[term.word] || slices.Contains(terms, term) {
+			continue
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: the search has no words to look for", ErrInvalid)
+	}
+
+	return terms, nil
+}
+
+// tokenize splits text into lower case words of letters and digits.
+func tokenize(text string) []searchToken {
+	var tokens []searchToken
+	start := -1
+	for i, r := range text + " " {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, searchToken{word: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	return tokens
+}
+
+// stem strips a common English suffix from word so that forms of a word such
+// as "validate" and "validation" match.
+func stem(word string) string {
+	for _, suffix := range stemSuffixes {
+		base, ok := strings.CutSuffix(word, suffix)
+		if !ok || utf8.RuneCountInString(base) < 3 {
+			continue
+		}
+		switch suffix {
+		case "es":
+			// "addresses", but not "services"
+			if !strings.HasSuffix(base, "s") && !strings.HasSuffix(base, "x") &&
+				!strings.HasSuffix(base, "ch") && !strings.HasSuffix(base, "sh") {
+				continue
+			}
+		case "s":
+			// "address" and "status" are not plurals
+			if strings.HasSuffix(base, "s") || strings.HasSuffix(base, "u") {
+				continue
+			}
+		case "ies", "ied":
+			base += "y"
+		}
+		return base
+	}
+	return word
+}
+
+// match returns how well word matches the term: 1 for the same word or stem,
+// less for a prefix or a word with a typo, and 0 for no match.
+func (t searchTerm) match(word string) float64 {
+	if word == t.word || stem(word) == t.stem {
+		return 1
+	}
+	if utf8.RuneCountInString(t.word) >= 3 && strings.HasPrefix(word, t.word) {
+		return 0.8
+	}
+
+	allowed := typoAllowance(t.word)
+	if allowed == 0 {
+		return 0
+	}
+	d := min(editDistance(t.word, word, allowed), editDistance(t.stem, stem(word), allowed))
+	if d > allowed {
+		return 0
+	}
+	return 0.6 / float64(d)
+}
+
+// typoAllowance returns the number of typos tolerated in a word: none in
+// short words, where they would match unrelated words, and up to two in long
+// ones.
+func typoAllowance(word string) int {
+	switch n := utf8.RuneCountInString(word); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// and transpositions that turn a into b, or limit+1 when it exceeds limit.
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > limit || -d > limit {
+		return limit + 1
+	}
+
+	// rows i-2, i-1 and i of the optimal string alignment matrix
+	prev2, prev, row := make([]int, len(rb)+1), make([]int, len(rb)+1), make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		row[0] = i
+		best := row[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			row[j] = min(prev[j]+1, row[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				row[j] = min(row[j], prev2[j-2]+1)
+			}
+			best = min(best, row[j])
+		}
+		if best > limit {
+			return limit + 1
+		}
+		prev2, prev, row = prev, row, prev2
+	}
+
+	return min(prev[len(rb)], limit+1)
+}
+
+// scoreService rates how well service matches the terms, from 0 for no match
+// to 1 when every term names the service, and highlights the matching words
+// of each field. Every term adds its best match in any field, weighted by
+// the field.
+func scoreService(service models.Service, terms []searchTerm) (float64, map[string]string) {
+	best := make([]float64, len(terms))
+	var highlights map[string]string
+
+	for _, field := range searchFields {
+		text := field.value(service)
+		tokens := tokenize(text)
+		matched := make([]bool, len(tokens))
+		var hit bool
+
+		for i, token := range tokens {
+			for t, term := range terms {
+				if m := term.match(token.word); m > 0 {
+					best[t] = max(best[t], m*field.weight)
+					matched[i], hit = true, true
+				}
+			}
+		}
+
+		if hit {
+			if highlights == nil {
+				highlights = make(map[string]string)
+			}
+			highlights[field.name] = highlight(text, tokens, matched)
+		}
+	}
+
+	var score float64
+	for _, b := range best {
+		score += b
+	}
+	return math.Round(score/float64(len(terms))*1000) / 1000, highlights
+}
+
+// highlight returns the snippet of text around its first matched token, with
+// the matched tokens wrapped in <mark></mark> and the rest HTML escaped.
+func highlight(text string, tokens []searchToken, matched []bool) string {
+	first := slices.Index(matched, true)
+	from, to := 0, len(tokens)
+	if len(tokens) > snippetWords {
+		from = max(0, min(first-snippetWords/4, len(tokens)-snippetWords))
+		to = from + snippetWords
+	}
+
+	var b strings.Builder
+	pos := 0
+	if from > 0 {
+		b.WriteString("…")
+		pos = tokens[from].start
+	}
+	for i := from; i < to; i++ {
+		token := tokens[i]
+		b.WriteString(html.EscapeString(text[pos:token.start]))
+		if matched[i] {
+			b.WriteString("<mark>" + html.EscapeString(text[token.start:token.end]) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(text[token.start:token.end]))
+		}
+		pos = token.end
+	}
+	if to < len(tokens) {
+		b.WriteString("…")
+	} else {
+		b.WriteString(html.EscapeString(text[pos:]))
+	}
+
+	return b.String()
+}
+
+// rankServices scores the services against the terms and returns those that
+// match, best first, up to limit of them when limit is positive.
+func rankServices(services []models.Service, terms []searchTerm, limit int) []models.SearchResult {
+	var results []models.SearchResult
+	for _, service := range services {
+		score, highlights := scoreService(service, terms)
+		if score > 0 {
+			results = append(
+				results, models.SearchResult{Service: service, Score: score, Highlights: highlights},
+			)
+		}
+	}
+
+	slices.SortFunc(
+		results, func(a, b models.SearchResult) int {
+			return cmp.Or(
+				cmp.Compare(b.Score, a.Score),
+				strings.Compare(a.Name, b.Name),
+				strings.Compare(a.ServiceID.String(), b.ServiceID.String()),
+			)
+		},
+	)
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"DirectoryService/models"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStem(t *testing.T) {
+	for word, want := range map[string]string{
+		"validation": "valid",
+		"validate":   "valid",
+		"validates":  "valid",
+		"addresses":  "address",
+		"address":    "address",
+		"services":   "service",
+		"status":     "status",
+		"categories": "category",
+		"processing": "process",
+		"api":        "api",
+	} {
+		assert.Equal(t, want, stem(word), word)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("address", "address", 2))
+	assert.Equal(t, 1, editDistance("adress", "address", 2))
+	assert.Equal(t, 1, editDistance("adderss", "address", 2), "a transposition is one edit")
+	assert.Equal(t, 2, editDistance("validaton", "validtion", 2))
+	assert.Equal(t, 3, editDistance("payments", "shipping", 2), "capped at limit+1")
+}
+
+func TestParseSearch(t *testing.T) {
+	terms, err := parseSearch("Something that does Address validation, address!")
+	require.NoError(t, err)
+	assert.Equal(
+		t, []searchTerm{{"something", "someth"}, {"address", "address"}, {"validation", "valid"}},
+		terms,
+	)
+
+	_, err = parseSearch("the of and")
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestHighlight(t *testing.T) {
+	terms, _ := parseSearch("payment")
+	score, highlights := scoreService(
+		models.Service{Name: "Payments <API>", Tags: []string{"billing", "payment"}}, terms,
+	)
+	assert.Equal(t, 1.0, score)
+	assert.Equal(
+		t, map[string]string{
+			"name": "<mark>Payments</mark> &lt;API&gt;",
+			"tags": "billing, <mark>payment</mark>",
+		}, highlights,
+	)
+
+	// Long fields are cut to a snippet around the first match
+	_, highlights = scoreService(
+		models.Service{
+			Name: "Ledger",
+			Description: "one two three four five six seven eight nine ten payment eleven twelve " +
+				"thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty twentyone " +
+				"twentytwo twentythree twentyfour twentyfive twentysix twentyseven.",
+		}, terms,
+	)
+	assert.Equal(
+		t, "…five six seven eight nine ten <mark>payment</mark> eleven twelve thirteen fourteen "+
+			"fifteen sixteen seventeen eighteen nineteen twenty twentyone twentytwo twentythree "+
+			"twentyfour twentyfive twentysix twentyseven.", highlights["description"],
+	)
+}
+
+// testSearchServices checks the ranking, typo tolerance and highlights of a
+// store's SearchServices.
+func testSearchServices(t *testing.T, store Store) {
+	ctx := context.Background()
+	for _, service := range []models.Service{
+		{
+			Name:        "Address Validation",
+			Description: "Validates and normalizes postal addresses.",
+			OwnerInfo:   "Logistics team", IndustryCategory: "Logistics",
+		},
+		{
+			Name:        "Shipping Quotes",
+			Description: "Quotes shipping rates after validating the delivery address.",
+			OwnerInfo:   "Logistics team", IndustryCategory: "Logistics",
+		},
+		{
+			Name: "Ledger", Description: "Double entry bookkeeping.", Tags: []string{"payments", "billing"},
+			OwnerInfo: "Finance team", IndustryCategory: "Finance",
+		},
+	} {
+		_, err := store.RegisterService(ctx, service)
+		require.NoError(t, err)
+	}
+
+	names := func(results []models.SearchResult) []string {
+		var names []string
+		for _, result := range results {
+			names = append(names, result.Name)
+		}
+		return names
+	}
+
+	results, err := store.SearchServices(ctx, "something that does address validation", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Address Validation", "Shipping Quotes"}, names(results))
+	assert.Greater(t, results[0].Score, results[1].Score)
+	assert.Equal(t, "<mark>Address</mark> <mark>Validation</mark>", results[0].Highlights["name"])
+
+	// Typos still match
+	results, err = store.SearchServices(ctx, "adress validaton", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Address Validation", "Shipping Quotes"}, names(results))
+
+	// Tags are searched and the limit applies
+	results, err = store.SearchServices(ctx, "payment", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Ledger"}, names(results))
+	assert.Equal(t, "<mark>payments</mark>, billing", results[0].Highlights["tags"])
+
+	results, err = store.SearchServices(ctx, "kubernetes", 0)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	_, err = store.SearchServices(ctx, "the", 0)
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestMemStoreSearchServices(t *testing.T) {
+	testSearchServices(t, NewMemStore())
+}
+
+func TestSQLiteSearchServices(t *testing.T) {
+	testSearchServices(t, setupTestSQLite(t))
+}
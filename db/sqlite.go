@@ -0,0 +1,779 @@
+package db
+
+import (
+	"DirectoryService/cfg"
+	"DirectoryService/industry"
+	"DirectoryService/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqlQuerier is the subset of sql.DB and sql.Tx used by SQLiteStore.
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// SQLiteStore is the embedded SQLite implementation of Store.
+type SQLiteStore struct {
+	DB *sql.DB
+	tx *sql.Tx // set when the store is scoped to a transaction
+}
+
+// SQLiteStore must satisfy Store.
+var _ Store = (*SQLiteStore)(nil)
+
+// ConnectSQLite opens the SQLite database at config.DB.Path and, when
+// config.DB.AutoMigrate is set, applies any pending migrations.
+func ConnectSQLite(config *cfg.Config) (*SQLiteStore, error) {
+	path := config.DB.Path
+	if path == "" {
+		return nil, fmt.Errorf("db.path must be set for the %s driver", DriverSQLite)
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", path)
+	sqlDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sqlite database: %w", err)
+	}
+	// SQLite serialises writers; a single connection also keeps ":memory:"
+	// databases alive for the lifetime of the store.
+	sqlDB.SetMaxOpenConns(1)
+
+	store := &SQLiteStore{DB: sqlDB}
+	if config.DB.AutoMigrate {
+		if err := migrateUp(context.Background(), store); err != nil {
+			sqlDB.Close()
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// db returns the transaction when there is one, otherwise the database.
+func (s *SQLiteStore) db() sqlQuerier {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.DB
+}
+
+// WithTx runs fn in a transaction. The transaction is committed when fn returns
+// nil and rolled back otherwise. Calls nested inside fn join the outer transaction.
+func (s *SQLiteStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(&SQLiteStore{DB: s.DB, tx: tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the underlying database. It is a no-op inside a transaction.
+func (s *SQLiteStore) Close() {
+	if s.tx != nil {
+		return
+	}
+	s.DB.Close()
+}
+
+// sqliteServiceColumns lists the services columns in the order scanSQLiteService reads them.
+const sqliteServiceColumns = `service_id, name, description, owner_info, industry_category,
+	client_rating, health_check, created_at, updated_at, transaction_count, avg_response_time, tags,
+	labels, review_count`
+
+// sqlRow is implemented by sql.Row and sql.Rows.
+type sqlRow interface {
+	Scan(dest ...any) error
+}
+
+// scanSQLiteService scans a row selected with sqliteServiceColumns.
+func scanSQLiteService(row sqlRow) (*models.Service, error) {
+	var service models.Service
+	var healthCheck, tags, labels []byte
+
+	err := row.Scan(
+		&service.ServiceID, &service.Name, &service.Description, &service.OwnerInfo,
+		&service.IndustryCategory, &service.ClientRating, &healthCheck, &service.CreatedAt,
+		&service.UpdatedAt, &service.TransactionCount, &service.AvgResponseTime, &tags, &labels,
+		&service.ReviewCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decodeJSON(healthCheck, &service.HealthCheck); err != nil {
+		return nil, err
+	}
+	if err := decodeJSON(tags, &service.Tags); err != nil {
+		return nil, err
+	}
+	if err := decodeJSON(labels, &service.Labels); err != nil {
+		return nil, err
+	}
+	service.IndustryCategoryName = industry.Title(service.IndustryCategory)
+
+	return &service, nil
+}
+
+// RegisterService inserts a new service into the database and returns the inserted service.
+// A new ServiceID is always generated, replacing any the caller set.
+func (s *SQLiteStore) RegisterService(ctx context.Context, service models.Service) (
+	*models.Service, error,
+) {
+	service.ServiceID = uuid.New()
+	service = withCategory(service)
+	service.Tags = normalizeTags(service.Tags)
+	service.ReviewCount = 0
+	service.CreatedAt = time.Now().UTC()
+	service.UpdatedAt = service.CreatedAt
+
+	healthCheck, err := encodeJSON(service.HealthCheck)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert service: %w", err)
+	}
+	tags, err := encodeJSON(service.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert service: %w", err)
+	}
+	labels, err := encodeJSON(service.Labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert service: %w", err)
+	}
+
+	query := `
+		INSERT INTO services (service_id, name, description, owner_info, industry_category,
+							  client_rating, health_check, created_at, updated_at,
+							  transaction_count, avg_response_time, tags, labels)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err = s.db().ExecContext(
+		ctx, query, service.ServiceID, service.Name, service.Description,
+		service.OwnerInfo, service.IndustryCategory, service.ClientRating, healthCheck,
+		service.CreatedAt, service.UpdatedAt, service.TransactionCount, service.AvgResponseTime, tags,
+		labels,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert service: %w", sqliteErr(err))
+	}
+
+	return &service, nil
+}
+
+// UpdateService updates the service details and returns the updated service.
+func (s *SQLiteStore) UpdateService(ctx context.Context, service models.Service) (
+	*models.Service, error,
+) {
+	service = withCategory(service)
+
+	healthCheck, err := encodeJSON(service.HealthCheck)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update service: %w", err)
+	}
+	tags, err := encodeJSON(normalizeTags(service.Tags))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update service: %w", err)
+	}
+	labels, err := encodeJSON(service.Labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update service: %w", err)
+	}
+
+	query := `
+		UPDATE services
+		SET name = ?, description = ?, owner_info = ?, industry_category = ?, health_check = ?,
+			tags = ?, labels = ?, updated_at = ?
+		WHERE service_id = ?
+	`
+
+	res, err := s.db().ExecContext(
+		ctx, query, service.Name, service.Description, service.OwnerInfo,
+		service.IndustryCategory, healthCheck, tags, labels, time.Now().UTC(), service.ServiceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update service: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("failed to update service: %w", ErrNotFound)
+	}
+
+	return s.GetService(ctx, service.ServiceID)
+}
+
+// GetService retrieves a service by ID.
+func (s *SQLiteStore) GetService(ctx context.Context, serviceID uuid.UUID) (
+	*models.Service, error,
+) {
+	query := `
+		SELECT ` + sqliteServiceColumns + `
+		FROM services
+		WHERE service_id = ?
+	`
+
+	service, err := scanSQLiteService(s.db().QueryRowContext(ctx, query, serviceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve service: %w", sqliteErr(err))
+	}
+
+	return service, nil
+}
+
+// GetServiceForUpdate retrieves a service by ID. The store's single connection
+// already keeps other transactions out until the caller's one ends.
+func (s *SQLiteStore) GetServiceForUpdate(ctx context.Context, serviceID uuid.UUID) (
+	*models.Service, error,
+) {
+	return s.GetService(ctx, serviceID)
+}
+
+// ListServices retrieves the services selected by query from the database.
+func (s *SQLiteStore) ListServices(ctx context.Context, query ServiceQuery) (
+	[]models.Service, error,
+) {
+	if err := query.check(); err != nil {
+		return nil, fmt.Errorf("failed to query services: %w", err)
+	}
+
+	where, order, args := query.sql(sqliteDialect)
+	rows, err := s.db().QueryContext(
+		ctx, `
+		SELECT `+sqliteServiceColumns+`
+		FROM services
+		`+where+`
+		`+order, args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query services: %w", err)
+	}
+	defer rows.Close()
+
+	var services []models.Service
+	for rows.Next() {
+		service, err := scanSQLiteService(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		services = append(services, *service)
+	}
+
+	return services, rows.Err()
+}
+
+// SearchServices ranks the services matching text by relevance. SQLite has
+// no equivalent of the tsvector ranking of the Postgres backend, so the
+// services are scored in Go.
+func (s *SQLiteStore) SearchServices(ctx context.Context, text string, limit int) (
+	[]models.SearchResult, error,
+) {
+	terms, err := parseSearch(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search services: %w", err)
+	}
+
+	services, err := s.ListServices(ctx, ServiceQuery{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search services: %w", err)
+	}
+
+	return rankServices(services, terms, limit), nil
+}
+
+// DeleteService deletes a service by ID. It returns ErrNotFound when there is no such
+// service and ErrConflict while the service still has instances.
+func (s *SQLiteStore) DeleteService(ctx context.Context, serviceID uuid.UUID) error {
+	res, err := s.db().ExecContext(ctx, `DELETE FROM services WHERE service_id = ?`, serviceID)
+	if sqliteCode(err) == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY {
+		return fmt.Errorf("failed to delete service: %w: service still has instances", ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete service: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("failed to delete service: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// sqliteReviewColumns lists the service_reviews columns in the order
+// scanSQLiteReview reads them.
+const sqliteReviewColumns = `review_id, service_id, reviewer, rating, COALESCE(review, ''), created_at,
+	updated_at`
+
+// scanSQLiteReview scans a row selected with sqliteReviewColumns.
+func scanSQLiteReview(row sqlRow) (*models.Review, error) {
+	var review models.Review
+	err := row.Scan(
+		&review.ReviewID, &review.ServiceID, &review.Reviewer, &review.Rating, &review.Comment,
+		&review.CreatedAt, &review.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &review, nil
+}
+
+// PutReview stores the review and recomputes the service's client rating in
+// one transaction.
+func (s *SQLiteStore) PutReview(ctx context.Context, review models.Review) (*models.Review, error) {
+	var stored *models.Review
+	err := s.WithTx(
+		ctx, func(tx Store) error {
+			var err error
+			stored, err = tx.(*SQLiteStore).putReview(ctx, review)
+			return err
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store review: %w", err)
+	}
+
+	return stored, nil
+}
+
+func (s *SQLiteStore) putReview(ctx context.Context, review models.Review) (*models.Review, error) {
+	// writing first takes the write lock, so the totals below include every review
+	now := time.Now().UTC()
+	stored, err := scanSQLiteReview(
+		s.db().QueryRowContext(
+			ctx, `
+			INSERT INTO service_reviews (review_id, service_id, reviewer, rating, review, created_at,
+										 updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (service_id, reviewer) DO UPDATE
+			SET rating = excluded.rating, review = excluded.review, updated_at = excluded.updated_at
+			RETURNING `+sqliteReviewColumns,
+			uuid.New(), review.ServiceID, review.Reviewer, review.Rating, review.Comment, now, now,
+		),
+	)
+	if sqliteCode(err) == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+
+	var stars, count int64
+	err = s.db().QueryRowContext(
+		ctx, `SELECT COALESCE(SUM(rating), 0), COUNT(*) FROM service_reviews WHERE service_id = ?`,
+		review.ServiceID,
+	).Scan(&stars, &count)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db().ExecContext(
+		ctx, `UPDATE services SET client_rating = ?, review_count = ? WHERE service_id = ?`,
+		clientRating(stars, count), count, review.ServiceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// ListReviews retrieves the reviews of a service, most recently updated first.
+func (s *SQLiteStore) ListReviews(ctx context.Context, serviceID uuid.UUID) (
+	[]models.Review, error,
+) {
+	rows, err := s.db().QueryContext(
+		ctx, `SELECT `+sqliteReviewColumns+` FROM service_reviews WHERE service_id = ?
+			ORDER BY updated_at DESC, review_id`, serviceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reviews: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := make([]models.Review, 0)
+	for rows.Next() {
+		review, err := scanSQLiteReview(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan review: %w", err)
+		}
+		reviews = append(reviews, *review)
+	}
+
+	return reviews, rows.Err()
+}
+
+// sqliteInstanceColumns lists the service_instances columns in the order
+// scanSQLiteInstance reads them.
+const sqliteInstanceColumns = `service_id, instance_id, version, host, port, url, api_spec,
+	latitude, longitude, health_status, created_at, last_checked, lease_ttl_ms, lease_expires_at,
+	scheme, base_path, endpoints, labels`
+
+// scanSQLiteInstance scans a row selected with sqliteInstanceColumns.
+func scanSQLiteInstance(row sqlRow) (*models.ServiceInstance, error) {
+	var serviceInstance models.ServiceInstance
+	var leaseTTLMs int64
+	var leaseExpiresAt sql.NullTime
+	var endpoints, labels []byte
+
+	err := row.Scan(
+		&serviceInstance.ServiceID, &serviceInstance.InstanceID, &serviceInstance.Version,
+		&serviceInstance.Host,
+		&serviceInstance.Port, &serviceInstance.Url, &serviceInstance.ApiSpec,
+		&serviceInstance.Latitude, &serviceInstance.Longitude,
+		&serviceInstance.HealthStatus, &serviceInstance.CreatedAt, &serviceInstance.LastChecked,
+		&leaseTTLMs, &leaseExpiresAt, &serviceInstance.Scheme, &serviceInstance.BasePath, &endpoints,
+		&labels,
+	)
+	if err != nil {
+		return nil, err
+	}
+	serviceInstance.LeaseTTL = models.Duration(time.Duration(leaseTTLMs) * time.Millisecond)
+	if leaseExpiresAt.Valid {
+		serviceInstance.LeaseExpiresAt = &leaseExpiresAt.Time
+	}
+	if err := decodeJSON(endpoints, &serviceInstance.Endpoints); err != nil {
+		return nil, err
+	}
+	if err := decodeJSON(labels, &serviceInstance.Labels); err != nil {
+		return nil, err
+	}
+
+	return &serviceInstance, nil
+}
+
+// CreateServiceInstance creates a new ServiceInstance in the database.
+func (s *SQLiteStore) CreateServiceInstance(
+	ctx context.Context, instance models.ServiceInstance,
+) (*models.ServiceInstance, error) {
+	if err := validateInstance(instance); err != nil {
+		return nil, fmt.Errorf("failed to create service instance: %w", err)
+	}
+
+	instance = withEndpoints(instance)
+	instance.InstanceID = uuid.New()
+	instance.HealthStatus = models.HealthStarting
+	instance.CreatedAt = time.Now().UTC()
+	instance.LastChecked = time.Now().UTC()
+	instance.LeaseExpiresAt = leaseExpiry(instance.LeaseTTL, instance.CreatedAt)
+
+	endpoints, err := encodeJSON(instance.Endpoints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service instance: %w", err)
+	}
+	labels, err := encodeJSON(instance.Labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service instance: %w", err)
+	}
+
+	query := `
+		INSERT INTO service_instances (
+			service_id, instance_id, version, host, port, url, api_spec, latitude, longitude, health_status, created_at, last_checked,
+			lease_ttl_ms, lease_expires_at, scheme, base_path, endpoints, labels
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err = s.db().ExecContext(
+		ctx, query, instance.ServiceID, instance.InstanceID, instance.Version, instance.Host,
+		instance.Port, instance.Url, instance.ApiSpec, instance.Latitude, instance.Longitude,
+		instance.HealthStatus, instance.CreatedAt, instance.LastChecked,
+		time.Duration(instance.LeaseTTL).Milliseconds(), instance.LeaseExpiresAt,
+		instance.Scheme, instance.BasePath, endpoints, labels,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service instance: %w", sqliteErr(err))
+	}
+
+	return &instance, nil
+}
+
+// GetServiceInstance retrieves a service instance by ID.
+func (s *SQLiteStore) GetServiceInstance(
+	ctx context.Context, instanceID uuid.UUID,
+) (*models.ServiceInstance, error) {
+	query := `
+		SELECT ` + sqliteInstanceColumns + `
+		FROM service_instances
+		WHERE instance_id = ?
+	`
+
+	serviceInstance, err := scanSQLiteInstance(s.db().QueryRowContext(ctx, query, instanceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve service instance: %w", sqliteErr(err))
+	}
+
+	return serviceInstance, nil
+}
+
+// ListServiceInstances retrieves the instances matching filter, oldest first.
+func (s *SQLiteStore) ListServiceInstances(
+	ctx context.Context, filter InstanceFilter,
+) ([]models.ServiceInstance, error) {
+	query := `
+		SELECT ` + sqliteInstanceColumns + `
+		FROM service_instances
+		WHERE (?1 IS NULL OR service_id = ?1)
+		  AND (?2 = '' OR version = ?2)
+		  AND (?3 = '' OR health_status = ?3)
+		  AND (?4 = '' OR host = ?4)
+	`
+
+	var serviceID any
+	if filter.ServiceID != uuid.Nil {
+		serviceID = filter.ServiceID.String()
+	}
+	args := []any{serviceID, filter.Version, string(filter.HealthStatus), filter.Host}
+	query += filter.labelSQL(sqliteDialect, &args) + `
+		ORDER BY created_at, instance_id`
+
+	rows, err := s.db().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query service instances: %w", err)
+	}
+	defer rows.Close()
+
+	instances := make([]models.ServiceInstance, 0)
+	for rows.Next() {
+		serviceInstance, err := scanSQLiteInstance(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		instances = append(instances, *serviceInstance)
+	}
+
+	return instances, rows.Err()
+}
+
+// UpdateServiceInstanceHealth records the outcome of a health check.
+func (s *SQLiteStore) UpdateServiceInstanceHealth(
+	ctx context.Context, instanceID uuid.UUID, status models.HealthStatus, checkedAt time.Time,
+) (*models.ServiceInstance, error) {
+	res, err := s.db().ExecContext(
+		ctx, `UPDATE service_instances SET health_status = ?, last_checked = ? WHERE instance_id = ?`,
+		string(status), checkedAt.UTC(), instanceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update service instance health: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("failed to update service instance health: %w", ErrNotFound)
+	}
+
+	return s.GetServiceInstance(ctx, instanceID)
+}
+
+// RemoveServiceInstance copies the instance to service_instance_history and then deletes it,
+// both in one transaction.
+func (s *SQLiteStore) RemoveServiceInstance(ctx context.Context, instanceID uuid.UUID) error {
+	return s.WithTx(
+		ctx, func(tx Store) error {
+			return tx.(*SQLiteStore).removeServiceInstance(ctx, instanceID, ReasonDeregistered)
+		},
+	)
+}
+
+// RenewLease extends the instance's lease to now plus its TTL.
+func (s *SQLiteStore) RenewLease(
+	ctx context.Context, instanceID uuid.UUID, ttl time.Duration, now time.Time,
+) (*models.ServiceInstance, error) {
+	if ttl < 0 {
+		return nil, fmt.Errorf("failed to renew lease: %w: ttl must not be negative", ErrInvalid)
+	}
+
+	var renewed *models.ServiceInstance
+	err := s.WithTx(
+		ctx, func(tx Store) error {
+			lite := tx.(*SQLiteStore)
+			serviceInstance, err := lite.GetServiceInstance(ctx, instanceID)
+			if err != nil {
+				return err
+			}
+			if ttl > 0 {
+				serviceInstance.LeaseTTL = models.Duration(ttl)
+			}
+			if serviceInstance.LeaseTTL <= 0 {
+				return fmt.Errorf("%w: instance has no lease ttl", ErrInvalid)
+			}
+			serviceInstance.LeaseExpiresAt = leaseExpiry(serviceInstance.LeaseTTL, now.UTC())
+
+			_, err = lite.db().ExecContext(
+				ctx, `
+				UPDATE service_instances SET lease_ttl_ms = ?, lease_expires_at = ?
+				WHERE instance_id = ?`,
+				time.Duration(serviceInstance.LeaseTTL).Milliseconds(),
+				*serviceInstance.LeaseExpiresAt, instanceID,
+			)
+			renewed = serviceInstance
+			return err
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to renew lease: %w", err)
+	}
+
+	return renewed, nil
+}
+
+// ExpireLeases archives every instance whose lease expired before now.
+func (s *SQLiteStore) ExpireLeases(ctx context.Context, now time.Time) (
+	[]models.ServiceInstance, error,
+) {
+	expired := make([]models.ServiceInstance, 0)
+	err := s.WithTx(
+		ctx, func(tx Store) error {
+			lite := tx.(*SQLiteStore)
+			rows, err := lite.db().QueryContext(
+				ctx, `
+				SELECT `+sqliteInstanceColumns+`
+				FROM service_instances
+				WHERE lease_expires_at IS NOT NULL`,
+			)
+			if err != nil {
+				return err
+			}
+
+			// timestamps are stored as text, so the expiry is compared here
+			// rather than in SQL
+			var leased []models.ServiceInstance
+			for rows.Next() {
+				serviceInstance, err := scanSQLiteInstance(rows)
+				if err != nil {
+					rows.Close()
+					return err
+				}
+				if serviceInstance.LeaseExpiresAt.Before(now) {
+					leased = append(leased, *serviceInstance)
+				}
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			for _, instance := range leased {
+				err := lite.removeServiceInstance(ctx, instance.InstanceID, ReasonLeaseExpired)
+				if err != nil {
+					return err
+				}
+				expired = append(expired, instance)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire leases: %w", err)
+	}
+
+	return expired, nil
+}
+
+// Index returns the registry index.
+func (s *SQLiteStore) Index(ctx context.Context) (uint64, error) {
+	var index uint64
+	err := s.db().QueryRowContext(ctx, `SELECT value FROM registry_index`).Scan(&index)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read registry index: %w", sqliteErr(err))
+	}
+
+	return index, nil
+}
+
+// AdvanceIndex adds n to the registry index and returns the new value.
+func (s *SQLiteStore) AdvanceIndex(ctx context.Context, n uint64) (uint64, error) {
+	var index uint64
+	err := s.db().QueryRowContext(
+		ctx, `UPDATE registry_index SET value = value + ? RETURNING value`, n,
+	).Scan(&index)
+	if err != nil {
+		return 0, fmt.Errorf("failed to advance registry index: %w", sqliteErr(err))
+	}
+
+	return index, nil
+}
+
+// removeServiceInstance archives and deletes the instance, recording reason in the history
+// metrics; the caller provides the transaction. SQLite has no row locks, but its single
+// writer serialises removals.
+func (s *SQLiteStore) removeServiceInstance(
+	ctx context.Context, instanceID uuid.UUID, reason string,
+) error {
+	serviceInstance, err := s.GetServiceInstance(ctx, instanceID)
+	if err != nil {
+		return fmt.Errorf("failed to get service instance: %w", err)
+	}
+
+	metricsJSON, err := json.Marshal(archiveMetrics(*serviceInstance, reason))
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+
+	query := `
+		INSERT INTO service_instance_history (
+			history_id, service_id, instance_id, version, url, metrics, started_at, stopped_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err = s.db().ExecContext(
+		ctx, query, uuid.New(), serviceInstance.ServiceID, serviceInstance.InstanceID,
+		serviceInstance.Version, serviceInstance.Url, string(metricsJSON),
+		serviceInstance.CreatedAt, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to copy service instance to history: %w", err)
+	}
+
+	_, err = s.db().ExecContext(ctx, `DELETE FROM service_instances WHERE instance_id = ?`, instanceID)
+	if err != nil {
+		return fmt.Errorf("failed to delete service instance: %w", err)
+	}
+
+	return nil
+}
+
+// sqliteErr translates database/sql errors into the Store's sentinel errors,
+// which leave the SQLite message out.
+func sqliteErr(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	switch sqliteCode(err) {
+	case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY, sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+		return &dbError{ErrConflict, err}
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return &dbError{ErrReference, err}
+	}
+
+	return err
+}
+
+// sqliteCode returns the extended SQLite result code of err, or 0.
+func sqliteCode(err error) int {
+	var sqliteError *sqlite.Error
+	if errors.As(err, &sqliteError) {
+		return sqliteError.Code()
+	}
+	return 0
+}
@@ -0,0 +1,313 @@
+package db
+
+import (
+	"DirectoryService/cfg"
+	"DirectoryService/models"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestSQLite(t *testing.T) *SQLiteStore {
+	var config cfg.Config
+	config.DB.Driver = DriverSQLite
+	config.DB.Path = filepath.Join(t.TempDir(), "registry.db")
+	config.DB.AutoMigrate = true
+
+	store, err := ConnectSQLite(&config)
+	if err != nil {
+		t.Fatalf("Failed to open sqlite database: %v", err)
+	}
+	t.Cleanup(store.Close)
+
+	return store
+}
+
+func TestNewStoreSQLiteFailure(t *testing.T) {
+	var config cfg.Config
+	config.DB.Driver = DriverSQLite
+	config.DB.Path = filepath.Join(t.TempDir(), "missing", "registry.db")
+	config.DB.AutoMigrate = true
+
+	store, err := NewStore(&config)
+	assert.Error(t, err, "a database that cannot be opened should fail")
+	assert.True(t, store == nil, "the failed store should be a nil interface")
+}
+
+func TestSQLiteServiceLifecycle(t *testing.T) {
+	ss := setupTestSQLite(t)
+	ctx := context.Background()
+
+	inserted, err := ss.RegisterService(
+		ctx, models.Service{
+			Name:             "Test Service",
+			Description:      "A test service",
+			OwnerInfo:        "Test Owner",
+			IndustryCategory: "Test Category",
+			ClientRating:     4.5,
+			AvgResponseTime:  12.5,
+		},
+	)
+	assert.NoError(t, err, "RegisterService should not return an error")
+
+	fetched, err := ss.GetService(ctx, inserted.ServiceID)
+	assert.NoError(t, err, "GetService should not return an error")
+	assert.Equal(t, "Test Service", fetched.Name, "Name should match")
+	assert.Equal(t, 4.5, fetched.ClientRating, "ClientRating should match")
+
+	inserted.Name = "Updated Service"
+	inserted.AvgResponseTime = 99 // the registry keeps the statistics, so this is ignored
+	updated, err := ss.UpdateService(ctx, *inserted)
+	assert.NoError(t, err, "UpdateService should not return an error")
+	assert.Equal(t, "Updated Service", updated.Name, "Name should match")
+	assert.Equal(t, 12.5, updated.AvgResponseTime, "AvgResponseTime should be unchanged")
+
+	services, err := ss.ListServices(ctx, ServiceQuery{})
+	assert.NoError(t, err, "ListServices should not return an error")
+	assert.Len(t, services, 1, "ListServices should return the registered service")
+
+	err = ss.DeleteService(ctx, inserted.ServiceID)
+	assert.NoError(t, err, "DeleteService should not return an error")
+
+	_, err = ss.GetService(ctx, inserted.ServiceID)
+	assert.True(t, errors.Is(err, ErrNotFound), "GetService should return ErrNotFound")
+}
+
+func TestSQLiteInstanceEndpoints(t *testing.T) {
+	ss := setupTestSQLite(t)
+	ctx := context.Background()
+
+	service, err := ss.RegisterService(ctx, models.Service{Name: "Test Service"})
+	assert.NoError(t, err)
+
+	created, err := ss.CreateServiceInstance(
+		ctx, models.ServiceInstance{
+			ServiceID: service.ServiceID,
+			Version:   "1.0.0",
+			Scheme:    "https",
+			Host:      "api.example.com",
+			Port:      8443,
+			BasePath:  "/payments",
+			Endpoints: &models.Endpoints{
+				GRPC:    "grpc://api.example.com:9090",
+				Metrics: "http://api.example.com:9100/metrics",
+			},
+		},
+	)
+	assert.NoError(t, err)
+
+	fetched, err := ss.GetServiceInstance(ctx, created.InstanceID)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.example.com:8443/payments", fetched.Url)
+	assert.Equal(t, "https", fetched.Scheme)
+	assert.Equal(t, "/payments", fetched.BasePath)
+	assert.Equal(
+		t, &models.Endpoints{
+			HTTPS:   "https://api.example.com:8443/payments",
+			GRPC:    "grpc://api.example.com:9090",
+			Metrics: "http://api.example.com:9100/metrics",
+		}, fetched.Endpoints,
+	)
+}
+
+func TestSQLiteCreateServiceInstanceUnknownService(t *testing.T) {
+	ss := setupTestSQLite(t)
+
+	_, err := ss.CreateServiceInstance(
+		context.Background(), models.ServiceInstance{ServiceID: uuid.New(), Version: "1.0.0"},
+	)
+	assert.ErrorIs(t, err, ErrReference, "unknown service should return ErrReference")
+	assert.NotContains(t, err.Error(), "FOREIGN KEY", "the SQLite message stays out of the error")
+	assert.ErrorContains(t, Cause(err), "FOREIGN KEY", "the cause keeps it for logging")
+}
+
+func TestSQLiteRemoveServiceInstance(t *testing.T) {
+	ss := setupTestSQLite(t)
+	ctx := context.Background()
+
+	service, err := ss.RegisterService(ctx, models.Service{Name: "Test Service"})
+	assert.NoError(t, err, "RegisterService should not return an error")
+
+	instance, err := ss.CreateServiceInstance(
+		ctx, models.ServiceInstance{
+			ServiceID: service.ServiceID,
+			Version:   "1.0.0",
+			Host:      "localhost",
+			Port:      8080,
+			Url:       "http://localhost:8080",
+		},
+	)
+	assert.NoError(t, err, "CreateServiceInstance should not return an error")
+
+	fetched, err := ss.GetServiceInstance(ctx, instance.InstanceID)
+	assert.NoError(t, err, "GetServiceInstance should not return an error")
+	assert.Equal(t, "http://localhost:8080", fetched.Url, "Url should match")
+
+	err = ss.RemoveServiceInstance(ctx, instance.InstanceID)
+	assert.NoError(t, err, "RemoveServiceInstance should not return an error")
+
+	var archived int
+	err = ss.DB.QueryRow(
+		`SELECT COUNT(*) FROM service_instance_history WHERE instance_id = ?`,
+		instance.InstanceID,
+	).Scan(&archived)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, archived, "instance should be archived")
+
+	_, err = ss.GetServiceInstance(ctx, instance.InstanceID)
+	assert.True(t, errors.Is(err, ErrNotFound), "instance should be deleted")
+}
+
+func TestSQLiteWithTxRollsBackOnError(t *testing.T) {
+	ss := setupTestSQLite(t)
+	ctx := context.Background()
+
+	errAbort := errors.New("abort")
+	err := ss.WithTx(
+		ctx, func(tx Store) error {
+			if _, err := tx.RegisterService(ctx, models.Service{Name: "Rolled Back"}); err != nil {
+				return err
+			}
+			return errAbort
+		},
+	)
+	assert.True(t, errors.Is(err, errAbort), "WithTx should return the error from fn")
+
+	services, err := ss.ListServices(ctx, ServiceQuery{})
+	assert.NoError(t, err, "ListServices should not return an error")
+	assert.Empty(t, services, "the service should have been rolled back")
+}
+
+func TestSQLiteIndexSurvivesRestart(t *testing.T) {
+	var config cfg.Config
+	config.DB.Driver = DriverSQLite
+	config.DB.Path = filepath.Join(t.TempDir(), "registry.db")
+	config.DB.AutoMigrate = true
+	ctx := context.Background()
+
+	ss, err := ConnectSQLite(&config)
+	if err != nil {
+		t.Fatalf("Failed to open sqlite database: %v", err)
+	}
+	index, err := ss.AdvanceIndex(ctx, 3)
+	assert.NoError(t, err, "AdvanceIndex should not return an error")
+	assert.Equal(t, uint64(3), index)
+	ss.Close()
+
+	ss, err = ConnectSQLite(&config)
+	if err != nil {
+		t.Fatalf("Failed to reopen sqlite database: %v", err)
+	}
+	defer ss.Close()
+
+	index, err = ss.Index(ctx)
+	assert.NoError(t, err, "Index should not return an error")
+	assert.Equal(t, uint64(3), index, "the index should survive a restart")
+}
+
+func TestSQLiteListServiceInstances(t *testing.T) {
+	ss := setupTestSQLite(t)
+	ctx := context.Background()
+
+	service, err := ss.RegisterService(ctx, models.Service{Name: "Test Service"})
+	assert.NoError(t, err, "RegisterService should not return an error")
+
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		_, err := ss.CreateServiceInstance(
+			ctx, models.ServiceInstance{
+				ServiceID: service.ServiceID, Version: version, Host: "localhost", Port: 8080,
+			},
+		)
+		assert.NoError(t, err, "CreateServiceInstance should not return an error")
+	}
+
+	instances, err := ss.ListServiceInstances(ctx, InstanceFilter{ServiceID: service.ServiceID})
+	assert.NoError(t, err, "ListServiceInstances should not return an error")
+	assert.Len(t, instances, 2)
+
+	instances, err = ss.ListServiceInstances(ctx, InstanceFilter{Version: "1.1.0"})
+	assert.NoError(t, err, "ListServiceInstances should not return an error")
+	assert.Len(t, instances, 1)
+	assert.Equal(t, "1.1.0", instances[0].Version)
+}
+
+func TestSQLiteHealthCheckAndStatus(t *testing.T) {
+	ss := setupTestSQLite(t)
+	ctx := context.Background()
+
+	check := &models.HealthCheck{
+		Mode: models.HealthCheckTCP, Interval: models.Duration(10 * time.Second),
+	}
+	service, err := ss.RegisterService(ctx, models.Service{Name: "Test Service", HealthCheck: check})
+	assert.NoError(t, err, "RegisterService should not return an error")
+
+	fetched, err := ss.GetService(ctx, service.ServiceID)
+	assert.NoError(t, err, "GetService should not return an error")
+	assert.Equal(t, check, fetched.HealthCheck, "HealthCheck should round-trip")
+
+	instance, err := ss.CreateServiceInstance(
+		ctx, models.ServiceInstance{
+			ServiceID: service.ServiceID, Version: "1.0.0", Host: "localhost", Port: 8080,
+		},
+	)
+	assert.NoError(t, err, "CreateServiceInstance should not return an error")
+
+	checkedAt := time.Now().Add(time.Minute).UTC()
+	updated, err := ss.UpdateServiceInstanceHealth(ctx, instance.InstanceID, models.HealthUp, checkedAt)
+	assert.NoError(t, err, "UpdateServiceInstanceHealth should not return an error")
+	assert.Equal(t, models.HealthUp, updated.HealthStatus)
+	assert.True(t, checkedAt.Equal(updated.LastChecked), "LastChecked should be updated")
+}
+
+func TestSQLiteLeaseExpiry(t *testing.T) {
+	ss := setupTestSQLite(t)
+	ctx := context.Background()
+
+	service, err := ss.RegisterService(ctx, models.Service{Name: "Test Service"})
+	assert.NoError(t, err, "RegisterService should not return an error")
+
+	leased, err := ss.CreateServiceInstance(
+		ctx, models.ServiceInstance{
+			ServiceID: service.ServiceID,
+			Version:   "1.0.0",
+			LeaseTTL:  models.Duration(10 * time.Second),
+		},
+	)
+	assert.NoError(t, err, "CreateServiceInstance should not return an error")
+
+	fetched, err := ss.GetServiceInstance(ctx, leased.InstanceID)
+	assert.NoError(t, err, "GetServiceInstance should not return an error")
+	assert.Equal(t, models.Duration(10*time.Second), fetched.LeaseTTL, "LeaseTTL should round-trip")
+	assert.NotNil(t, fetched.LeaseExpiresAt, "leased instance should have an expiry")
+
+	renewAt := leased.CreatedAt.Add(8 * time.Second)
+	renewed, err := ss.RenewLease(ctx, leased.InstanceID, 20*time.Second, renewAt)
+	assert.NoError(t, err, "RenewLease should not return an error")
+	assert.Equal(t, models.Duration(20*time.Second), renewed.LeaseTTL, "ttl should be replaced")
+
+	_, err = ss.RenewLease(ctx, uuid.New(), 0, renewAt)
+	assert.ErrorIs(t, err, ErrNotFound, "renewing an unknown instance should fail")
+
+	expired, err := ss.ExpireLeases(ctx, renewAt.Add(15*time.Second))
+	assert.NoError(t, err, "ExpireLeases should not return an error")
+	assert.Empty(t, expired, "a renewed lease should not expire")
+
+	expired, err = ss.ExpireLeases(ctx, renewAt.Add(21*time.Second))
+	assert.NoError(t, err, "ExpireLeases should not return an error")
+	assert.Len(t, expired, 1, "the lapsed lease should expire")
+
+	_, err = ss.GetServiceInstance(ctx, leased.InstanceID)
+	assert.ErrorIs(t, err, ErrNotFound, "expired instance should be deleted")
+
+	var metrics string
+	err = ss.DB.QueryRowContext(
+		ctx, `SELECT metrics FROM service_instance_history WHERE instance_id = ?`, leased.InstanceID,
+	).Scan(&metrics)
+	assert.NoError(t, err, "expired instance should be archived")
+	assert.Contains(t, metrics, `"reason":"lease expired"`)
+}
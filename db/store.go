@@ -0,0 +1,336 @@
+package db
+
+import (
+	"DirectoryService/cfg"
+	"DirectoryService/industry"
+	"DirectoryService/labels"
+	"DirectoryService/models"
+	"DirectoryService/semver"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Supported values for the db.driver configuration key.
+const (
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+	DriverSQLite   = "sqlite"
+)
+
+var (
+	// ErrNotFound is returned when the requested service or instance does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict is returned when a change conflicts with the stored state, such as
+	// a duplicate ID or deleting a service that still has instances.
+	ErrConflict = errors.New("conflict")
+
+	// ErrInvalid is returned when a value is rejected by the store, such as an
+	// instance version that is not a semantic version.
+	ErrInvalid = errors.New("invalid")
+
+	// ErrReference is returned when a value refers to a row that does not exist,
+	// such as an instance of an unknown service.
+	ErrReference = errors.New("invalid reference")
+)
+
+// dbError is one of the errors above raised for a database error. Its message
+// is the Store error's alone, since the database's text can quote stored
+// values; errors.Is and errors.As reach both, and Cause returns the database
+// error for logging.
+type dbError struct {
+	err, cause error
+}
+
+func (e *dbError) Error() string   { return e.err.Error() }
+func (e *dbError) Unwrap() []error { return []error{e.err, e.cause} }
+
+// Cause returns the database error behind a Store error, or nil when the
+// error was not raised for one.
+func Cause(err error) error {
+	var dbErr *dbError
+	if errors.As(err, &dbErr) {
+		return dbErr.cause
+	}
+	return nil
+}
+
+// Reasons recorded under the "reason" key of the history metrics when an
+// instance is archived.
+const (
+	ReasonDeregistered = "deregistered"
+	ReasonLeaseExpired = "lease expired"
+)
+
+// InstanceFilter selects service instances. Zero-valued fields match any instance.
+type InstanceFilter struct {
+	ServiceID    uuid.UUID
+	Version      string
+	HealthStatus models.HealthStatus
+	Host         string
+	Labels       labels.Selector
+}
+
+// Matches reports whether instance satisfies the filter.
+func (f InstanceFilter) Matches(instance models.ServiceInstance) bool {
+	return (f.ServiceID == uuid.Nil || instance.ServiceID == f.ServiceID) &&
+		(f.Version == "" || instance.Version == f.Version) &&
+		(f.HealthStatus == "" || instance.HealthStatus == f.HealthStatus) &&
+		(f.Host == "" || instance.Host == f.Host) &&
+		f.Labels.Matches(instance.Labels)
+}
+
+// labelSQL returns the AND clauses, if any, that apply the filter's label
+// selector, adding their arguments to args.
+func (f InstanceFilter) labelSQL(d sqlDialect, args *[]any) string {
+	arg := func(v any) string {
+		*args = append(*args, v)
+		return d.placeholder(len(*args))
+	}
+
+	var clauses string
+	for _, condition := range labelConditions(d, f.Labels, arg) {
+		clauses += "\n\t\t  AND " + condition
+	}
+	return clauses
+}
+
+// Store is the storage-agnostic interface for the registry. DbCtx is the
+// PostgreSQL implementation; alternative backends and test fakes implement
+// the same set of operations.
+type Store interface {
+	// RegisterService inserts a new service and returns the stored service. The
+	// ServiceID is always generated, replacing any the caller set. The service
+	// starts without reviews; its ClientRating and usage statistics are stored
+	// as given, so callers reset them, as the API's handler does.
+	RegisterService(ctx context.Context, service models.Service) (*models.Service, error)
+
+	// UpdateService updates the service details and returns the updated service.
+	// The client rating and review count, which reviews maintain, and the usage
+	// statistics, which the registry maintains, are left as they are.
+	UpdateService(ctx context.Context, service models.Service) (*models.Service, error)
+
+	// GetService retrieves a service by ID.
+	GetService(ctx context.Context, serviceID uuid.UUID) (*models.Service, error)
+
+	// GetServiceForUpdate retrieves a service by ID and, inside WithTx, keeps
+	// concurrent transactions from changing it until the transaction ends, so
+	// that updates read from it apply one after the other.
+	GetServiceForUpdate(ctx context.Context, serviceID uuid.UUID) (*models.Service, error)
+
+	// ListServices retrieves the services selected by query, in its order. It
+	// fails with ErrInvalid for an unknown order or a cursor taken from a
+	// different one.
+	ListServices(ctx context.Context, query ServiceQuery) ([]models.Service, error)
+
+	// SearchServices ranks the services matching text by relevance, best first,
+	// and returns at most limit of them, or all when limit is zero. Matching
+	// tolerates typos and each result highlights the words that matched. Text
+	// without a word to look for fails with ErrInvalid.
+	SearchServices(ctx context.Context, text string, limit int) ([]models.SearchResult, error)
+
+	// DeleteService deletes a service and its reviews by ID. It fails with
+	// ErrConflict while the service still has instances.
+	DeleteService(ctx context.Context, serviceID uuid.UUID) error
+
+	// PutReview stores a review of a service, replacing the reviewer's earlier
+	// review if there is one, and recomputes the service's client rating and
+	// review count. It returns the stored review and fails with ErrNotFound for
+	// an unknown service.
+	PutReview(ctx context.Context, review models.Review) (*models.Review, error)
+
+	// ListReviews retrieves the reviews of a service, most recently updated
+	// first.
+	ListReviews(ctx context.Context, serviceID uuid.UUID) ([]models.Review, error)
+
+	// CreateServiceInstance registers a new instance of a service. The version
+	// must be a semantic version.
+	CreateServiceInstance(
+		ctx context.Context, instance models.ServiceInstance,
+	) (*models.ServiceInstance, error)
+
+	// GetServiceInstance retrieves a service instance by ID.
+	GetServiceInstance(ctx context.Context, instanceID uuid.UUID) (*models.ServiceInstance, error)
+
+	// ListServiceInstances retrieves the instances matching filter.
+	ListServiceInstances(
+		ctx context.Context, filter InstanceFilter,
+	) ([]models.ServiceInstance, error)
+
+	// UpdateServiceInstanceHealth records the outcome of a health check.
+	UpdateServiceInstanceHealth(
+		ctx context.Context, instanceID uuid.UUID, status models.HealthStatus, checkedAt time.Time,
+	) (*models.ServiceInstance, error)
+
+	// RemoveServiceInstance archives the instance to the history and deletes it.
+	RemoveServiceInstance(ctx context.Context, instanceID uuid.UUID) error
+
+	// RenewLease extends the instance's lease to now plus its TTL. A non-zero ttl
+	// replaces the stored TTL; renewing an instance without a TTL fails with
+	// ErrInvalid.
+	RenewLease(
+		ctx context.Context, instanceID uuid.UUID, ttl time.Duration, now time.Time,
+	) (*models.ServiceInstance, error)
+
+	// ExpireLeases archives and deletes every instance whose lease expired
+	// before now, exactly as RemoveServiceInstance does, and returns them.
+	ExpireLeases(ctx context.Context, now time.Time) ([]models.ServiceInstance, error)
+
+	// Index returns the registry index, the number of changes published to
+	// watchers so far. It is stored with the registry, so it survives restarts
+	// and is shared by every registry node using the same database.
+	Index(ctx context.Context) (uint64, error)
+
+	// AdvanceIndex adds n to the registry index and returns the new value.
+	// Called inside the transaction of the changes it counts, it orders them
+	// with the changes of concurrent transactions.
+	AdvanceIndex(ctx context.Context, n uint64) (uint64, error)
+
+	// WithTx runs fn atomically: every operation fn performs on tx is committed
+	// when fn returns nil and rolled back when it returns an error.
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+
+	// Close releases the resources held by the store.
+	Close()
+}
+
+// DbCtx must satisfy Store.
+var _ Store = (*DbCtx)(nil)
+
+// NewStore creates the Store selected by config.DB.Driver. An empty driver
+// defaults to PostgreSQL.
+func NewStore(config *cfg.Config) (Store, error) {
+	switch config.DB.Driver {
+	case "", DriverPostgres:
+		pool, err := ConnectDB(config)
+		if err != nil {
+			return nil, err
+		}
+		return NewDbCtx(pool), nil
+	case DriverMemory:
+		return NewMemStore(), nil
+	case DriverSQLite:
+		store, err := ConnectSQLite(config)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unsupported db driver %q", config.DB.Driver)
+	}
+}
+
+// validateInstance checks the fields every backend requires of a new instance.
+func validateInstance(instance models.ServiceInstance) error {
+	if _, err := semver.Parse(instance.Version); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	if instance.LeaseTTL < 0 {
+		return fmt.Errorf("%w: lease_ttl must not be negative", ErrInvalid)
+	}
+
+	return nil
+}
+
+// normalizeTags trims the tags of a service and drops empty and repeated
+// ones, keeping the first spelling of tags that differ only in case.
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		seen[strings.ToLower(tag)] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// withCategory puts the industry category of a service in canonical form and
+// sets its display name. Categories outside the NAICS and ISIC tables, which
+// the API rejects, are kept as they are.
+func withCategory(service models.Service) models.Service {
+	service.IndustryCategory = industry.Normalize(service.IndustryCategory)
+	service.IndustryCategoryName = industry.Title(service.IndustryCategory)
+	return service
+}
+
+// withEndpoints sets the canonical URL of a new instance and records it as the
+// endpoint of its scheme, unless the caller named that endpoint already. The
+// endpoints are copied so the stored instance does not share them.
+func withEndpoints(instance models.ServiceInstance) models.ServiceInstance {
+	instance.Url = instance.CanonicalURL()
+
+	var endpoints models.Endpoints
+	if instance.Endpoints != nil {
+		endpoints = *instance.Endpoints
+	}
+	if u, err := url.Parse(instance.Url); err == nil {
+		switch {
+		case u.Scheme == "http" && endpoints.HTTP == "":
+			endpoints.HTTP = instance.Url
+		case u.Scheme == "https" && endpoints.HTTPS == "":
+			endpoints.HTTPS = instance.Url
+		}
+	}
+
+	instance.Endpoints = nil
+	if !endpoints.IsZero() {
+		instance.Endpoints = &endpoints
+	}
+	return instance
+}
+
+// leaseExpiry returns when a lease with ttl granted at now expires, or nil when
+// the instance has no lease.
+func leaseExpiry(ttl models.Duration, now time.Time) *time.Time {
+	if ttl <= 0 {
+		return nil
+	}
+
+	expiresAt := now.Add(time.Duration(ttl))
+	return &expiresAt
+}
+
+// archiveMetrics builds the metrics stored with an archived instance.
+func archiveMetrics(instance models.ServiceInstance, reason string) map[string]any {
+	return map[string]any{
+		"health_status": instance.HealthStatus,
+		"reason":        reason,
+	}
+}
+
+// encodeJSON marshals v for a JSON column. Nil values are stored as NULL.
+func encodeJSON(v any) (*string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON column: %w", err)
+	}
+	if string(b) == "null" {
+		return nil, nil
+	}
+
+	encoded := string(b)
+	return &encoded, nil
+}
+
+// decodeJSON unmarshals a JSON column into v, leaving v untouched for NULL.
+func decodeJSON(b []byte, v any) error {
+	if len(b) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("failed to decode JSON column: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,341 @@
+package db
+
+import (
+	"DirectoryService/models"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WatchedStore decorates a Store and publishes every change it makes to a
+// ChangeFeed. Each change runs in a transaction that also advances the
+// registry index, which numbers the change's events; they are published once
+// the transaction commits and discarded when it rolls back.
+type WatchedStore struct {
+	Store
+	feed    *ChangeFeed
+	publish *sync.Mutex     // held from advancing the index until publishing
+	pending *[]models.Event // set inside WithTx
+}
+
+// WatchedStore must satisfy Store.
+var _ Store = (*WatchedStore)(nil)
+
+// NewWatchedStore wraps store so that its changes are published to feed.
+func NewWatchedStore(store Store, feed *ChangeFeed) *WatchedStore {
+	return &WatchedStore{Store: store, feed: feed, publish: &sync.Mutex{}}
+}
+
+// Feed returns the ChangeFeed the store publishes to.
+func (w *WatchedStore) Feed() *ChangeFeed {
+	return w.feed
+}
+
+// change runs fn in a transaction, joining the enclosing one if there is
+// one, so that the change and the registry index advance together.
+func (w *WatchedStore) change(ctx context.Context, fn func(tx *WatchedStore) error) error {
+	if w.pending != nil {
+		return fn(w)
+	}
+	return w.WithTx(ctx, func(tx Store) error { return fn(tx.(*WatchedStore)) })
+}
+
+// emit queues events until the enclosing transaction commits.
+func (w *WatchedStore) emit(events ...models.Event) {
+	*w.pending = append(*w.pending, events...)
+}
+
+// serviceName looks up the name recorded on instance events. A failed lookup
+// leaves the name empty rather than failing the change that already happened.
+func (w *WatchedStore) serviceName(ctx context.Context, serviceID uuid.UUID) string {
+	service, err := w.Store.GetService(ctx, serviceID)
+	if err != nil {
+		return ""
+	}
+
+	return service.Name
+}
+
+// instanceEvent builds an event about instance.
+func (w *WatchedStore) instanceEvent(
+	ctx context.Context, eventType models.EventType, instance models.ServiceInstance,
+) models.Event {
+	return models.Event{
+		Type:        eventType,
+		ServiceID:   instance.ServiceID,
+		ServiceName: w.serviceName(ctx, instance.ServiceID),
+		Instance:    &instance,
+	}
+}
+
+// WithTx runs fn in a transaction of the wrapped store and publishes the
+// changes fn made once it commits, numbered by the registry index the
+// transaction advanced.
+func (w *WatchedStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if w.pending != nil {
+		// nested transactions join the outer one, which publishes on commit
+		return w.Store.WithTx(
+			ctx, func(tx Store) error {
+				return fn(&WatchedStore{Store: tx, feed: w.feed, publish: w.publish, pending: w.pending})
+			},
+		)
+	}
+
+	var pending []models.Event
+	locked := false
+	err := w.Store.WithTx(
+		ctx, func(tx Store) error {
+			err := fn(&WatchedStore{Store: tx, feed: w.feed, publish: w.publish, pending: &pending})
+			if err != nil || len(pending) == 0 {
+				return err
+			}
+
+			// Transactions advance the index one at a time, so holding publish
+			// until the events are out keeps the feed in index order
+			w.publish.Lock()
+			locked = true
+			index, err := tx.AdvanceIndex(ctx, uint64(len(pending)))
+			if err != nil {
+				return err
+			}
+			first := index - uint64(len(pending)) + 1
+			for i := range pending {
+				pending[i].Index = first + uint64(i)
+			}
+			return nil
+		},
+	)
+	if locked {
+		defer w.publish.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+
+	w.feed.Publish(pending...)
+	return nil
+}
+
+// RegisterService registers the service and publishes service.registered.
+func (w *WatchedStore) RegisterService(ctx context.Context, service models.Service) (
+	*models.Service, error,
+) {
+	var registered *models.Service
+	err := w.change(
+		ctx, func(tx *WatchedStore) error {
+			var err error
+			if registered, err = tx.Store.RegisterService(ctx, service); err != nil {
+				return err
+			}
+
+			published := *registered
+			tx.emit(
+				models.Event{
+					Type:        models.EventServiceRegistered,
+					ServiceID:   registered.ServiceID,
+					ServiceName: registered.Name,
+					Service:     &published,
+				},
+			)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return registered, nil
+}
+
+// UpdateService updates the service and publishes service.updated.
+func (w *WatchedStore) UpdateService(ctx context.Context, service models.Service) (
+	*models.Service, error,
+) {
+	var updated *models.Service
+	err := w.change(
+		ctx, func(tx *WatchedStore) error {
+			var err error
+			if updated, err = tx.Store.UpdateService(ctx, service); err != nil {
+				return err
+			}
+
+			published := *updated
+			tx.emit(
+				models.Event{
+					Type:        models.EventServiceUpdated,
+					ServiceID:   updated.ServiceID,
+					ServiceName: updated.Name,
+					Service:     &published,
+				},
+			)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// DeleteService deletes the service and publishes service.deleted.
+func (w *WatchedStore) DeleteService(ctx context.Context, serviceID uuid.UUID) error {
+	return w.change(
+		ctx, func(tx *WatchedStore) error {
+			service, err := tx.Store.GetService(ctx, serviceID)
+			if err != nil {
+				return err
+			}
+			if err := tx.Store.DeleteService(ctx, serviceID); err != nil {
+				return err
+			}
+
+			tx.emit(
+				models.Event{
+					Type:        models.EventServiceDeleted,
+					ServiceID:   serviceID,
+					ServiceName: service.Name,
+					Service:     service,
+				},
+			)
+			return nil
+		},
+	)
+}
+
+// PutReview stores the review and publishes service.updated, since the
+// service's client rating changed with it.
+func (w *WatchedStore) PutReview(ctx context.Context, review models.Review) (*models.Review, error) {
+	var stored *models.Review
+	err := w.change(
+		ctx, func(tx *WatchedStore) error {
+			var err error
+			if stored, err = tx.Store.PutReview(ctx, review); err != nil {
+				return err
+			}
+
+			if service, err := tx.Store.GetService(ctx, review.ServiceID); err == nil {
+				tx.emit(
+					models.Event{
+						Type:        models.EventServiceUpdated,
+						ServiceID:   service.ServiceID,
+						ServiceName: service.Name,
+						Service:     service,
+					},
+				)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// CreateServiceInstance registers the instance and publishes instance.added.
+func (w *WatchedStore) CreateServiceInstance(
+	ctx context.Context, instance models.ServiceInstance,
+) (*models.ServiceInstance, error) {
+	var created *models.ServiceInstance
+	err := w.change(
+		ctx, func(tx *WatchedStore) error {
+			var err error
+			if created, err = tx.Store.CreateServiceInstance(ctx, instance); err != nil {
+				return err
+			}
+
+			tx.emit(tx.instanceEvent(ctx, models.EventInstanceAdded, *created))
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// UpdateServiceInstanceHealth records the health check and publishes
+// instance.health_changed when the status changed.
+func (w *WatchedStore) UpdateServiceInstanceHealth(
+	ctx context.Context, instanceID uuid.UUID, status models.HealthStatus, checkedAt time.Time,
+) (*models.ServiceInstance, error) {
+	var updated *models.ServiceInstance
+	err := w.change(
+		ctx, func(tx *WatchedStore) error {
+			previous, err := tx.Store.GetServiceInstance(ctx, instanceID)
+			if err != nil {
+				return err
+			}
+
+			updated, err = tx.Store.UpdateServiceInstanceHealth(ctx, instanceID, status, checkedAt)
+			if err != nil {
+				return err
+			}
+
+			if updated.HealthStatus != previous.HealthStatus {
+				event := tx.instanceEvent(ctx, models.EventInstanceHealthChanged, *updated)
+				event.PreviousHealth = previous.HealthStatus
+				tx.emit(event)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// RemoveServiceInstance archives the instance and publishes instance.removed.
+func (w *WatchedStore) RemoveServiceInstance(ctx context.Context, instanceID uuid.UUID) error {
+	return w.change(
+		ctx, func(tx *WatchedStore) error {
+			instance, err := tx.Store.GetServiceInstance(ctx, instanceID)
+			if err != nil {
+				return err
+			}
+			if err := tx.Store.RemoveServiceInstance(ctx, instanceID); err != nil {
+				return err
+			}
+
+			event := tx.instanceEvent(ctx, models.EventInstanceRemoved, *instance)
+			event.Reason = ReasonDeregistered
+			tx.emit(event)
+			return nil
+		},
+	)
+}
+
+// ExpireLeases archives the expired instances and publishes instance.removed
+// for each of them.
+func (w *WatchedStore) ExpireLeases(ctx context.Context, now time.Time) (
+	[]models.ServiceInstance, error,
+) {
+	var expired []models.ServiceInstance
+	err := w.change(
+		ctx, func(tx *WatchedStore) error {
+			var err error
+			if expired, err = tx.Store.ExpireLeases(ctx, now); err != nil {
+				return err
+			}
+
+			for _, instance := range expired {
+				event := tx.instanceEvent(ctx, models.EventInstanceRemoved, instance)
+				event.Reason = ReasonLeaseExpired
+				tx.emit(event)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return expired, nil
+}
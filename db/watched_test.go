@@ -0,0 +1,201 @@
+package db
+
+import (
+	"DirectoryService/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func eventTypes(events []models.Event) []models.EventType {
+	types := make([]models.EventType, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestChangeFeedSinceAndEviction(t *testing.T) {
+	feed := NewChangeFeed(2)
+
+	events, ok := feed.Since(0)
+	assert.True(t, ok, "an empty feed has nothing to miss")
+	assert.Empty(t, events)
+
+	feed.Publish(models.Event{Type: models.EventServiceRegistered})
+	feed.Publish(models.Event{Type: models.EventServiceUpdated})
+	feed.Publish(models.Event{Type: models.EventServiceDeleted})
+	assert.Equal(t, uint64(3), feed.Index())
+
+	events, ok = feed.Since(1)
+	assert.True(t, ok)
+	assert.Equal(t, []models.EventType{models.EventServiceUpdated, models.EventServiceDeleted}, eventTypes(events))
+	assert.Equal(t, uint64(3), events[1].Index)
+
+	_, ok = feed.Since(0)
+	assert.False(t, ok, "the first event has been evicted")
+
+	_, ok = feed.Since(10)
+	assert.False(t, ok, "an index ahead of the feed cannot be resumed")
+}
+
+func TestChangeFeedSinceMissingChanges(t *testing.T) {
+	feed := NewChangeFeed(0)
+	feed.SkipTo(5)
+	assert.Equal(t, uint64(5), feed.Index(), "the feed follows on from the persisted index")
+
+	events, ok := feed.Since(5)
+	assert.True(t, ok)
+	assert.Empty(t, events)
+
+	// 6 and 7 were made through another registry node
+	feed.Publish(models.Event{Index: 8, Type: models.EventServiceRegistered})
+	assert.Equal(t, uint64(8), feed.Index())
+
+	_, ok = feed.Since(5)
+	assert.False(t, ok, "changes the feed does not hold cannot be resumed")
+
+	events, ok = feed.Since(7)
+	assert.True(t, ok)
+	assert.Len(t, events, 1)
+
+	// the registry's database was restored to an earlier state
+	feed.SkipTo(3)
+	_, ok = feed.Since(8)
+	assert.False(t, ok, "an index from before the reset cannot be resumed")
+	feed.Publish(models.Event{Index: 4, Type: models.EventServiceRegistered})
+	events, ok = feed.Since(3)
+	assert.True(t, ok)
+	assert.Len(t, events, 1, "the events from before the reset are dropped")
+}
+
+func TestChangeFeedWait(t *testing.T) {
+	feed := NewChangeFeed(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, uint64(0), feed.Wait(ctx, 0), "Wait should return when ctx expires")
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		feed.Publish(models.Event{Type: models.EventServiceRegistered})
+	}()
+	assert.Equal(t, uint64(1), feed.Wait(context.Background(), 0), "Wait should return on publish")
+}
+
+func TestWatchedStorePublishesChanges(t *testing.T) {
+	feed := NewChangeFeed(0)
+	store := NewWatchedStore(NewMemStore(), feed)
+	ctx := context.Background()
+
+	service, err := store.RegisterService(ctx, models.Service{Name: "Payments"})
+	assert.NoError(t, err)
+	instance, err := store.CreateServiceInstance(
+		ctx, models.ServiceInstance{ServiceID: service.ServiceID, Version: "1.0.0"},
+	)
+	assert.NoError(t, err)
+
+	_, err = store.UpdateServiceInstanceHealth(ctx, instance.InstanceID, models.HealthUp, time.Now())
+	assert.NoError(t, err)
+	_, err = store.UpdateServiceInstanceHealth(ctx, instance.InstanceID, models.HealthUp, time.Now())
+	assert.NoError(t, err)
+	assert.NoError(t, store.RemoveServiceInstance(ctx, instance.InstanceID))
+	assert.NoError(t, store.DeleteService(ctx, service.ServiceID))
+
+	events, ok := feed.Since(0)
+	assert.True(t, ok)
+	assert.Equal(
+		t, []models.EventType{
+			models.EventServiceRegistered,
+			models.EventInstanceAdded,
+			models.EventInstanceHealthChanged,
+			models.EventInstanceRemoved,
+			models.EventServiceDeleted,
+		}, eventTypes(events), "an unchanged health status should not be published",
+	)
+	assert.Equal(t, "Payments", events[1].ServiceName, "instance events carry the service name")
+	assert.Equal(t, models.HealthStarting, events[2].PreviousHealth)
+	assert.Equal(t, ReasonDeregistered, events[3].Reason)
+}
+
+func TestWatchedStorePublishesOnCommitOnly(t *testing.T) {
+	feed := NewChangeFeed(0)
+	store := NewWatchedStore(NewMemStore(), feed)
+	ctx := context.Background()
+
+	errRollback := errors.New("rollback")
+	err := store.WithTx(
+		ctx, func(tx Store) error {
+			if _, err := tx.RegisterService(ctx, models.Service{Name: "Discarded"}); err != nil {
+				return err
+			}
+			return errRollback
+		},
+	)
+	assert.ErrorIs(t, err, errRollback)
+	assert.Equal(t, uint64(0), feed.Index(), "rolled back changes should not be published")
+
+	err = store.WithTx(
+		ctx, func(tx Store) error {
+			service, err := tx.RegisterService(ctx, models.Service{Name: "Payments"})
+			if err != nil {
+				return err
+			}
+			assert.Equal(t, uint64(0), feed.Index(), "changes are held until commit")
+			service.Description = "updated"
+			_, err = tx.UpdateService(ctx, *service)
+			return err
+		},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), feed.Index(), "committed changes should be published")
+}
+
+func TestWatchedStoreNumbersChangesWithRegistryIndex(t *testing.T) {
+	backend := NewMemStore()
+	ctx := context.Background()
+	_, err := backend.AdvanceIndex(ctx, 10) // changes made before a restart
+	assert.NoError(t, err)
+
+	feed := NewChangeFeed(0)
+	feed.SkipTo(10)
+	store := NewWatchedStore(backend, feed)
+
+	service, err := store.RegisterService(ctx, models.Service{Name: "Payments"})
+	assert.NoError(t, err)
+	_, err = store.CreateServiceInstance(ctx, models.ServiceInstance{ServiceID: uuid.New(), Version: "1.0.0"})
+	assert.Error(t, err, "a failed change should not advance the index")
+	assert.NoError(t, store.DeleteService(ctx, service.ServiceID))
+
+	index, err := store.Index(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(12), index, "each published change advances the registry index")
+
+	events, ok := feed.Since(10)
+	assert.True(t, ok)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, uint64(11), events[0].Index)
+		assert.Equal(t, uint64(12), events[1].Index)
+	}
+}
+
+func TestWatchedStorePublishesReviews(t *testing.T) {
+	feed := NewChangeFeed(0)
+	store := NewWatchedStore(NewMemStore(), feed)
+	ctx := context.Background()
+
+	service, err := store.RegisterService(ctx, models.Service{Name: "Payments"})
+	assert.NoError(t, err)
+	_, err = store.PutReview(ctx, models.Review{ServiceID: service.ServiceID, Reviewer: "alice", Rating: 5})
+	assert.NoError(t, err)
+
+	events, _ := feed.Since(1)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, models.EventServiceUpdated, events[0].Type)
+		assert.Equal(t, 3.33, events[0].Service.ClientRating, "the event carries the new rating")
+	}
+}
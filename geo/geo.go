@@ -0,0 +1,51 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+// EarthRadiusKm is the mean radius of the Earth used for distance calculations.
+const EarthRadiusKm = 6371.0088
+
+// Point is a WGS84 latitude/longitude in degrees.
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Validate checks that the point lies within the latitude and longitude bounds.
+func (p Point) Validate() error {
+	if math.IsNaN(p.Latitude) || p.Latitude < -90 || p.Latitude > 90 {
+		return fmt.Errorf("latitude %v is out of range [-90, 90]", p.Latitude)
+	}
+	if math.IsNaN(p.Longitude) || p.Longitude < -180 || p.Longitude > 180 {
+		return fmt.Errorf("longitude %v is out of range [-180, 180]", p.Longitude)
+	}
+
+	return nil
+}
+
+// IsZero reports whether the point is 0,0, which the registry treats as "no
+// location" because the coordinates are optional on an instance.
+func (p Point) IsZero() bool {
+	return p.Latitude == 0 && p.Longitude == 0
+}
+
+// DistanceKm returns the great-circle distance between a and b in kilometres
+// using the haversine formula.
+func DistanceKm(a, b Point) float64 {
+	lat1 := radians(a.Latitude)
+	lat2 := radians(b.Latitude)
+	dLat := lat2 - lat1
+	dLon := radians(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
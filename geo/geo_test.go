@@ -0,0 +1,27 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistanceKm(t *testing.T) {
+	sanFrancisco := Point{Latitude: 37.7749, Longitude: -122.4194}
+	newYork := Point{Latitude: 40.7128, Longitude: -74.0060}
+	london := Point{Latitude: 51.5074, Longitude: -0.1278}
+
+	assert.InDelta(t, 4129, DistanceKm(sanFrancisco, newYork), 10)
+	assert.InDelta(t, 5570, DistanceKm(newYork, london), 10)
+	assert.InDelta(t, DistanceKm(london, newYork), DistanceKm(newYork, london), 1e-9)
+	assert.Equal(t, 0.0, DistanceKm(london, london))
+
+	// antipodal points are half the circumference apart
+	assert.InDelta(t, 20015, DistanceKm(Point{0, 0}, Point{0, 180}), 1)
+}
+
+func TestPointValidate(t *testing.T) {
+	assert.NoError(t, Point{Latitude: -90, Longitude: 180}.Validate())
+	assert.Error(t, Point{Latitude: 500, Longitude: 0}.Validate())
+	assert.Error(t, Point{Latitude: 0, Longitude: -181}.Validate())
+}
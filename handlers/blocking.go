@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"DirectoryService/models"
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limits on the wait of a blocking query.
+const (
+	defaultBlockingWait = 5 * time.Minute
+	maxBlockingWait     = 10 * time.Minute
+)
+
+// indexPollInterval is how often the registry index is reread, while a
+// blocking query or watch is waiting, to notice changes made through other
+// registry nodes, which the local feed does not see.
+const indexPollInterval = time.Second
+
+// indexHeader carries the registry index on read responses.
+const indexHeader = "X-Registry-Index"
+
+// blockingQuery implements blocking queries for a read endpoint. The response
+// carries the registry index, which the store persists, in X-Registry-Index.
+// When the request passes ?index=N the call blocks until a change past N is
+// relevant to the endpoint, or until ?wait= (default 5m, at most 10m)
+// elapses, and the endpoint then answers with its current state. Changes the
+// local feed cannot account for count as relevant; those made through other
+// registry nodes end the wait within two index polls. An index ahead of the
+// registry's means the index was reset, as when its database is restored:
+// the call returns at once with the current, lower index, and the client
+// should start over from it.
+//
+// It returns false after writing an error response.
+func (s *Server) blockingQuery(
+	w http.ResponseWriter, r *http.Request, relevant func(models.Event) bool,
+) bool {
+	if s.Feed == nil {
+		return true
+	}
+
+	query := r.URL.Query()
+	var current uint64
+	var err error
+	if raw := query.Get("index"); raw == "" {
+		current, err = s.Store.Index(r.Context())
+	} else {
+		index, parseErr := strconv.ParseUint(raw, 10, 64)
+		if parseErr != nil {
+			badRequest(w, r, "Invalid index")
+			return false
+		}
+		wait, parseErr := parseWait(query.Get("wait"))
+		if parseErr != nil {
+			badRequest(w, r, "Invalid wait")
+			return false
+		}
+
+		// The wait outlives the server's write timeout
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + 10*time.Second))
+
+		ctx, cancel := context.WithTimeout(r.Context(), wait)
+		defer cancel()
+		current, err = s.waitForChange(ctx, index, relevant)
+	}
+	if err != nil {
+		writeStoreError(w, r, err)
+		return false
+	}
+
+	w.Header().Set(indexHeader, strconv.FormatUint(current, 10))
+	return true
+}
+
+// waitForChange blocks until a relevant change follows index, the registry
+// index goes back past it, or ctx is done, and returns the registry index it
+// last saw.
+func (s *Server) waitForChange(
+	ctx context.Context, index uint64, relevant func(models.Event) bool,
+) (uint64, error) {
+	defer s.followIndex()()
+
+	// The registry index is read once, to answer an index from before a reset
+	// and changes the feed has yet to see; the index poller moves the feed
+	// past later changes made through other registry nodes
+	seen := s.Feed.Index()
+	current, err := s.Store.Index(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return index, nil
+		}
+		return 0, err
+	}
+	if current < index || (current > index && s.changedSince(index, current, relevant)) {
+		return current, nil
+	}
+	index = current
+
+	for {
+		next := s.Feed.Wait(ctx, seen)
+		switch {
+		case ctx.Err() != nil:
+			return index, nil
+		case next < seen:
+			// the registry index was reset
+			return next, nil
+		case next > index:
+			if s.changedSince(index, next, relevant) {
+				return next, nil
+			}
+			index = next
+		}
+		seen = next
+	}
+}
+
+// changedSince reports whether a change after index, up to current, is
+// relevant. Changes the local feed does not hold, because it discarded them,
+// has yet to publish them or they were made through another registry node,
+// count as relevant.
+func (s *Server) changedSince(index, current uint64, relevant func(models.Event) bool) bool {
+	events, ok := s.Feed.Since(index)
+	if !ok || len(events) == 0 || events[len(events)-1].Index < current {
+		return true
+	}
+	for _, event := range events {
+		if event.Index > current {
+			break
+		}
+		if relevant(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWait parses the wait parameter of a blocking query: a duration such
+// as "30s" or a number of seconds.
+func parseWait(raw string) (time.Duration, error) {
+	if raw == "" {
+		return defaultBlockingWait, nil
+	}
+
+	wait, err := time.ParseDuration(raw)
+	if err != nil {
+		seconds, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return 0, err
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+	if wait <= 0 {
+		return defaultBlockingWait, nil
+	}
+
+	return min(wait, maxBlockingWait), nil
+}
+
+// isServiceEvent reports whether event changed a service rather than an instance.
+func isServiceEvent(event models.Event) bool {
+	switch event.Type {
+	case models.EventServiceRegistered, models.EventServiceUpdated, models.EventServiceDeleted:
+		return true
+	default:
+		return false
+	}
+}
+
+// indexPoller runs the index poll of a server while any blocking query or
+// watch is waiting, so that the registry index is read once per interval
+// however many are.
+type indexPoller struct {
+	mu      sync.Mutex
+	waiters int
+	stop    context.CancelFunc
+}
+
+// followIndex registers a waiter with the server's index poller, starting it
+// for the first, and returns the function that unregisters it, stopping the
+// poller after the last.
+func (s *Server) followIndex() func() {
+	p := &s.poller
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.waiters++
+	if p.waiters == 1 {
+		ctx, cancel := context.WithCancel(context.Background())
+		p.stop = cancel
+		go s.pollIndex(ctx)
+	}
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.waiters--
+		if p.waiters == 0 {
+			p.stop()
+			p.stop = nil
+		}
+	}
+}
+
+// pollIndex moves the feed past the changes made through other registry
+// nodes, which only the persisted registry index records, until ctx is done;
+// waiters on the feed then start over. Local changes reach the feed just
+// after they commit, so an index the feed has not reached is only skipped to
+// when it still has not at the next poll.
+func (s *Server) pollIndex(ctx context.Context) {
+	ticker := time.NewTicker(indexPollInterval)
+	defer ticker.Stop()
+
+	var unseen uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if unseen > s.Feed.Index() {
+			s.Feed.SkipTo(unseen)
+		}
+
+		// the feed never passes the persisted index, unless it was reset
+		seen := s.Feed.Index()
+		current, err := s.Store.Index(ctx)
+		switch {
+		case err != nil:
+			unseen = 0
+		case current < seen:
+			s.Feed.SkipTo(current)
+			unseen = 0
+		case current > seen:
+			unseen = current
+		default:
+			unseen = 0
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"DirectoryService/industry"
+	"DirectoryService/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler to list the services of an industry category, such as NAICS:52 or
+// ISIC:J, and of every category below it, a page at a time. It accepts the
+// filter, sort and paging parameters of ListServicesHandler.
+func (s *Server) CategoryServicesHandler(w http.ResponseWriter, r *http.Request) {
+	category, err := industry.Lookup(mux.Vars(r)["code"])
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, models.CodeNotFound, "No industry category "+mux.Vars(r)["code"])
+		return
+	}
+
+	query, err := parseServiceQuery(r.URL.Query())
+	if err != nil {
+		badRequest(w, r, err.Error())
+		return
+	}
+	query.Categories = industry.Subtree(category)
+
+	s.listServices(w, r, query)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"DirectoryService/models"
+	"DirectoryService/validate"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxBodyBytes bounds request bodies when Server.MaxBodyBytes is zero.
+const DefaultMaxBodyBytes = 1 << 20
+
+// decodeJSON decodes the request body into v. It rejects unknown fields,
+// trailing data and bodies over the server's size limit. An empty body
+// returns io.EOF; other decoding failures wrap errBadPayload.
+func (s *Server) decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	limit := s.MaxBodyBytes
+	if limit <= 0 {
+		limit = DefaultMaxBodyBytes
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(v)
+	if err == nil && decoder.More() {
+		err = errors.New("unexpected data after the JSON value")
+	}
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil, errors.Is(err, io.EOF), errors.As(err, &tooLarge):
+		return err
+	default:
+		return fmt.Errorf("%w: %v", errBadPayload, err)
+	}
+}
+
+// decodePayload decodes a payload read by decodeJSON into v, rejecting unknown
+// fields as decodeJSON does. Failures wrap errBadPayload.
+func decodePayload(payload json.RawMessage, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", errBadPayload, err)
+	}
+	return nil
+}
+
+// writeRequestError answers a request whose payload was rejected, falling
+// back to writeStoreError for errors returned by the store.
+func writeRequestError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	var fields validate.Errors
+	switch {
+	case errors.As(err, &tooLarge):
+		writeError(
+			w, r, http.StatusRequestEntityTooLarge, models.CodePayloadTooLarge,
+			fmt.Sprintf("The request body exceeds %d bytes", tooLarge.Limit),
+		)
+	case errors.Is(err, io.EOF):
+		badPayload(w, r, "The request body is empty")
+	case errors.Is(err, errBadPayload):
+		badPayload(w, r, err.Error())
+	case errors.Is(err, errIDMismatch), errors.Is(err, errIDAssigned),
+		errors.Is(err, models.ErrNoAddress):
+		badPayload(w, r, err.Error())
+	case errors.As(err, &fields):
+		writeValidationError(w, r, fields)
+	default:
+		writeStoreError(w, r, err)
+	}
+}
+
+// writeValidationError writes a 422 problem listing the invalid fields.
+func writeValidationError(w http.ResponseWriter, r *http.Request, fields validate.Errors) {
+	problem := newProblem(
+		w, r, http.StatusUnprocessableEntity, models.CodeInvalidValue,
+		"The request has invalid fields",
+	)
+	problem.Errors = fields
+	writeProblem(w, problem)
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"DirectoryService/db"
+	"DirectoryService/geo"
+	"DirectoryService/labels"
+	"DirectoryService/models"
+	"DirectoryService/semver"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// discoveredVersion pairs a discovered instance with its parsed version.
+type discoveredVersion struct {
+	instance models.DiscoveredInstance
+	version  semver.Version
+}
+
+// Handler to discover the instances of a service by name (or service_id) and
+// version. A version of "1", "1.2" or "1.2.3" returns instances at that version
+// or newer, highest version first. Pre-release instances are only returned
+// when the version asks for that pre-release or prerelease=true is given.
+//
+// When the caller passes lat and lon, instances are ordered by great-circle
+// distance instead (nearest first, instances without a location last) and
+// max_distance_km drops instances that are further away.
+//
+// A label selector such as selector=env=prod,!canary keeps the instances whose
+// labels match it.
+//
+// Discovery supports blocking queries with ?index=N&wait=D.
+func (s *Server) DiscoverHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	origin, maxDistance, err := parseProximity(
+		query.Get("lat"), query.Get("lon"), query.Get("max_distance_km"),
+	)
+	if err != nil {
+		badRequest(w, r, err.Error())
+		return
+	}
+
+	var constraint *semver.Constraint
+	if version := query.Get("version"); version != "" {
+		c, err := semver.ParseConstraint(version)
+		if err != nil {
+			badRequest(w, r, err.Error())
+			return
+		}
+		constraint = &c
+	}
+	allowPreRelease := query.Get("prerelease") == "true"
+
+	selector, err := labels.Parse(query.Get("selector"))
+	if err != nil {
+		badRequest(w, r, err.Error())
+		return
+	}
+
+	// Block on changes to the requested service, including one registered
+	// under the requested name while the query waits
+	serviceID, name := query.Get("service_id"), query.Get("name")
+	var id uuid.UUID
+	if serviceID != "" {
+		id, err = uuid.Parse(serviceID)
+	}
+	if err != nil || (serviceID == "" && name == "") {
+		badRequest(w, r, "Either name or a valid service_id is required")
+		return
+	}
+	relevant := func(event models.Event) bool {
+		if serviceID != "" {
+			return event.ServiceID == id
+		}
+		return strings.EqualFold(event.ServiceName, name)
+	}
+	if !s.blockingQuery(w, r, relevant) {
+		return
+	}
+
+	services, err := s.discoverServices(r, serviceID, name)
+	if err != nil {
+		if err == errBadPayload {
+			badRequest(w, r, "Either name or a valid service_id is required")
+			return
+		}
+		writeStoreError(w, r, err)
+		return
+	}
+
+	matches := make([]discoveredVersion, 0)
+	for _, service := range services {
+		instances, err := s.Store.ListServiceInstances(
+			r.Context(), db.InstanceFilter{
+				ServiceID:    service.ServiceID,
+				HealthStatus: models.HealthStatus(query.Get("health_status")),
+				Labels:       selector,
+			},
+		)
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+
+		for _, instance := range instances {
+			version, err := semver.Parse(instance.Version)
+			if err != nil {
+				// instances registered before versions were validated
+				continue
+			}
+			if constraint != nil && !constraint.Match(version, allowPreRelease) {
+				continue
+			}
+			if constraint == nil && version.IsPreRelease() && !allowPreRelease {
+				continue
+			}
+
+			discovered := models.DiscoveredInstance{
+				ServiceInstance: instance,
+				ServiceName:     service.Name,
+			}
+			location := geo.Point{Latitude: instance.Latitude, Longitude: instance.Longitude}
+			if origin != nil && !location.IsZero() {
+				distance := geo.DistanceKm(*origin, location)
+				discovered.DistanceKm = &distance
+			}
+			if maxDistance != nil &&
+				(discovered.DistanceKm == nil || *discovered.DistanceKm > *maxDistance) {
+				continue
+			}
+
+			matches = append(matches, discoveredVersion{instance: discovered, version: version})
+		}
+	}
+
+	sort.SliceStable(
+		matches, func(i, j int) bool {
+			di, dj := matches[i].instance.DistanceKm, matches[j].instance.DistanceKm
+			if origin != nil && (di != nil) != (dj != nil) {
+				return di != nil
+			}
+			if di != nil && dj != nil && *di != *dj {
+				return *di < *dj
+			}
+			return matches[i].version.Compare(matches[j].version) > 0
+		},
+	)
+
+	result := make([]models.DiscoveredInstance, len(matches))
+	for i, match := range matches {
+		result[i] = match.instance
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// discoverServices resolves the services a discovery request refers to.
+func (s *Server) discoverServices(r *http.Request, serviceID, name string) (
+	[]models.Service, error,
+) {
+	if serviceID != "" {
+		id, err := uuid.Parse(serviceID)
+		if err != nil {
+			return nil, errBadPayload
+		}
+		service, err := s.Store.GetService(r.Context(), id)
+		if err != nil {
+			return nil, err
+		}
+		return []models.Service{*service}, nil
+	}
+
+	if name == "" {
+		return nil, errBadPayload
+	}
+
+	services, err := s.Store.ListServices(r.Context(), db.ServiceQuery{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no service named %q: %w", name, db.ErrNotFound)
+	}
+
+	return services, nil
+}
+
+// parseProximity parses the caller location and optional search radius.
+func parseProximity(lat, lon, maxDistance string) (*geo.Point, *float64, error) {
+	if lat == "" && lon == "" {
+		if maxDistance != "" {
+			return nil, nil, fmt.Errorf("max_distance_km requires lat and lon")
+		}
+		return nil, nil, nil
+	}
+	if lat == "" || lon == "" {
+		return nil, nil, fmt.Errorf("lat and lon must be given together")
+	}
+
+	var origin geo.Point
+	var err error
+	if origin.Latitude, err = strconv.ParseFloat(lat, 64); err != nil {
+		return nil, nil, fmt.Errorf("invalid lat %q", lat)
+	}
+	if origin.Longitude, err = strconv.ParseFloat(lon, 64); err != nil {
+		return nil, nil, fmt.Errorf("invalid lon %q", lon)
+	}
+	if err := origin.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	if maxDistance == "" {
+		return &origin, nil, nil
+	}
+	radius, err := strconv.ParseFloat(maxDistance, 64)
+	if err != nil || radius < 0 {
+		return nil, nil, fmt.Errorf("invalid max_distance_km %q", maxDistance)
+	}
+
+	return &origin, &radius, nil
+}
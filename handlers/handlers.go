@@ -2,59 +2,371 @@ package handlers
 
 import (
 	"DirectoryService/db"
-	"context"
+	"DirectoryService/labels"
+	"DirectoryService/validate"
 	"encoding/json"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"io"
 	"net/http"
+	"slices"
+	"time"
 
 	"DirectoryService/models"
 )
 
-// Handler function for registering a service
+// Handler function for registering a service. The registry assigns the
+// service ID, so a payload naming one is rejected.
 func (s *Server) RegisterServiceHandler(w http.ResponseWriter, r *http.Request) {
 	var service models.Service
-	if err := json.NewDecoder(r.Body).Decode(&service); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if err := s.decodeJSON(w, r, &service); err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+	if service.ServiceID != uuid.Nil {
+		writeRequestError(w, r, errIDAssigned)
+		return
+	}
+	if err := service.Validate(); err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+	// ratings come from reviews and usage statistics from the registry, not
+	// from the registrant
+	service.ClientRating = 0
+	service.TransactionCount = 0
+	service.AvgResponseTime = 0
+
+	newService, err := s.Store.RegisterService(r.Context(), service)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newService)
+}
+
+// Handler to list services a page at a time. The industry_category, owner,
+// min_rating and label selector query parameters filter the list, and sort
+// orders it by name, rating, created_at or transaction_count; a leading "-"
+// sorts descending.
+// When more services follow, X-Next-Cursor holds the cursor parameter of the
+// next page and a Link header points to it.
+func (s *Server) ListServicesHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := parseServiceQuery(r.URL.Query())
+	if err != nil {
+		badRequest(w, r, err.Error())
+		return
+	}
+	s.listServices(w, r, query)
+}
+
+// listServices writes a page of the services query selects, after waiting
+// for a change when the request is a blocking query.
+func (s *Server) listServices(w http.ResponseWriter, r *http.Request, query db.ServiceQuery) {
+	if !s.blockingQuery(w, r, isServiceEvent) {
 		return
 	}
 
-	dbCtx := db.NewDbCtx(s.DB)
+	// One extra service tells whether there is a next page
+	pageSize := query.Limit
+	query.Limit++
+	services, err := s.Store.ListServices(r.Context(), query)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	if services == nil {
+		services = []models.Service{}
+	}
+	if len(services) > pageSize {
+		services = services[:pageSize]
+		setNextPage(w, r, query.CursorAfter(services[pageSize-1]).Encode())
+	}
 
-	ctx := context.Background()
-	newService, err := dbCtx.RegisterService(ctx, service)
+	writeJSON(w, http.StatusOK, services)
+}
+
+// Handler to get a service by ID
+func (s *Server) GetServiceHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID, err := uuid.Parse(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		badRequest(w, r, "Invalid service ID")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(newService); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	service, err := s.Store.GetService(r.Context(), serviceID)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
 	}
+
+	writeJSON(w, http.StatusOK, service)
+}
+
+// Handler to replace a service (PUT) or update some of its fields (PATCH).
+// PATCH decodes the payload over the stored service, so omitted fields keep
+// their current values.
+func (s *Server) UpdateServiceHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		badRequest(w, r, "Invalid service ID")
+		return
+	}
+
+	// The body is read and checked before the transaction starts, so a slow
+	// client does not hold the store while it sends it
+	var payload json.RawMessage
+	if err := s.decodeJSON(w, r, &payload); err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+	var service models.Service
+	if err := decodePayload(payload, &service); err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+	if service.ServiceID != uuid.Nil && service.ServiceID != serviceID {
+		writeRequestError(w, r, errIDMismatch)
+		return
+	}
+	if r.Method == http.MethodPut {
+		// the industry category is checked against the stored one below
+		err := withoutRule(service.Validate(), "industry_category", "industry")
+		if err != nil {
+			writeRequestError(w, r, err)
+			return
+		}
+	}
+
+	var updated *models.Service
+	err = s.Store.WithTx(
+		r.Context(), func(tx db.Store) error {
+			existing, err := tx.GetServiceForUpdate(r.Context(), serviceID)
+			if err != nil {
+				return err
+			}
+			merged := service
+			if r.Method == http.MethodPatch {
+				merged = *existing
+				if err := decodePayload(payload, &merged); err != nil {
+					return err
+				}
+			}
+
+			merged.ServiceID = serviceID
+			err = merged.Validate()
+			if merged.IndustryCategory == existing.IndustryCategory {
+				// services registered before categories were checked may
+				// keep their free text category until it is changed
+				err = withoutRule(err, "industry_category", "industry")
+			}
+			if err != nil {
+				return err
+			}
+
+			updated, err = tx.UpdateService(r.Context(), merged)
+			return err
+		},
+	)
+	if err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// withoutRule drops the failures of rule on field from the validation error
+// err, returning nil when none remain.
+func withoutRule(err error, field, rule string) error {
+	var fields validate.Errors
+	if !errors.As(err, &fields) {
+		return err
+	}
+	fields = slices.DeleteFunc(
+		slices.Clone(fields), func(fe validate.FieldError) bool {
+			return fe.Field == field && fe.Rule == rule
+		},
+	)
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// Handler to delete a service
+func (s *Server) DeleteServiceHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		badRequest(w, r, "Invalid service ID")
+		return
+	}
+
+	if err := s.Store.DeleteService(r.Context(), serviceID); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler to get the usage statistics of a service and a count of its
+// instances by health status
+func (s *Server) ServiceStatisticsHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		badRequest(w, r, "Invalid service ID")
+		return
+	}
+
+	service, err := s.Store.GetService(r.Context(), serviceID)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	instances, err := s.Store.ListServiceInstances(
+		r.Context(), db.InstanceFilter{ServiceID: serviceID},
+	)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	byHealth := make(map[models.HealthStatus]int)
+	for _, instance := range instances {
+		byHealth[instance.HealthStatus]++
+	}
+
+	writeJSON(
+		w, http.StatusOK, models.ServiceStatistics{
+			ServiceID:        service.ServiceID,
+			TransactionCount: service.TransactionCount,
+			AvgResponseTime:  service.AvgResponseTime,
+			Details: map[string]interface{}{
+				"client_rating":       service.ClientRating,
+				"instance_count":      len(instances),
+				"instances_by_health": byHealth,
+			},
+		},
+	)
 }
 
 // Handler to register and instance of a service
 func (s *Server) RegisterServiceInstanceHandler(w http.ResponseWriter, r *http.Request) {
 	var serviceInstance models.ServiceInstance
-	if err := json.NewDecoder(r.Body).Decode(&serviceInstance); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if err := s.decodeJSON(w, r, &serviceInstance); err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+	if err := serviceInstance.Validate(); err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+	if serviceInstance.LeaseTTL == 0 {
+		serviceInstance.LeaseTTL = models.Duration(s.DefaultLeaseTTL)
+	}
+
+	newInstance, err := s.Store.CreateServiceInstance(r.Context(), serviceInstance)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newInstance)
+}
+
+// Handler to get a service instance by ID
+func (s *Server) GetServiceInstanceHandler(w http.ResponseWriter, r *http.Request) {
+	instanceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		badRequest(w, r, "Invalid instance ID")
+		return
+	}
+
+	instance, err := s.Store.GetServiceInstance(r.Context(), instanceID)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, instance)
+}
+
+// Handler to list service instances, optionally filtered by the service_id,
+// version, health_status and host query parameters
+func (s *Server) ListServiceInstancesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := db.InstanceFilter{
+		Version:      query.Get("version"),
+		HealthStatus: models.HealthStatus(query.Get("health_status")),
+		Host:         query.Get("host"),
+	}
+	if serviceID := query.Get("service_id"); serviceID != "" {
+		id, err := uuid.Parse(serviceID)
+		if err != nil {
+			badRequest(w, r, "Invalid service ID")
+			return
+		}
+		filter.ServiceID = id
+	}
+	selector, err := labels.Parse(query.Get("selector"))
+	if err != nil {
+		badRequest(w, r, err.Error())
+		return
+	}
+	filter.Labels = selector
+
+	relevant := func(event models.Event) bool {
+		return !isServiceEvent(event) &&
+			(filter.ServiceID == uuid.Nil || event.ServiceID == filter.ServiceID)
+	}
+	if !s.blockingQuery(w, r, relevant) {
 		return
 	}
 
-	dbCtx := db.NewDbCtx(s.DB)
+	instances, err := s.Store.ListServiceInstances(r.Context(), filter)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
 
-	ctx := context.Background()
-	newInstance, err := dbCtx.CreateServiceInstance(ctx, serviceInstance)
+	writeJSON(w, http.StatusOK, instances)
+}
+
+// Handler to list the instances of a service
+func (s *Server) ListInstancesOfServiceHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		badRequest(w, r, "Invalid service ID")
+		return
+	}
+	selector, err := labels.Parse(r.URL.Query().Get("selector"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		badRequest(w, r, err.Error())
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(newInstance); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	relevant := func(event models.Event) bool { return event.ServiceID == serviceID }
+	if !s.blockingQuery(w, r, relevant) {
+		return
 	}
+
+	if _, err := s.Store.GetService(r.Context(), serviceID); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	instances, err := s.Store.ListServiceInstances(
+		r.Context(), db.InstanceFilter{ServiceID: serviceID, Labels: selector},
+	)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, instances)
 }
 
 // Handler to remove a service instance
@@ -62,18 +374,45 @@ func (s *Server) RemoveServiceInstanceHandler(w http.ResponseWriter, r *http.Req
 	vars := mux.Vars(r)
 	instanceID, err := uuid.Parse(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid instance ID", http.StatusBadRequest)
+		badRequest(w, r, "Invalid instance ID")
 		return
 	}
 
-	dbCtx := db.NewDbCtx(s.DB)
-
-	ctx := context.Background()
-	err = dbCtx.RemoveServiceInstance(ctx, instanceID)
+	err = s.Store.RemoveServiceInstance(r.Context(), instanceID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStoreError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// HeartbeatHandler renews the lease of a service instance. The optional body
+// {"ttl": "30s"} replaces the instance's lease TTL.
+func (s *Server) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		badRequest(w, r, "Invalid instance ID")
+		return
+	}
+
+	var heartbeat struct {
+		TTL models.Duration `json:"ttl"`
+	}
+	err = s.decodeJSON(w, r, &heartbeat)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeRequestError(w, r, err)
+		return
+	}
+
+	instance, err := s.Store.RenewLease(
+		r.Context(), instanceID, time.Duration(heartbeat.TTL), time.Now(),
+	)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, instance)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"DirectoryService/models"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// HealthChecker runs an immediate health check of a service instance and
+// records the outcome. health.Monitor implements it.
+type HealthChecker interface {
+	Check(ctx context.Context, instanceID uuid.UUID) (models.HealthStatus, error)
+}
+
+// healthReport is the body of a health update and of a health check response.
+type healthReport struct {
+	HealthStatus models.HealthStatus `json:"health_status"`
+}
+
+// UpdateInstanceHealthHandler records the health an instance reports for
+// itself, e.g. {"health_status": "down"} while it drains.
+func (s *Server) UpdateInstanceHealthHandler(w http.ResponseWriter, r *http.Request) {
+	instanceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		badRequest(w, r, "Invalid instance ID")
+		return
+	}
+
+	var report healthReport
+	if err := s.decodeJSON(w, r, &report); err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+	switch report.HealthStatus {
+	case models.HealthStarting, models.HealthUp, models.HealthDown, models.HealthUnknown:
+	default:
+		badRequest(w, r, "Invalid health_status")
+		return
+	}
+
+	instance, err := s.Store.UpdateServiceInstanceHealth(
+		r.Context(), instanceID, report.HealthStatus, time.Now(),
+	)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, instance)
+}
+
+// HealthCheckHandler probes an instance now instead of waiting for the
+// monitor's next round, and returns the resulting status.
+func (s *Server) HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
+	instanceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		badRequest(w, r, "Invalid instance ID")
+		return
+	}
+	if s.Checker == nil {
+		writeError(w, r, http.StatusServiceUnavailable, models.CodeUnavailable, "Health checks are not enabled")
+		return
+	}
+
+	status, err := s.Checker.Check(r.Context(), instanceID)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, healthReport{HealthStatus: status})
+}
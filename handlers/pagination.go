@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"DirectoryService/db"
+	"DirectoryService/industry"
+	"DirectoryService/labels"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Page sizes of the service listing.
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// nextCursorHeader carries the cursor of the next page of a listing.
+const nextCursorHeader = "X-Next-Cursor"
+
+// parseServiceQuery reads the filter, sort and paging parameters of the
+// service listing.
+func parseServiceQuery(values url.Values) (db.ServiceQuery, error) {
+	query := db.ServiceQuery{
+		IndustryCategory: industry.Normalize(values.Get("industry_category")),
+		Owner:            values.Get("owner"),
+		Limit:            DefaultPageSize,
+	}
+
+	selector, err := labels.Parse(values.Get("selector"))
+	if err != nil {
+		return query, err
+	}
+	query.Labels = selector
+
+	if raw := values.Get("min_rating"); raw != "" {
+		rating, err := strconv.ParseFloat(raw, 64)
+		if err != nil || rating < 0 || rating > 5 {
+			return query, errors.New("Invalid min_rating: must be a number from 0 to 5")
+		}
+		query.MinRating = rating
+	}
+
+	if raw := values.Get("sort"); raw != "" {
+		query.Descending = strings.HasPrefix(raw, "-")
+		query.Sort = db.ServiceSort(strings.TrimPrefix(raw, "-"))
+		switch query.Sort {
+		case db.SortByName, db.SortByRating, db.SortByCreatedAt, db.SortByTransactionCount:
+		default:
+			return query, fmt.Errorf(
+				"Invalid sort: must be %s, %s, %s or %s, optionally prefixed with -",
+				db.SortByName, db.SortByRating, db.SortByCreatedAt, db.SortByTransactionCount,
+			)
+		}
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return query, errors.New("Invalid limit: must be a positive integer")
+		}
+		query.Limit = min(limit, MaxPageSize)
+	}
+
+	if raw := values.Get("cursor"); raw != "" {
+		cursor, err := db.DecodeServiceCursor(raw)
+		if err != nil {
+			return query, errors.New("Invalid cursor")
+		}
+		sort := query.Sort
+		if sort == "" {
+			sort = db.SortByName
+		}
+		if cursor.Sort != sort || cursor.Descending != query.Descending {
+			return query, errors.New("Invalid cursor: it belongs to a different sort order")
+		}
+		query.After = cursor
+	}
+
+	return query, nil
+}
+
+// setNextPage advertises the next page of a listing: its cursor in
+// X-Next-Cursor and its URL in a Link header. Blocking query parameters are
+// dropped from the link.
+func setNextPage(w http.ResponseWriter, r *http.Request, cursor string) {
+	values := r.URL.Query()
+	values.Set("cursor", cursor)
+	values.Del("index")
+	values.Del("wait")
+	next := url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+
+	w.Header().Set(nextCursorHeader, cursor)
+	w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+}
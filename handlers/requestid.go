@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// requestIDHeader carries the request ID in requests and responses.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// withRequestID gives every request an ID: the client's X-Request-ID when it
+// is a reasonable value, otherwise a new one. The ID is echoed in the
+// response header and included in error responses.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(requestIDHeader)
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+
+			w.Header().Set(requestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+		},
+	)
+}
+
+// requestID returns the ID of the request. Requests that did not pass through
+// withRequestID are given one now.
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		return id
+	}
+
+	id := w.Header().Get(requestIDHeader)
+	if id == "" {
+		id = uuid.NewString()
+		w.Header().Set(requestIDHeader, id)
+	}
+	return id
+}
+
+// validRequestID reports whether a client-supplied request ID is short and
+// printable ASCII, so that it is safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"DirectoryService/db"
+	"DirectoryService/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// Request errors, also raised inside a Store transaction.
+var (
+	errBadPayload = errors.New("invalid request payload")
+	errIDMismatch = errors.New("ID in payload does not match the URL")
+	errIDAssigned = errors.New("service_id is assigned by the registry")
+)
+
+// problemTypeBase prefixes the error code to form the problem type URI.
+const problemTypeBase = "/problems/"
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// writeError writes an RFC 7807 problem response.
+func writeError(
+	w http.ResponseWriter, r *http.Request, status int, code models.ErrorCode, detail string,
+) {
+	writeProblem(w, newProblem(w, r, status, code, detail))
+}
+
+// newProblem builds the problem response for a failed request.
+func newProblem(
+	w http.ResponseWriter, r *http.Request, status int, code models.ErrorCode, detail string,
+) models.Problem {
+	return models.Problem{
+		Type:      problemTypeBase + string(code),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: requestID(w, r),
+	}
+}
+
+// writeProblem writes a problem response.
+func writeProblem(w http.ResponseWriter, problem models.Problem) {
+	w.Header().Set("Content-Type", models.ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+// storeProblems maps the Store's sentinel errors to a status, an error code
+// and a fixed detail. The detail never includes the error's own message,
+// which can quote stored values or database text.
+var storeProblems = []struct {
+	err    error
+	status int
+	code   models.ErrorCode
+	detail string
+}{
+	{db.ErrNotFound, http.StatusNotFound, models.CodeNotFound, "The requested resource does not exist"},
+	{db.ErrConflict, http.StatusConflict, models.CodeConflict, "The request conflicts with the current state of the registry"},
+	{db.ErrReference, http.StatusUnprocessableEntity, models.CodeInvalidReference, "The request refers to a resource that does not exist"},
+	{db.ErrInvalid, http.StatusUnprocessableEntity, models.CodeInvalidValue, "The request has a value the registry does not accept"},
+}
+
+// writeStoreError maps a Store error to the matching status and error code.
+// The error and its database cause are logged with the request ID, and
+// unexpected errors are answered with a generic 500, so that database
+// messages do not reach clients.
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	id := requestID(w, r)
+	if cause := db.Cause(err); cause != nil {
+		log.Printf("request %s: %s %s: %v: %v", id, r.Method, r.URL.Path, err, cause)
+	} else {
+		log.Printf("request %s: %s %s: %v", id, r.Method, r.URL.Path, err)
+	}
+
+	for _, p := range storeProblems {
+		if errors.Is(err, p.err) {
+			writeError(w, r, p.status, p.code, p.detail)
+			return
+		}
+	}
+	writeError(
+		w, r, http.StatusInternalServerError, models.CodeInternal,
+		"The registry failed to handle the request",
+	)
+}
+
+// badRequest writes a 400 problem for an invalid path or query parameter.
+func badRequest(w http.ResponseWriter, r *http.Request, detail string) {
+	writeError(w, r, http.StatusBadRequest, models.CodeBadRequest, detail)
+}
+
+// badPayload writes a 400 problem for a body that cannot be decoded.
+func badPayload(w http.ResponseWriter, r *http.Request, detail string) {
+	writeError(w, r, http.StatusBadRequest, models.CodeInvalidPayload, detail)
+}
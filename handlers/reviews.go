@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"DirectoryService/models"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Handler to review a service. A reviewer has one review of a service, so
+// reviewing it again edits that review. The service's client_rating is
+// recomputed from its reviews.
+func (s *Server) ReviewServiceHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		badRequest(w, r, "Invalid service ID")
+		return
+	}
+
+	var review models.Review
+	if err := s.decodeJSON(w, r, &review); err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+	if review.ServiceID != uuid.Nil && review.ServiceID != serviceID {
+		writeRequestError(w, r, errIDMismatch)
+		return
+	}
+	review.ServiceID = serviceID
+	review.Reviewer = strings.TrimSpace(review.Reviewer)
+	if err := review.Validate(); err != nil {
+		writeRequestError(w, r, err)
+		return
+	}
+
+	stored, err := s.Store.PutReview(r.Context(), review)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stored)
+}
+
+// Handler to list the reviews of a service, most recently updated first
+func (s *Server) ListReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		badRequest(w, r, "Invalid service ID")
+		return
+	}
+
+	relevant := func(event models.Event) bool { return event.ServiceID == serviceID }
+	if !s.blockingQuery(w, r, relevant) {
+		return
+	}
+
+	if _, err := s.Store.GetService(r.Context(), serviceID); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	reviews, err := s.Store.ListReviews(r.Context(), serviceID)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reviews)
+}
@@ -1,28 +1,85 @@
 package handlers
 
 import (
+	"DirectoryService/db"
+	"DirectoryService/models"
 	"github.com/gorilla/mux"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"net/http"
+	"time"
 )
 
-// Server struct with DB connection pool
+// Server struct with the registry store
 type Server struct {
-	DB *pgxpool.Pool
+	Store db.Store
+
+	// DefaultLeaseTTL is applied to instances registered without a lease_ttl.
+	// Zero leaves such instances without a lease.
+	DefaultLeaseTTL time.Duration
+
+	// Feed publishes the store's changes to watchers. It is nil unless the
+	// store is a db.WatchedStore.
+	Feed *db.ChangeFeed
+
+	// Checker runs on-demand health checks. Nil disables them.
+	Checker HealthChecker
+
+	// MaxBodyBytes bounds request bodies. Defaults to DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+
+	poller indexPoller
 }
 
 // create function to create new server struct
-func NewServer(pool *pgxpool.Pool) *Server {
-	return &Server{
-		pool,
+func NewServer(store db.Store) *Server {
+	server := &Server{
+		Store: store,
+	}
+	if watched, ok := store.(*db.WatchedStore); ok {
+		server.Feed = watched.Feed()
 	}
+
+	return server
 }
 
 // NewRouter sets up the REST routes.
 func (s *Server) NewRouter() *mux.Router {
 	r := mux.NewRouter()
 	r.HandleFunc("/services", s.RegisterServiceHandler).Methods("POST")
+	r.HandleFunc("/services", s.ListServicesHandler).Methods("GET")
+	r.HandleFunc("/services/{id}", s.GetServiceHandler).Methods("GET")
+	r.HandleFunc("/services/{id}", s.UpdateServiceHandler).Methods("PUT", "PATCH")
+	r.HandleFunc("/services/{id}", s.DeleteServiceHandler).Methods("DELETE")
+	r.HandleFunc("/services/{id}/instances", s.ListInstancesOfServiceHandler).Methods("GET")
+	r.HandleFunc("/services/{id}/statistics", s.ServiceStatisticsHandler).Methods("GET")
+	r.HandleFunc("/services/{id}/reviews", s.ReviewServiceHandler).Methods("POST")
+	r.HandleFunc("/services/{id}/reviews", s.ListReviewsHandler).Methods("GET")
 	r.HandleFunc("/service-instances", s.RegisterServiceInstanceHandler).Methods("POST")
+	r.HandleFunc("/service-instances", s.ListServiceInstancesHandler).Methods("GET")
+	r.HandleFunc("/service-instances/{id}", s.GetServiceInstanceHandler).Methods("GET")
 	r.HandleFunc("/service-instances/{id}", s.RemoveServiceInstanceHandler).Methods("DELETE")
+	r.HandleFunc("/service-instances/{id}/heartbeat", s.HeartbeatHandler).Methods("PUT")
+	r.HandleFunc("/service-instances/{id}/health", s.UpdateInstanceHealthHandler).Methods("PUT")
+	r.HandleFunc("/service-instances/{id}/health-check", s.HealthCheckHandler).Methods("POST")
+	r.HandleFunc("/search", s.SearchHandler).Methods("GET")
+	r.HandleFunc("/categories/{code}/services", s.CategoryServicesHandler).Methods("GET")
+	r.HandleFunc("/discover", s.DiscoverHandler).Methods("GET")
+	r.HandleFunc("/watch", s.WatchHandler).Methods("GET")
+
+	// Unmatched requests get problem responses too
+	r.NotFoundHandler = withRequestID(http.HandlerFunc(routeNotFound))
+	r.MethodNotAllowedHandler = withRequestID(http.HandlerFunc(methodNotAllowed))
+	r.Use(withRequestID)
 
 	return r
 }
+
+func routeNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusNotFound, models.CodeNotFound, "No route matches "+r.URL.Path)
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(
+		w, r, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed,
+		r.Method+" is not supported on "+r.URL.Path,
+	)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"DirectoryService/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Result counts of the search API.
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// maxSearchLength bounds the search text, in characters.
+const maxSearchLength = 256
+
+// Handler to search the service catalog. The q query parameter holds the
+// words to look for in the name, description, owner, industry category and
+// tags of services; limit bounds the number of results, best first.
+func (s *Server) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	text := strings.TrimSpace(r.URL.Query().Get("q"))
+	if text == "" {
+		badRequest(w, r, "The q parameter is required")
+		return
+	}
+	if utf8.RuneCountInString(text) > maxSearchLength {
+		badRequest(w, r, "Invalid q: must be at most "+strconv.Itoa(maxSearchLength)+" characters long")
+		return
+	}
+
+	limit := DefaultSearchLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			badRequest(w, r, "Invalid limit: must be a positive integer")
+			return
+		}
+		limit = min(n, MaxSearchLimit)
+	}
+
+	results, err := s.Store.SearchServices(r.Context(), text, limit)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	if results == nil {
+		results = []models.SearchResult{}
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
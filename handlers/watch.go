@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"DirectoryService/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// watchKeepAlive is how often an idle watch stream sends a comment line so
+// that proxies do not close it.
+const watchKeepAlive = 15 * time.Second
+
+// WatchHandler streams registry changes as Server-Sent Events. Each event
+// carries its change index as the SSE id; a client that reconnects with a
+// Last-Event-ID header (or last_event_id query parameter) receives the
+// changes it missed. When those changes are no longer retained the stream
+// sends a "reset" event, after which the client should re-list the registry.
+//
+// The stream carries the changes made through this registry node. Changes
+// made through other nodes sharing its database cannot be replayed: the
+// stream notices them from the gaps they leave in the change indexes, or
+// within two polls of the persisted registry index, which the server's
+// blocking queries and watches share, and sends a reset event. A client
+// resuming after a restart picks up where it left off, since the registry
+// index persists.
+//
+// The service_id and name query parameters restrict the stream to one service.
+func (s *Server) WatchHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Feed == nil {
+		writeError(w, r, http.StatusServiceUnavailable, models.CodeUnavailable, "Watch is not enabled")
+		return
+	}
+
+	query := r.URL.Query()
+	var serviceID uuid.UUID
+	if raw := query.Get("service_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			badRequest(w, r, "Invalid service_id")
+			return
+		}
+		serviceID = id
+	}
+	name := query.Get("name")
+
+	last := s.Feed.Index()
+	resume := r.Header.Get("Last-Event-ID")
+	if resume == "" {
+		resume = query.Get("last_event_id")
+	}
+	if resume != "" {
+		index, err := strconv.ParseUint(resume, 10, 64)
+		if err != nil {
+			badRequest(w, r, "Invalid Last-Event-ID")
+			return
+		}
+		last = index
+	}
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": watching\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	defer s.followIndex()()
+
+	ctx := r.Context()
+	idle := time.Now()
+	for {
+		events, ok := s.Feed.Since(last)
+		if !ok {
+			last = s.Feed.Index()
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: {\"index\":%d}\n\n", last, models.EventReset, last)
+			idle = time.Now()
+		}
+		for _, event := range events {
+			last = event.Index
+			if !watchMatches(event, serviceID, name) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Index, event.Type, data)
+			idle = time.Now()
+		}
+		if time.Since(idle) >= watchKeepAlive {
+			fmt.Fprint(w, ": keepalive\n\n")
+			idle = time.Now()
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		// the index poller moves the feed past changes made through other
+		// registry nodes
+		waitCtx, cancel := context.WithDeadline(ctx, idle.Add(watchKeepAlive))
+		s.Feed.Wait(waitCtx, last)
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// watchMatches reports whether event concerns the watched service. Zero
+// values match every service.
+func watchMatches(event models.Event, serviceID uuid.UUID, name string) bool {
+	return (serviceID == uuid.Nil || event.ServiceID == serviceID) &&
+		(name == "" || strings.EqualFold(event.ServiceName, name))
+}
@@ -1,14 +1,26 @@
 package handlers_test
 
 import (
-	"DirectoryService/cfg"
 	"DirectoryService/db"
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"DirectoryService/handlers"
 	"DirectoryService/models"
@@ -16,18 +28,10 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// setupTestServer runs the handlers against the in-memory store so the suite
+// does not need a database.
 func setupTestServer(t *testing.T) *handlers.Server {
-	config, err := cfg.LoadConfig()
-	if err != nil {
-		t.Fatalf("Failed to load cfg: %v", err)
-	}
-
-	pool, err := db.ConnectDB(config)
-	if err != nil {
-		t.Fatalf("Failed to connect to database: %v", err)
-	}
-
-	return handlers.NewServer(pool)
+	return handlers.NewServer(db.NewWatchedStore(db.NewMemStore(), db.NewChangeFeed(0)))
 }
 
 func TestRegisterServiceHandler(t *testing.T) {
@@ -37,7 +41,7 @@ func TestRegisterServiceHandler(t *testing.T) {
 		Name:             "Test Service",
 		Description:      "A test service",
 		OwnerInfo:        "Owner",
-		IndustryCategory: "Category",
+		IndustryCategory: "NAICS:5415",
 		ClientRating:     4.5,
 	}
 
@@ -66,7 +70,7 @@ func TestRegisterServiceInstanceHandler(t *testing.T) {
 		Name:             "Parent Service",
 		Description:      "Test service 1",
 		OwnerInfo:        "Owner",
-		IndustryCategory: "Category",
+		IndustryCategory: "NAICS:5415",
 		ClientRating:     4.2,
 	}
 
@@ -127,7 +131,7 @@ func TestRemoveServiceInstanceHandler(t *testing.T) {
 		Name:             "Parent Service 2",
 		Description:      "Test service 2",
 		OwnerInfo:        "Owner",
-		IndustryCategory: "Category",
+		IndustryCategory: "NAICS:5415",
 		ClientRating:     4.4,
 	}
 
@@ -197,3 +201,1317 @@ func TestRemoveServiceInstanceHandler(t *testing.T) {
 
 	assert.Equal(t, http.StatusNoContent, deleteRR.Code)
 }
+
+// registerTestService registers a service through the router and returns it.
+func registerTestService(t *testing.T, router http.Handler, service models.Service) models.Service {
+	body, _ := json.Marshal(service)
+	req := httptest.NewRequest("POST", "/services", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to register service: %d %s", rr.Code, rr.Body.String())
+	}
+
+	var responseService models.Service
+	if err := json.NewDecoder(rr.Body).Decode(&responseService); err != nil {
+		t.Fatal(err)
+	}
+
+	return responseService
+}
+
+func TestServiceCRUDHandlers(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+
+	service := registerTestService(
+		t, router, models.Service{
+			Name:             "CRUD Service",
+			Description:      "Test service",
+			OwnerInfo:        "Owner",
+			IndustryCategory: "NAICS:5415",
+			ClientRating:     4.0,
+		},
+	)
+
+	// List
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/services", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var services []models.Service
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&services))
+	assert.Len(t, services, 1)
+
+	// Get
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/services/"+service.ServiceID.String(), nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// PATCH keeps fields that are not in the payload
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(
+		rr, httptest.NewRequest(
+			"PATCH", "/services/"+service.ServiceID.String(),
+			bytes.NewBufferString(`{"description":"Patched"}`),
+		),
+	)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var patched models.Service
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&patched))
+	assert.Equal(t, service.ServiceID, patched.ServiceID)
+	assert.Equal(t, "CRUD Service", patched.Name)
+	assert.Equal(t, "Patched", patched.Description)
+
+	// PUT replaces the service
+	body, _ := json.Marshal(models.Service{Name: "Replaced Service", ClientRating: 3.0})
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(
+		rr, httptest.NewRequest("PUT", "/services/"+service.ServiceID.String(), bytes.NewBuffer(body)),
+	)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var replaced models.Service
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&replaced))
+	assert.Equal(t, service.ServiceID, replaced.ServiceID)
+	assert.Equal(t, "Replaced Service", replaced.Name)
+	assert.Empty(t, replaced.Description)
+
+	// Delete, then the service is gone
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("DELETE", "/services/"+service.ServiceID.String(), nil))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/services/"+service.ServiceID.String(), nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("DELETE", "/services/"+service.ServiceID.String(), nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestServiceHandlersConflicts(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+
+	service := registerTestService(t, router, models.Service{Name: "Conflict Service"})
+
+	// The registry assigns service IDs, so re-registering one is rejected
+	body, _ := json.Marshal(service)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/services", bytes.NewBuffer(body)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// A service with instances cannot be deleted
+	instance, _ := json.Marshal(
+		models.ServiceInstance{
+			ServiceID: service.ServiceID,
+			Version:   "1.0.0",
+			Host:      "localhost",
+			Port:      8080,
+		},
+	)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/service-instances", bytes.NewBuffer(instance)))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("DELETE", "/services/"+service.ServiceID.String(), nil))
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// Updating an unknown service is not found
+	rr = httptest.NewRecorder()
+	body, _ = json.Marshal(models.Service{Name: "Unknown Service"})
+	router.ServeHTTP(
+		rr, httptest.NewRequest("PUT", "/services/"+uuid.New().String(), bytes.NewBuffer(body)),
+	)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestListServicesHandlerPaging(t *testing.T) {
+	server := setupTestServer(t)
+	router := server.NewRouter()
+
+	// The API rates services from their reviews, so ratings are seeded in the store
+	seed := func(service models.Service) {
+		_, err := server.Store.RegisterService(context.Background(), service)
+		assert.NoError(t, err)
+	}
+	for i, name := range []string{"Echo", "Alpha", "Delta", "Bravo", "Charlie"} {
+		seed(models.Service{Name: name, IndustryCategory: "NAICS:52", ClientRating: float64(i)})
+	}
+	seed(models.Service{Name: "Foxtrot", IndustryCategory: "NAICS:44-45", ClientRating: 5})
+
+	list := func(target string) ([]string, *httptest.ResponseRecorder) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET %s: %d %s", target, rr.Code, rr.Body.String())
+		}
+		var services []models.Service
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&services))
+		var names []string
+		for _, service := range services {
+			names = append(names, service.Name)
+		}
+		return names, rr
+	}
+
+	// Follow the Link header through the filtered listing
+	var pages [][]string
+	target := "/services?industry_category=naics:52&sort=-rating&limit=2"
+	for target != "" {
+		names, rr := list(target)
+		pages = append(pages, names)
+
+		target = ""
+		if link := rr.Header().Get("Link"); link != "" {
+			assert.True(t, strings.HasSuffix(link, `>; rel="next"`), link)
+			target = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+			assert.Contains(t, target, "cursor="+rr.Header().Get("X-Next-Cursor"))
+		}
+	}
+	assert.Equal(t, [][]string{{"Charlie", "Bravo"}, {"Delta", "Alpha"}, {"Echo"}}, pages)
+
+	// The last page has no next page
+	names, rr := list("/services?min_rating=4")
+	assert.Equal(t, []string{"Charlie", "Foxtrot"}, names)
+	assert.Empty(t, rr.Header().Get("Link"))
+	assert.Empty(t, rr.Header().Get("X-Next-Cursor"))
+
+	// Bad parameters are rejected
+	_, rr = list("/services?sort=name&limit=1")
+	cursor := rr.Header().Get("X-Next-Cursor")
+	for _, query := range []string{
+		"sort=popularity", "limit=0", "limit=ten", "min_rating=6", "cursor=garbage",
+		"sort=rating&cursor=" + cursor,
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/services?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		assert.Equal(t, models.CodeBadRequest, decodeProblem(t, rr).Code, query)
+	}
+}
+
+func TestCategoryServicesHandler(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+	for name, category := range map[string]string{
+		"Clearing": "522320", "Cards": "naics:522210", "Consulting": "NAICS:5415", "Platform": "ISIC:6201",
+	} {
+		registerTestService(t, router, models.Service{Name: name, IndustryCategory: category})
+	}
+
+	list := func(target string) []models.Service {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET %s: %d %s", target, rr.Code, rr.Body.String())
+		}
+		var services []models.Service
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&services))
+		return services
+	}
+
+	// Sub-codes are included and responses carry the canonical code and its title
+	services := list("/categories/naics:522/services")
+	if assert.Len(t, services, 2) {
+		assert.Equal(t, "Cards", services[0].Name)
+		assert.Equal(t, "NAICS:522210", services[0].IndustryCategory)
+		assert.Equal(t, "Credit Card Issuing", services[0].IndustryCategoryName)
+		assert.Equal(t, "Clearing", services[1].Name)
+	}
+	assert.Len(t, list("/categories/52/services?limit=1"), 1)
+	assert.Len(t, list("/categories/ISIC:J/services"), 1)
+	assert.Empty(t, list("/categories/ISIC:K/services"))
+
+	// The listing filters still apply
+	assert.Empty(t, list("/categories/NAICS:52/services?min_rating=1"))
+
+	for _, code := range []string{"Finance", "NAICS:999999", "SIC:7372"} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/categories/"+code+"/services", nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code, code)
+		assert.Equal(t, models.CodeNotFound, decodeProblem(t, rr).Code, code)
+	}
+}
+
+func TestUsageStatisticsAreNotClientSupplied(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+	service := registerTestService(
+		t, router, models.Service{Name: "Payments", TransactionCount: 1000, AvgResponseTime: 5},
+	)
+	assert.Zero(t, service.TransactionCount, "registrants cannot claim usage")
+	assert.Zero(t, service.AvgResponseTime)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(
+		rr, httptest.NewRequest(
+			"PATCH", "/services/"+service.ServiceID.String(),
+			strings.NewReader(`{"transaction_count": 1000, "average_response_time": 5}`),
+		),
+	)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var updated models.Service
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &updated))
+	assert.Zero(t, updated.TransactionCount, "updates leave the statistics to the registry")
+	assert.Zero(t, updated.AvgResponseTime)
+}
+
+func TestReviewHandlers(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+	service := registerTestService(t, router, models.Service{Name: "Payments", ClientRating: 5})
+	assert.Zero(t, service.ClientRating, "registrants cannot claim a rating")
+	target := "/services/" + service.ServiceID.String() + "/reviews"
+
+	review := func(body string) (models.Review, *httptest.ResponseRecorder) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", target, strings.NewReader(body)))
+		var review models.Review
+		if rr.Code == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&review))
+		}
+		return review, rr
+	}
+	rated := func() models.Service {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/services/"+service.ServiceID.String(), nil))
+		var service models.Service
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&service))
+		return service
+	}
+
+	first, rr := review(`{"reviewer":" alice ","rating":5,"comment":"Fast"}`)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "alice", first.Reviewer)
+	assert.Equal(t, service.ServiceID, first.ServiceID)
+	assert.Equal(t, 3.33, rated().ClientRating)
+
+	// The same reviewer edits their review instead of adding another
+	edited, rr := review(`{"reviewer":"alice","rating":3}`)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, first.ReviewID, edited.ReviewID)
+	_, rr = review(`{"reviewer":"bob","rating":4}`)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, 3.14, rated().ClientRating)
+	assert.Equal(t, int64(2), rated().ReviewCount)
+
+	// Updating the service does not change its rating
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(
+		rr, httptest.NewRequest(
+			"PATCH", "/services/"+service.ServiceID.String(), strings.NewReader(`{"client_rating":5}`),
+		),
+	)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, 3.14, rated().ClientRating)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var reviews []models.Review
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&reviews))
+	if assert.Len(t, reviews, 2) {
+		assert.Equal(t, "bob", reviews[0].Reviewer)
+		assert.Equal(t, "alice", reviews[1].Reviewer)
+		assert.Equal(t, 3, reviews[1].Rating)
+		assert.Empty(t, reviews[1].Comment, "an edit replaces the whole review")
+	}
+
+	_, rr = review(`{"reviewer":"  ","rating":6}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	fields := make(map[string]string)
+	for _, fe := range decodeProblem(t, rr).Errors {
+		fields[fe.Field] = fe.Rule
+	}
+	assert.Equal(t, map[string]string{"reviewer": "required", "rating": "max"}, fields)
+
+	_, rr = review(`{"service_id":"` + uuid.New().String() + `","reviewer":"carol","rating":4}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	unknown := "/services/" + uuid.New().String() + "/reviews"
+	for _, method := range []string{"GET", "POST"} {
+		rr = httptest.NewRecorder()
+		body := strings.NewReader(`{"reviewer":"carol","rating":4}`)
+		router.ServeHTTP(rr, httptest.NewRequest(method, unknown, body))
+		assert.Equal(t, http.StatusNotFound, rr.Code, method)
+	}
+}
+
+func TestSearchHandler(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+	registerTestService(
+		t, router, models.Service{
+			Name: "Address Validation", Description: "Normalizes postal addresses",
+			Tags: []string{"geo", "postal"},
+		},
+	)
+	registerTestService(t, router, models.Service{Name: "Ledger", Description: "Bookkeeping"})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/search?q=adress+valdation", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var results []models.SearchResult
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&results))
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "Address Validation", results[0].Name)
+		assert.Equal(t, []string{"geo", "postal"}, results[0].Tags)
+		assert.Positive(t, results[0].Score)
+		assert.Equal(t, "<mark>Address</mark> <mark>Validation</mark>", results[0].Highlights["name"])
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/search?q=nothing+matches", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, "[]", rr.Body.String())
+
+	for query, status := range map[string]int{
+		"":                              http.StatusBadRequest,
+		"q=ledger&limit=0":              http.StatusBadRequest,
+		"q=" + strings.Repeat("x", 300): http.StatusBadRequest,
+		"q=the":                         http.StatusUnprocessableEntity,
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/search?"+query, nil))
+		assert.Equal(t, status, rr.Code, query)
+	}
+}
+
+// failingStore fails reads with raw database errors, and with Store errors
+// whose messages quote stored values.
+type failingStore struct {
+	db.Store
+}
+
+func (failingStore) ListServices(context.Context, db.ServiceQuery) ([]models.Service, error) {
+	return nil, errors.New(`failed to list services: ERROR: relation "r1.services" does not exist`)
+}
+
+func (failingStore) GetService(context.Context, uuid.UUID) (*models.Service, error) {
+	return nil, fmt.Errorf("failed to retrieve service: %w: Key (name)=(Secret Payroll)", db.ErrConflict)
+}
+
+// decodeProblem checks that rr is a problem response and decodes it.
+func decodeProblem(t *testing.T, rr *httptest.ResponseRecorder) models.Problem {
+	t.Helper()
+
+	assert.Equal(t, models.ProblemContentType, rr.Header().Get("Content-Type"))
+	var problem models.Problem
+	if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to decode problem: %v", err)
+	}
+	assert.Equal(t, rr.Code, problem.Status)
+	assert.NotEmpty(t, problem.RequestID)
+	assert.Equal(t, rr.Header().Get("X-Request-ID"), problem.RequestID)
+
+	return problem
+}
+
+func TestErrorResponses(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+	service := registerTestService(t, router, models.Service{Name: "Problem Service"})
+
+	send := func(method, target, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		name   string
+		rr     *httptest.ResponseRecorder
+		status int
+		code   models.ErrorCode
+	}{
+		{
+			"unknown service", send("GET", "/services/"+uuid.New().String(), ""),
+			http.StatusNotFound, models.CodeNotFound,
+		},
+		{
+			"unknown field", send("POST", "/services", `{"name":"Payments","colour":"blue"}`),
+			http.StatusBadRequest, models.CodeInvalidPayload,
+		},
+		{
+			"trailing data", send("POST", "/services", `{"name":"Payments"} {}`),
+			http.StatusBadRequest, models.CodeInvalidPayload,
+		},
+		{
+			"oversized body",
+			send("POST", "/services", `{"name":"Payments","description":"`+strings.Repeat("x", 2<<20)+`"}`),
+			http.StatusRequestEntityTooLarge, models.CodePayloadTooLarge,
+		},
+		{
+			"invalid ID", send("GET", "/services/nope", ""),
+			http.StatusBadRequest, models.CodeBadRequest,
+		},
+		{
+			"malformed payload", send("POST", "/services", "{"),
+			http.StatusBadRequest, models.CodeInvalidPayload,
+		},
+		{
+			"client-assigned service ID", send(
+				"POST", "/services", `{"service_id":"`+service.ServiceID.String()+`","name":"Copy"}`,
+			),
+			http.StatusBadRequest, models.CodeInvalidPayload,
+		},
+		{
+			"instance of an unknown service", send(
+				"POST", "/service-instances",
+				`{"service_id":"`+uuid.New().String()+`","version":"1.0.0","host":"10.0.0.1"}`,
+			),
+			http.StatusUnprocessableEntity, models.CodeInvalidReference,
+		},
+		{
+			"invalid version", send(
+				"POST", "/service-instances",
+				`{"service_id":"`+service.ServiceID.String()+`","version":"x","host":"10.0.0.1"}`,
+			),
+			http.StatusUnprocessableEntity, models.CodeInvalidValue,
+		},
+		{
+			"instance without host or url", send(
+				"POST", "/service-instances",
+				`{"service_id":"`+service.ServiceID.String()+`","version":"1.0.0","port":8080}`,
+			),
+			http.StatusBadRequest, models.CodeInvalidPayload,
+		},
+		{
+			"unknown route", send("GET", "/nowhere", ""),
+			http.StatusNotFound, models.CodeNotFound,
+		},
+		{
+			"unsupported method", send("POST", "/discover", ""),
+			http.StatusMethodNotAllowed, models.CodeMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.status, tt.rr.Code)
+				problem := decodeProblem(t, tt.rr)
+				assert.Equal(t, tt.code, problem.Code)
+				assert.Equal(t, "/problems/"+string(tt.code), problem.Type)
+			},
+		)
+	}
+
+	// A client request ID is kept, an unsafe one replaced
+	rr := send("GET", "/services/"+uuid.New().String(), "", "X-Request-ID", "trace-42")
+	assert.Equal(t, "trace-42", decodeProblem(t, rr).RequestID)
+	rr = send("GET", "/services/"+uuid.New().String(), "", "X-Request-ID", "two words")
+	assert.NotEqual(t, "two words", decodeProblem(t, rr).RequestID)
+
+	// Successful responses carry the request ID too
+	rr = send("GET", "/services/"+service.ServiceID.String(), "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("X-Request-ID"))
+}
+
+func TestValidation(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+	service := registerTestService(t, router, models.Service{Name: "Payments"})
+
+	fields := func(method, target, body string) map[string]string {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s %s: expected 422, got %d %s", method, target, rr.Code, rr.Body.String())
+		}
+
+		problem := decodeProblem(t, rr)
+		assert.Equal(t, models.CodeInvalidValue, problem.Code)
+		rules := make(map[string]string)
+		for _, fe := range problem.Errors {
+			rules[fe.Field] = fe.Rule
+		}
+		return rules
+	}
+
+	assert.Equal(
+		t, map[string]string{"name": "required", "client_rating": "max", "health_check.mode": "oneof"},
+		fields("POST", "/services", `{"client_rating":99,"health_check":{"mode":"udp"}}`),
+	)
+	assert.Equal(
+		t, map[string]string{"client_rating": "min"},
+		fields("PATCH", "/services/"+service.ServiceID.String(), `{"client_rating":-1}`),
+	)
+	assert.Equal(
+		t, map[string]string{"name": "required"},
+		fields("PUT", "/services/"+service.ServiceID.String(), `{"description":"no name"}`),
+	)
+	assert.Equal(
+		t, map[string]string{"industry_category": "industry"},
+		fields("PATCH", "/services/"+service.ServiceID.String(), `{"industry_category":"Finance"}`),
+	)
+	assert.Equal(
+		t, map[string]string{
+			"service_id": "required", "version": "semver", "host": "hostname", "port": "min",
+			"url": "url", "latitude": "max", "longitude": "min",
+		},
+		fields(
+			"POST", "/service-instances",
+			`{"version":"v1","host":"bad host","port":-1,"url":"localhost:8080","latitude":500,"longitude":-181}`,
+		),
+	)
+
+	// The stored service is unchanged by rejected updates
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/services/"+service.ServiceID.String(), nil))
+	var stored models.Service
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&stored))
+	assert.Equal(t, "Payments", stored.Name)
+	assert.Zero(t, stored.ClientRating)
+
+	valid := `{"service_id":"` + service.ServiceID.String() +
+		`","version":"1.0.0","host":"10.0.0.7","port":8443,"url":"https://api.example.com/v1","latitude":-33.9,"longitude":151.2}`
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/service-instances", strings.NewReader(valid)))
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func TestUpdateServiceReadsBodyOutsideTransaction(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+	service := registerTestService(t, router, models.Service{Name: "Slow Update"})
+
+	// The client sends the body of its update slowly
+	body, client := io.Pipe()
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("PATCH", "/services/"+service.ServiceID.String(), body))
+		done <- rr
+	}()
+	_, err := io.WriteString(client, `{"description":`)
+	assert.NoError(t, err)
+
+	// Meanwhile other writes go ahead
+	registered := make(chan struct{})
+	go func() {
+		registerTestService(t, router, models.Service{Name: "Other Service"})
+		close(registered)
+	}()
+	select {
+	case <-registered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("registration waited for the update body")
+	}
+
+	_, err = io.WriteString(client, `"Updated"}`)
+	assert.NoError(t, err)
+	assert.NoError(t, client.Close())
+	rr := <-done
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var updated models.Service
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&updated))
+	assert.Equal(t, "Slow Update", updated.Name)
+	assert.Equal(t, "Updated", updated.Description)
+}
+
+func TestUpdateServiceWithLegacyCategory(t *testing.T) {
+	server := setupTestServer(t)
+	router := server.NewRouter()
+	// Registered before categories were checked against NAICS and ISIC
+	service, err := server.Store.RegisterService(
+		context.Background(), models.Service{Name: "Ledger", IndustryCategory: "Finance"},
+	)
+	assert.NoError(t, err)
+	target := "/services/" + service.ServiceID.String()
+
+	update := func(method, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+
+	// The category is kept while other fields change
+	rr := update("PATCH", `{"description":"General ledger"}`)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	rr = update("PUT", `{"name":"Ledger","industry_category":"Finance","owner_info":"Accounts"}`)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var updated models.Service
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&updated))
+	assert.Equal(t, "Finance", updated.IndustryCategory)
+
+	// Changing it requires a valid category
+	rr = update("PATCH", `{"industry_category":"Banking"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	problem := decodeProblem(t, rr)
+	if assert.Len(t, problem.Errors, 1) {
+		assert.Equal(t, "industry", problem.Errors[0].Rule)
+	}
+	rr = update("PATCH", `{"industry_category":"naics:522110"}`)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&updated))
+	assert.Equal(t, "NAICS:522110", updated.IndustryCategory)
+	assert.Equal(t, "Commercial Banking", updated.IndustryCategoryName)
+
+	// Other rules still apply to services with a legacy category
+	_, err = server.Store.UpdateService(
+		context.Background(), models.Service{ServiceID: service.ServiceID, Name: "Ledger", IndustryCategory: "Finance"},
+	)
+	assert.NoError(t, err)
+	rr = update("PATCH", `{"name":""}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	problem = decodeProblem(t, rr)
+	if assert.Len(t, problem.Errors, 1) {
+		assert.Equal(t, "required", problem.Errors[0].Rule)
+	}
+}
+
+func TestInternalErrorsAreNotLeaked(t *testing.T) {
+	router := handlers.NewServer(failingStore{db.NewMemStore()}).NewRouter()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/services", nil))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	problem := decodeProblem(t, rr)
+	assert.Equal(t, models.CodeInternal, problem.Code)
+	assert.NotContains(t, problem.Detail, "relation", "database errors should not reach the client")
+
+	// Store errors are answered with a fixed detail, and logged in full
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/services/"+uuid.New().String(), nil)
+	req.Header.Set("X-Request-ID", "trace-7")
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	problem = decodeProblem(t, rr)
+	assert.Equal(t, "The request conflicts with the current state of the registry", problem.Detail)
+	assert.Contains(t, logged.String(), "request trace-7")
+	assert.Contains(t, logged.String(), "Secret Payroll")
+}
+
+// registerTestInstance registers a service instance through the router and returns it.
+func registerTestInstance(
+	t *testing.T, router http.Handler, instance models.ServiceInstance,
+) models.ServiceInstance {
+	body, _ := json.Marshal(instance)
+	req := httptest.NewRequest("POST", "/service-instances", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Failed to register service instance: %d %s", rr.Code, rr.Body.String())
+	}
+
+	var responseInstance models.ServiceInstance
+	if err := json.NewDecoder(rr.Body).Decode(&responseInstance); err != nil {
+		t.Fatal(err)
+	}
+
+	return responseInstance
+}
+
+func TestServiceInstanceQueryHandlers(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+
+	payments := registerTestService(t, router, models.Service{Name: "Payments"})
+	ledger := registerTestService(t, router, models.Service{Name: "Ledger"})
+
+	first := registerTestInstance(
+		t, router, models.ServiceInstance{
+			ServiceID: payments.ServiceID, Version: "1.0.0", Host: "host-a", Port: 8080,
+		},
+	)
+	registerTestInstance(
+		t, router, models.ServiceInstance{
+			ServiceID: payments.ServiceID, Version: "1.1.0", Host: "host-b", Port: 8080,
+		},
+	)
+	registerTestInstance(
+		t, router, models.ServiceInstance{
+			ServiceID: ledger.ServiceID, Version: "1.0.0", Host: "host-a", Port: 9090,
+		},
+	)
+
+	getInstances := func(target string) []models.ServiceInstance {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, http.StatusOK, rr.Code, target)
+		var instances []models.ServiceInstance
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&instances))
+		return instances
+	}
+
+	assert.Len(t, getInstances("/service-instances"), 3)
+	assert.Len(t, getInstances("/services/"+payments.ServiceID.String()+"/instances"), 2)
+	assert.Len(t, getInstances("/service-instances?service_id="+payments.ServiceID.String()), 2)
+	assert.Len(t, getInstances("/service-instances?host=host-a"), 2)
+	assert.Len(t, getInstances("/service-instances?version=1.1.0"), 1)
+	assert.Len(t, getInstances("/service-instances?health_status=starting"), 3)
+	assert.Empty(t, getInstances("/service-instances?health_status=up"))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/service-instances/"+first.InstanceID.String(), nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var fetched models.ServiceInstance
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&fetched))
+	assert.Equal(t, first.InstanceID, fetched.InstanceID)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/service-instances/"+uuid.New().String(), nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/services/"+uuid.New().String()+"/instances", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestLabelSelectors(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+
+	payments := registerTestService(
+		t, router, models.Service{Name: "payments", Labels: map[string]string{"team": "payments"}},
+	)
+	registerTestService(t, router, models.Service{Name: "ledger", Labels: map[string]string{"team": "finance"}})
+	for _, region := range []string{"eu-west", "eu-north", "us-east"} {
+		registerTestInstance(
+			t, router, models.ServiceInstance{
+				ServiceID: payments.ServiceID, Version: "1.0.0", Host: "localhost", Port: 8080,
+				Labels: map[string]string{"env": "prod", "region": region},
+			},
+		)
+	}
+	registerTestInstance(
+		t, router, models.ServiceInstance{
+			ServiceID: payments.ServiceID, Version: "1.0.0", Host: "localhost", Port: 8081,
+			Labels: map[string]string{"env": "prod", "region": "eu-west", "canary": ""},
+		},
+	)
+
+	get := func(target string, v any) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET %s: %d %s", target, rr.Code, rr.Body.String())
+		}
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(v))
+	}
+	selector := url.QueryEscape("env=prod,region in (eu-west,eu-north),!canary")
+
+	var discovered []models.DiscoveredInstance
+	get("/discover?name=payments&selector="+selector, &discovered)
+	assert.Len(t, discovered, 2)
+	for _, instance := range discovered {
+		assert.NotContains(t, instance.Labels, "canary")
+		assert.Contains(t, []string{"eu-west", "eu-north"}, instance.Labels["region"])
+	}
+
+	var instances []models.ServiceInstance
+	get("/service-instances?selector="+selector, &instances)
+	assert.Len(t, instances, 2)
+	get("/services/"+payments.ServiceID.String()+"/instances?selector=canary", &instances)
+	assert.Len(t, instances, 1)
+
+	var services []models.Service
+	get("/services?selector=team%3Dfinance", &services)
+	if assert.Len(t, services, 1) {
+		assert.Equal(t, "ledger", services[0].Name)
+		assert.Equal(t, map[string]string{"team": "finance"}, services[0].Labels)
+	}
+
+	// An empty value is valid; malformed selectors and labels are rejected
+	get("/services?selector=env%3D", &services)
+	assert.Empty(t, services)
+	for _, target := range []string{
+		"/services?selector=" + url.QueryEscape("region in eu"),
+		"/service-instances?selector=%21",
+		"/discover?name=payments&selector=" + url.QueryEscape("a b"),
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, target)
+		assert.Equal(t, models.CodeBadRequest, decodeProblem(t, rr).Code, target)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(
+		rr, httptest.NewRequest("POST", "/services", strings.NewReader(`{"name":"x","labels":{"bad key":"v"}}`)),
+	)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	if problem := decodeProblem(t, rr); assert.Len(t, problem.Errors, 1) {
+		assert.Equal(t, "labels", problem.Errors[0].Field)
+	}
+}
+
+func TestDiscoverHandlerVersionRules(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+
+	payments := registerTestService(t, router, models.Service{Name: "payments"})
+	versions := []string{"1.1.0", "1.2.0", "1.2.5", "1.3.0-beta.1", "2.0.0", "0.9.0"}
+	for _, version := range versions {
+		registerTestInstance(
+			t, router, models.ServiceInstance{
+				ServiceID: payments.ServiceID, Version: version, Host: "localhost", Port: 8080,
+			},
+		)
+	}
+
+	discover := func(target string) []string {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, http.StatusOK, rr.Code, target)
+		var instances []models.DiscoveredInstance
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&instances))
+		versions := make([]string, len(instances))
+		for i, instance := range instances {
+			assert.Equal(t, "payments", instance.ServiceName)
+			versions[i] = instance.Version
+		}
+		return versions
+	}
+
+	assert.Equal(
+		t, []string{"2.0.0", "1.2.5", "1.2.0", "1.1.0"},
+		discover("/discover?name=payments&version=1"),
+	)
+	assert.Equal(
+		t, []string{"2.0.0", "1.2.5", "1.2.0"}, discover("/discover?name=payments&version=1.2"),
+	)
+	assert.Equal(t, []string{"2.0.0", "1.2.5"}, discover("/discover?name=payments&version=1.2.3"))
+	assert.Equal(
+		t, []string{"2.0.0", "1.3.0-beta.1", "1.2.5"},
+		discover("/discover?name=payments&version=1.2.3&prerelease=true"),
+	)
+	assert.Equal(
+		t, []string{"2.0.0", "1.3.0-beta.1"},
+		discover("/discover?name=PAYMENTS&version=1.3.0-beta"),
+	)
+	assert.Len(t, discover("/discover?service_id="+payments.ServiceID.String()), 5)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/discover?name=payments&version=1.x", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/discover?name=unknown", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	// Instance versions must be semantic versions
+	body, _ := json.Marshal(
+		models.ServiceInstance{ServiceID: payments.ServiceID, Version: "latest", Host: "localhost"},
+	)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/service-instances", bytes.NewBuffer(body)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+}
+
+func TestDiscoverReturnsEndpoints(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+	payments := registerTestService(t, router, models.Service{Name: "payments"})
+
+	registerTestInstance(
+		t, router, models.ServiceInstance{
+			ServiceID: payments.ServiceID,
+			Version:   "1.0.0",
+			Scheme:    "https",
+			Host:      "payments.internal",
+			Port:      8443,
+			BasePath:  "/api",
+			Endpoints: &models.Endpoints{
+				GRPC:   "grpc://payments.internal:9090",
+				Health: "http://payments.internal:8081/healthz",
+			},
+		},
+	)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/discover?name=payments", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var discovered []models.DiscoveredInstance
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&discovered))
+	if assert.Len(t, discovered, 1) {
+		assert.Equal(t, "https://payments.internal:8443/api", discovered[0].Url)
+		assert.Equal(
+			t, &models.Endpoints{
+				HTTPS:  "https://payments.internal:8443/api",
+				GRPC:   "grpc://payments.internal:9090",
+				Health: "http://payments.internal:8081/healthz",
+			}, discovered[0].Endpoints,
+		)
+	}
+
+	// Endpoints must be absolute URLs
+	body := `{"service_id":"` + payments.ServiceID.String() +
+		`","version":"1.0.0","host":"payments.internal","scheme":"ftp","endpoints":{"metrics":"/metrics"}}`
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/service-instances", strings.NewReader(body)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	problem := decodeProblem(t, rr)
+	fields := make([]string, len(problem.Errors))
+	for i, fe := range problem.Errors {
+		fields[i] = fe.Field
+	}
+	assert.ElementsMatch(t, []string{"scheme", "endpoints.metrics"}, fields)
+}
+
+func TestDiscoverHandlerOrdersByDistance(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+
+	payments := registerTestService(t, router, models.Service{Name: "payments"})
+	locations := map[string][2]float64{
+		"london":   {51.5074, -0.1278},
+		"new-york": {40.7128, -74.0060},
+		"paris":    {48.8566, 2.3522},
+		"nowhere":  {0, 0},
+	}
+	for host, location := range locations {
+		registerTestInstance(
+			t, router, models.ServiceInstance{
+				ServiceID: payments.ServiceID,
+				Version:   "1.0.0",
+				Host:      host,
+				Port:      8080,
+				Latitude:  location[0],
+				Longitude: location[1],
+			},
+		)
+	}
+
+	discover := func(target string) []models.DiscoveredInstance {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, http.StatusOK, rr.Code, target)
+		var instances []models.DiscoveredInstance
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&instances))
+		return instances
+	}
+
+	// A caller in Brussels
+	instances := discover("/discover?name=payments&lat=50.8503&lon=4.3517")
+	hosts := make([]string, len(instances))
+	for i, instance := range instances {
+		hosts[i] = instance.Host
+	}
+	assert.Equal(t, []string{"paris", "london", "new-york", "nowhere"}, hosts)
+	assert.InDelta(t, 264, *instances[0].DistanceKm, 5)
+	assert.Nil(t, instances[3].DistanceKm, "instances without a location have no distance")
+
+	instances = discover("/discover?name=payments&lat=50.8503&lon=4.3517&max_distance_km=300")
+	assert.Len(t, instances, 1)
+	assert.Equal(t, "paris", instances[0].Host)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/discover?name=payments&lat=500&lon=0", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestHeartbeatHandler(t *testing.T) {
+	server := setupTestServer(t)
+	server.DefaultLeaseTTL = 30 * time.Second
+	router := server.NewRouter()
+
+	service := registerTestService(t, router, models.Service{Name: "Payments"})
+	instance := registerTestInstance(
+		t, router, models.ServiceInstance{ServiceID: service.ServiceID, Version: "1.0.0", Host: "10.0.0.1"},
+	)
+	assert.Equal(t, models.Duration(30*time.Second), instance.LeaseTTL, "default TTL should apply")
+	assert.NotNil(t, instance.LeaseExpiresAt)
+
+	heartbeat := func(id string, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(
+			"PUT", "/service-instances/"+id+"/heartbeat", bytes.NewBufferString(body),
+		)
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := heartbeat(instance.InstanceID.String(), "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var renewed models.ServiceInstance
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&renewed))
+	assert.False(t, renewed.LeaseExpiresAt.Before(*instance.LeaseExpiresAt), "lease should be extended")
+
+	rr = heartbeat(instance.InstanceID.String(), `{"ttl": "2m"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&renewed))
+	assert.Equal(t, models.Duration(2*time.Minute), renewed.LeaseTTL, "body TTL should replace the lease TTL")
+
+	assert.Equal(t, http.StatusBadRequest, heartbeat(instance.InstanceID.String(), `{"ttl":`).Code)
+	assert.Equal(t, http.StatusBadRequest, heartbeat("not-a-uuid", "").Code)
+	assert.Equal(t, http.StatusNotFound, heartbeat(uuid.New().String(), "").Code)
+
+	server.DefaultLeaseTTL = 0
+	unleased := registerTestInstance(
+		t, router, models.ServiceInstance{ServiceID: service.ServiceID, Version: "1.0.0", Host: "10.0.0.1"},
+	)
+	assert.Nil(t, unleased.LeaseExpiresAt, "no lease without a TTL")
+	assert.Equal(t, http.StatusUnprocessableEntity, heartbeat(unleased.InstanceID.String(), "").Code)
+}
+
+// sseEvent is one event read from a watch stream.
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// readSSEEvent reads the next event from a watch stream, skipping comments.
+func readSSEEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	var event sseEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read watch stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if event.Event != "" {
+				return event
+			}
+		case strings.HasPrefix(line, "id: "):
+			event.ID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.Event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.Data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestWatchHandler(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	ledger := registerTestService(t, router, models.Service{Name: "Ledger"})
+
+	watch := func(target, lastEventID string) (*bufio.Reader, func()) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+target, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		return bufio.NewReader(resp.Body), func() {
+			cancel()
+			resp.Body.Close()
+		}
+	}
+
+	stream, stop := watch("/watch?name=payments", "")
+	defer stop()
+
+	registerTestInstance(t, router, models.ServiceInstance{ServiceID: ledger.ServiceID, Version: "1.0.0", Host: "10.0.0.1"})
+	payments := registerTestService(t, router, models.Service{Name: "Payments"})
+	instance := registerTestInstance(
+		t, router, models.ServiceInstance{ServiceID: payments.ServiceID, Version: "1.0.0", Host: "10.0.0.2"},
+	)
+
+	registered := readSSEEvent(t, stream)
+	assert.Equal(t, "service.registered", registered.Event, "Ledger events should be filtered out")
+	added := readSSEEvent(t, stream)
+	assert.Equal(t, "instance.added", added.Event)
+	var event models.Event
+	assert.NoError(t, json.Unmarshal([]byte(added.Data), &event))
+	assert.Equal(t, instance.InstanceID, event.Instance.InstanceID)
+	assert.Equal(t, "Payments", event.ServiceName)
+
+	// a client reconnecting after the registration receives the instance again
+	resumed, stopResumed := watch("/watch?service_id="+payments.ServiceID.String(), registered.ID)
+	defer stopResumed()
+	replayed := readSSEEvent(t, resumed)
+	assert.Equal(t, added.ID, replayed.ID)
+	assert.Equal(t, "instance.added", replayed.Event)
+
+	// an index the feed has never reached cannot be resumed
+	reset, stopReset := watch("/watch", "9999")
+	defer stopReset()
+	assert.Equal(t, "reset", readSSEEvent(t, reset).Event)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/watch?service_id=nope", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestWatchHandlerResetsOnChangesOfOtherNodes(t *testing.T) {
+	backend := db.NewMemStore()
+	ts := httptest.NewServer(
+		handlers.NewServer(db.NewWatchedStore(backend, db.NewChangeFeed(0))).NewRouter(),
+	)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/watch", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// another registry node on the same database registers a service
+	_, err = backend.RegisterService(ctx, models.Service{Name: "Ledger"})
+	assert.NoError(t, err)
+	_, err = backend.AdvanceIndex(ctx, 1)
+	assert.NoError(t, err)
+
+	reset := readSSEEvent(t, bufio.NewReader(resp.Body))
+	assert.Equal(t, "reset", reset.Event, "the stream cannot replay the change")
+	assert.Equal(t, "1", reset.ID)
+}
+
+func TestWatchersShareIndexPoll(t *testing.T) {
+	backend := &indexCountingStore{Store: db.NewMemStore()}
+	ts := httptest.NewServer(
+		handlers.NewServer(db.NewWatchedStore(backend, db.NewChangeFeed(0))).NewRouter(),
+	)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/watch", nil)
+			resp, err := http.DefaultClient.Do(req)
+			if assert.NoError(t, err) {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, backend.reads.Load(), int32(3), "the index should be polled once per interval")
+}
+
+func TestBlockingQueries(t *testing.T) {
+	router := setupTestServer(t).NewRouter()
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	payments := registerTestService(t, router, models.Service{Name: "Payments"})
+	ledger := registerTestService(t, router, models.Service{Name: "Ledger"})
+
+	get := func(target string) (*http.Response, time.Duration) {
+		start := time.Now()
+		resp, err := http.Get(ts.URL + target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp, time.Since(start)
+	}
+
+	resp, _ := get("/services")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	index := resp.Header.Get("X-Registry-Index")
+	assert.Equal(t, "2", index, "each registration advances the index")
+
+	// nothing changes: the query returns with the same index once the wait elapses
+	resp, elapsed := get("/services?index=" + index + "&wait=50ms")
+	assert.Equal(t, index, resp.Header.Get("X-Registry-Index"))
+	assert.GreaterOrEqual(t, elapsed, 50*time.Millisecond)
+
+	// post registers in the background, where the test cannot fail fatally
+	post := func(target string, v any) {
+		body, _ := json.Marshal(v)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", target, bytes.NewReader(body)))
+	}
+
+	// an instance of another service does not wake a query on Payments
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		post("/service-instances", models.ServiceInstance{ServiceID: ledger.ServiceID, Version: "1.0.0", Host: "10.0.0.1"})
+		time.Sleep(50 * time.Millisecond)
+		post("/service-instances", models.ServiceInstance{ServiceID: payments.ServiceID, Version: "1.0.0", Host: "10.0.0.2"})
+	}()
+	resp, elapsed = get(
+		"/services/" + payments.ServiceID.String() + "/instances?index=" + index + "&wait=5s",
+	)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "4", resp.Header.Get("X-Registry-Index"))
+	assert.GreaterOrEqual(t, elapsed, 100*time.Millisecond, "the Ledger instance should not wake the query")
+	assert.Less(t, elapsed, 5*time.Second, "the Payments instance should wake the query")
+
+	// discovery blocks until a service with the requested name appears
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		post("/services", models.Service{Name: "Billing"})
+	}()
+	resp, elapsed = get("/discover?name=billing&index=4&wait=5s")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "5", resp.Header.Get("X-Registry-Index"))
+	assert.Less(t, elapsed, 5*time.Second)
+
+	// an index from before a restart is answered at once
+	resp, elapsed = get("/service-instances?index=9999&wait=5s")
+	assert.Equal(t, "5", resp.Header.Get("X-Registry-Index"))
+	assert.Less(t, elapsed, time.Second)
+
+	resp, _ = get("/services?index=abc")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = get("/services?index=1&wait=soon")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// indexCountingStore counts the reads of the registry index.
+type indexCountingStore struct {
+	db.Store
+	reads atomic.Int32
+}
+
+func (s *indexCountingStore) Index(ctx context.Context) (uint64, error) {
+	s.reads.Add(1)
+	return s.Store.Index(ctx)
+}
+
+func TestBlockingQueriesShareIndexPoll(t *testing.T) {
+	backend := &indexCountingStore{Store: db.NewMemStore()}
+	router := handlers.NewServer(db.NewWatchedStore(backend, db.NewChangeFeed(0))).NewRouter()
+
+	// each query reads the index once, then they wait on one poll
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", "/services?index=0&wait=2500ms", nil))
+			assert.Equal(t, http.StatusOK, rr.Code)
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, backend.reads.Load(), int32(10+3), "the index should be polled once per interval")
+}
+
+func TestBlockingQueriesSeeOtherNodes(t *testing.T) {
+	backend := db.NewMemStore()
+	router := handlers.NewServer(db.NewWatchedStore(backend, db.NewChangeFeed(0))).NewRouter()
+	registerTestService(t, router, models.Service{Name: "Payments"})
+
+	// another registry node on the same database registers a service, which
+	// only the persisted index records
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		ctx := context.Background()
+		if _, err := backend.RegisterService(ctx, models.Service{Name: "Ledger"}); err == nil {
+			_, _ = backend.AdvanceIndex(ctx, 1)
+		}
+	}()
+
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/services?index=1&wait=5s", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("X-Registry-Index"))
+	assert.Less(t, time.Since(start), 5*time.Second, "the change should end the wait")
+
+	var services []models.Service
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &services))
+	assert.Len(t, services, 2)
+}
@@ -0,0 +1,436 @@
+package health
+
+import (
+	"DirectoryService/db"
+	"DirectoryService/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Options are the registry-wide health check defaults. A service can override
+// them with its models.HealthCheck.
+type Options struct {
+	Interval           time.Duration // time between checks of one instance
+	Timeout            time.Duration // probe timeout
+	HealthyThreshold   int           // consecutive successes before an instance is up
+	UnhealthyThreshold int           // consecutive failures before an instance is down
+	Concurrency        int           // maximum number of probes in flight
+	Tick               time.Duration // how often the scheduler looks for due checks
+}
+
+// DefaultOptions returns the defaults used for zero-valued Options fields.
+func DefaultOptions() Options {
+	return Options{
+		Interval:           30 * time.Second,
+		Timeout:            5 * time.Second,
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 3,
+		Concurrency:        64,
+		Tick:               time.Second,
+	}
+}
+
+// ErrCheckRunning is returned by Check while a check of the instance is
+// already running.
+var ErrCheckRunning = fmt.Errorf("%w: a health check of the instance is already running", db.ErrConflict)
+
+// errUnknown marks probes that could not be performed, as opposed to probes
+// the instance failed.
+var errUnknown = errors.New("health check could not be performed")
+
+// instanceState is the schedule and hysteresis state of one instance.
+type instanceState struct {
+	instance  models.ServiceInstance // as last listed, with the status last recorded
+	check     models.HealthCheck
+	successes int
+	failures  int
+	nextCheck time.Time
+	running   bool
+}
+
+// Monitor periodically probes every registered instance and records its
+// health in the store.
+type Monitor struct {
+	store  db.Store
+	opts   Options
+	client *http.Client
+	dialer *net.Dialer
+	slots  chan struct{}
+
+	mu       sync.Mutex
+	states   map[uuid.UUID]*instanceState
+	nextList time.Time // when schedule next lists the instances
+	wg       sync.WaitGroup
+}
+
+// NewMonitor creates a Monitor for store. Zero-valued options take their
+// DefaultOptions value.
+func NewMonitor(store db.Store, opts Options) *Monitor {
+	defaults := DefaultOptions()
+	if opts.Interval <= 0 {
+		opts.Interval = defaults.Interval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaults.Timeout
+	}
+	if opts.HealthyThreshold <= 0 {
+		opts.HealthyThreshold = defaults.HealthyThreshold
+	}
+	if opts.UnhealthyThreshold <= 0 {
+		opts.UnhealthyThreshold = defaults.UnhealthyThreshold
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaults.Concurrency
+	}
+	if opts.Tick <= 0 {
+		opts.Tick = defaults.Tick
+	}
+
+	return &Monitor{
+		store: store,
+		opts:  opts,
+		client: &http.Client{
+			// The per-check timeout is applied through the request context
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		dialer: &net.Dialer{},
+		slots:  make(chan struct{}, opts.Concurrency),
+		states: make(map[uuid.UUID]*instanceState),
+	}
+}
+
+// Run schedules health checks until ctx is cancelled, then waits for the
+// probes in flight.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.Tick)
+	defer ticker.Stop()
+
+	for {
+		if err := m.schedule(ctx, time.Now()); err != nil {
+			log.Printf("health monitor: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			m.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// schedule starts a probe for every instance that is due, as long as there is
+// a free slot. Instances left over are picked up on a later tick. The
+// instances are listed once per interval; ticks in between only look through
+// the states.
+func (m *Monitor) schedule(ctx context.Context, now time.Time) error {
+	if !now.Before(m.nextList) {
+		if err := m.list(ctx, now); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, st := range m.states {
+		if st.running || now.Before(st.nextCheck) {
+			continue
+		}
+
+		select {
+		case m.slots <- struct{}{}:
+		default:
+			return nil // all slots busy
+		}
+
+		st.running = true
+		st.nextCheck = now.Add(time.Duration(st.check.Interval))
+
+		m.wg.Add(1)
+		go func(instance models.ServiceInstance, check models.HealthCheck) {
+			defer m.wg.Done()
+			defer func() { <-m.slots }()
+
+			_, err := m.check(ctx, instance, check)
+			if err != nil && ctx.Err() == nil && !errors.Is(err, db.ErrNotFound) {
+				log.Printf("health monitor: instance %s: %v", instance.InstanceID, err)
+			}
+		}(st.instance, st.check)
+	}
+
+	return nil
+}
+
+// list refreshes the states from the registered instances and their
+// services' health check settings. New instances are due at once.
+func (m *Monitor) list(ctx context.Context, now time.Time) error {
+	instances, err := m.store.ListServiceInstances(ctx, db.InstanceFilter{})
+	if err != nil {
+		return fmt.Errorf("failed to list instances: %w", err)
+	}
+	services, err := m.healthChecks(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[uuid.UUID]bool, len(instances))
+	for _, instance := range instances {
+		seen[instance.InstanceID] = true
+
+		st, ok := m.states[instance.InstanceID]
+		if !ok {
+			st = &instanceState{}
+			m.states[instance.InstanceID] = st
+		}
+		if !st.running {
+			st.instance = instance
+		}
+		st.check = m.effective(services[instance.ServiceID])
+	}
+
+	// forget instances that have been removed
+	for id, st := range m.states {
+		if !seen[id] && !st.running {
+			delete(m.states, id)
+		}
+	}
+
+	m.nextList = now.Add(m.opts.Interval)
+	return nil
+}
+
+// Check probes one instance immediately, records the result and returns the
+// resulting health status. It fails with ErrCheckRunning while a scheduled or
+// manual check of the instance is running.
+func (m *Monitor) Check(ctx context.Context, instanceID uuid.UUID) (models.HealthStatus, error) {
+	instance, err := m.store.GetServiceInstance(ctx, instanceID)
+	if err != nil {
+		return "", err
+	}
+	service, err := m.store.GetService(ctx, instance.ServiceID)
+	if err != nil {
+		return "", err
+	}
+	check := m.effective(service.HealthCheck)
+
+	m.mu.Lock()
+	st, ok := m.states[instanceID]
+	if !ok {
+		st = &instanceState{}
+		m.states[instanceID] = st
+	}
+	if st.running {
+		m.mu.Unlock()
+		return "", ErrCheckRunning
+	}
+	st.instance, st.check = *instance, check
+	st.running = true
+	st.nextCheck = time.Now().Add(time.Duration(check.Interval))
+	m.mu.Unlock()
+
+	updated, err := m.check(ctx, *instance, check)
+	if err != nil {
+		return "", err
+	}
+
+	return updated.HealthStatus, nil
+}
+
+// check probes the instance, applies the thresholds and stores the outcome.
+// The caller marks the instance's state running; check clears it.
+func (m *Monitor) check(
+	ctx context.Context, instance models.ServiceInstance, check models.HealthCheck,
+) (*models.ServiceInstance, error) {
+	defer func() {
+		m.mu.Lock()
+		if st := m.states[instance.InstanceID]; st != nil {
+			st.running = false
+		}
+		m.mu.Unlock()
+	}()
+
+	probeErr := m.probe(ctx, instance, check)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	m.mu.Lock()
+	st := m.states[instance.InstanceID]
+	if st == nil {
+		st = &instanceState{}
+		m.states[instance.InstanceID] = st
+	}
+	status := st.record(instance.HealthStatus, probeErr, check)
+	st.instance.HealthStatus = status
+	m.mu.Unlock()
+
+	updated, err := m.store.UpdateServiceInstanceHealth(ctx, instance.InstanceID, status, time.Now())
+	if errors.Is(err, db.ErrNotFound) {
+		// the instance was removed since it was listed
+		m.mu.Lock()
+		delete(m.states, instance.InstanceID)
+		m.mu.Unlock()
+	}
+	return updated, err
+}
+
+// record applies the outcome of a probe and returns the new status. The status
+// only flips to up or down after the configured number of consecutive results.
+func (st *instanceState) record(
+	current models.HealthStatus, probeErr error, check models.HealthCheck,
+) models.HealthStatus {
+	switch {
+	case errors.Is(probeErr, errUnknown):
+		st.successes, st.failures = 0, 0
+		return models.HealthUnknown
+	case probeErr == nil:
+		st.successes++
+		st.failures = 0
+		if st.successes >= check.HealthyThreshold {
+			return models.HealthUp
+		}
+	default:
+		st.failures++
+		st.successes = 0
+		if st.failures >= check.UnhealthyThreshold {
+			return models.HealthDown
+		}
+	}
+
+	if current == models.HealthUnknown {
+		// keep reporting unknown until the thresholds decide either way
+		return models.HealthUnknown
+	}
+	return current
+}
+
+// effective merges a service's health check settings over the defaults.
+func (m *Monitor) effective(check *models.HealthCheck) models.HealthCheck {
+	effective := models.HealthCheck{
+		Mode:               models.HealthCheckHTTP,
+		Path:               "/health",
+		Interval:           models.Duration(m.opts.Interval),
+		Timeout:            models.Duration(m.opts.Timeout),
+		HealthyThreshold:   m.opts.HealthyThreshold,
+		UnhealthyThreshold: m.opts.UnhealthyThreshold,
+	}
+	if check == nil {
+		return effective
+	}
+
+	if check.Mode != "" {
+		effective.Mode = check.Mode
+	}
+	if check.Path != "" {
+		effective.Path = check.Path
+	}
+	if check.Interval > 0 {
+		effective.Interval = check.Interval
+	}
+	if check.Timeout > 0 {
+		effective.Timeout = check.Timeout
+	}
+	if check.HealthyThreshold > 0 {
+		effective.HealthyThreshold = check.HealthyThreshold
+	}
+	if check.UnhealthyThreshold > 0 {
+		effective.UnhealthyThreshold = check.UnhealthyThreshold
+	}
+
+	return effective
+}
+
+// healthChecks returns the health check settings of every service.
+func (m *Monitor) healthChecks(ctx context.Context) (map[uuid.UUID]*models.HealthCheck, error) {
+	services, err := m.store.ListServices(ctx, db.ServiceQuery{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	checks := make(map[uuid.UUID]*models.HealthCheck, len(services))
+	for _, service := range services {
+		checks[service.ServiceID] = service.HealthCheck
+	}
+
+	return checks, nil
+}
+
+// probe performs one HTTP or TCP check. It returns nil when the instance is
+// healthy and an error wrapping errUnknown when the check could not be made.
+func (m *Monitor) probe(
+	ctx context.Context, instance models.ServiceInstance, check models.HealthCheck,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(check.Timeout))
+	defer cancel()
+
+	switch check.Mode {
+	case models.HealthCheckTCP:
+		if instance.Host == "" || instance.Port <= 0 {
+			return fmt.Errorf("%w: instance has no host and port", errUnknown)
+		}
+		conn, err := m.dialer.DialContext(
+			ctx, "tcp", net.JoinHostPort(instance.Host, strconv.Itoa(instance.Port)),
+		)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	case models.HealthCheckHTTP:
+		target, err := healthURL(instance, check.Path)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUnknown, err)
+		}
+		resp, err := m.client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return fmt.Errorf("health endpoint returned %s", resp.Status)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("%w: unsupported mode %q", errUnknown, check.Mode)
+	}
+}
+
+// healthURL returns the instance's health endpoint when it names one, and
+// otherwise builds the URL from the instance URL, falling back to
+// http://host:port.
+func healthURL(instance models.ServiceInstance, path string) (string, error) {
+	if instance.Endpoints != nil && instance.Endpoints.Health != "" {
+		return instance.Endpoints.Health, nil
+	}
+
+	base := strings.TrimSuffix(instance.Url, "/")
+	if base == "" {
+		if instance.Host == "" || instance.Port <= 0 {
+			return "", fmt.Errorf("%w: instance has no URL, host or port", errUnknown)
+		}
+		base = "http://" + net.JoinHostPort(instance.Host, strconv.Itoa(instance.Port))
+	}
+
+	return base + "/" + strings.TrimPrefix(path, "/"), nil
+}
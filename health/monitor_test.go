@@ -0,0 +1,269 @@
+package health
+
+import (
+	"DirectoryService/db"
+	"DirectoryService/models"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// registerInstance registers a service and one instance reachable at rawURL.
+func registerInstance(
+	t *testing.T, store db.Store, rawURL string, check *models.HealthCheck,
+) *models.ServiceInstance {
+	ctx := context.Background()
+
+	service, err := store.RegisterService(ctx, models.Service{Name: "Test Service", HealthCheck: check})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(u.Port())
+
+	instance, err := store.CreateServiceInstance(
+		ctx, models.ServiceInstance{
+			ServiceID: service.ServiceID,
+			Version:   "1.0.0",
+			Host:      u.Hostname(),
+			Port:      port,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return instance
+}
+
+func TestCheckHTTPAppliesThresholds(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/status", r.URL.Path)
+				if !healthy.Load() {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	store := db.NewMemStore()
+	instance := registerInstance(
+		t, store, server.URL, &models.HealthCheck{Path: "/status", UnhealthyThreshold: 2},
+	)
+	monitor := NewMonitor(store, Options{HealthyThreshold: 2})
+	ctx := context.Background()
+
+	status, err := monitor.Check(ctx, instance.InstanceID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.HealthStarting, status, "one success is below the threshold")
+
+	status, err = monitor.Check(ctx, instance.InstanceID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.HealthUp, status)
+
+	healthy.Store(false)
+	status, _ = monitor.Check(ctx, instance.InstanceID)
+	assert.Equal(t, models.HealthUp, status, "one failure is below the threshold")
+	status, _ = monitor.Check(ctx, instance.InstanceID)
+	assert.Equal(t, models.HealthDown, status)
+
+	stored, err := store.GetServiceInstance(ctx, instance.InstanceID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.HealthDown, stored.HealthStatus)
+	assert.WithinDuration(t, time.Now(), stored.LastChecked, time.Minute)
+}
+
+func TestCheckHTTPUsesHealthEndpoint(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/internal/healthz" {
+					w.WriteHeader(http.StatusNotFound)
+				}
+			},
+		),
+	)
+	defer server.Close()
+
+	store := db.NewMemStore()
+	ctx := context.Background()
+	service, err := store.RegisterService(
+		ctx, models.Service{Name: "Test Service", HealthCheck: &models.HealthCheck{Path: "/status"}},
+	)
+	assert.NoError(t, err)
+	instance, err := store.CreateServiceInstance(
+		ctx, models.ServiceInstance{
+			ServiceID: service.ServiceID,
+			Version:   "1.0.0",
+			Url:       "http://127.0.0.1:1",
+			Endpoints: &models.Endpoints{Health: server.URL + "/internal/healthz"},
+		},
+	)
+	assert.NoError(t, err)
+
+	status, err := NewMonitor(store, Options{HealthyThreshold: 1}).Check(ctx, instance.InstanceID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.HealthUp, status, "the health endpoint should be probed instead of the URL")
+}
+
+func TestCheckTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := db.NewMemStore()
+	instance := registerInstance(
+		t, store, "tcp://"+listener.Addr().String(),
+		&models.HealthCheck{Mode: models.HealthCheckTCP, HealthyThreshold: 1, UnhealthyThreshold: 1},
+	)
+	monitor := NewMonitor(store, Options{Timeout: time.Second})
+	ctx := context.Background()
+
+	status, err := monitor.Check(ctx, instance.InstanceID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.HealthUp, status)
+
+	listener.Close()
+	status, err = monitor.Check(ctx, instance.InstanceID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.HealthDown, status)
+}
+
+func TestCheckUnsupportedModeIsUnknown(t *testing.T) {
+	store := db.NewMemStore()
+	instance := registerInstance(
+		t, store, "http://127.0.0.1:1", &models.HealthCheck{Mode: "grpc"},
+	)
+
+	status, err := NewMonitor(store, Options{}).Check(context.Background(), instance.InstanceID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.HealthUnknown, status)
+}
+
+func TestScheduleBoundsConcurrency(t *testing.T) {
+	var inFlight, maxInFlight int32
+	release := make(chan struct{})
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&inFlight, 1)
+				for {
+					m := atomic.LoadInt32(&maxInFlight)
+					if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+						break
+					}
+				}
+				<-release
+				atomic.AddInt32(&inFlight, -1)
+			},
+		),
+	)
+	defer server.Close()
+
+	store := db.NewMemStore()
+	for i := 0; i < 5; i++ {
+		registerInstance(t, store, server.URL, nil)
+	}
+
+	monitor := NewMonitor(
+		store, Options{Concurrency: 2, Timeout: 5 * time.Second, HealthyThreshold: 1},
+	)
+	ctx := context.Background()
+
+	assert.NoError(t, monitor.schedule(ctx, time.Now()))
+	assert.Eventually(
+		t, func() bool { return atomic.LoadInt32(&inFlight) == 2 }, time.Second, 10*time.Millisecond,
+	)
+
+	// further ticks cannot start more probes while the slots are busy
+	assert.NoError(t, monitor.schedule(ctx, time.Now()))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxInFlight))
+
+	close(release)
+	monitor.wg.Wait()
+
+	instances, err := store.ListServiceInstances(ctx, db.InstanceFilter{})
+	assert.NoError(t, err)
+	checked := 0
+	for _, instance := range instances {
+		if instance.HealthStatus == models.HealthUp {
+			checked++
+		}
+	}
+	assert.Equal(t, 2, checked, "only the probes that got a slot should have run")
+}
+
+// countingStore counts the instance listings of the store it wraps.
+type countingStore struct {
+	db.Store
+	listings int32
+}
+
+func (s *countingStore) ListServiceInstances(
+	ctx context.Context, filter db.InstanceFilter,
+) ([]models.ServiceInstance, error) {
+	atomic.AddInt32(&s.listings, 1)
+	return s.Store.ListServiceInstances(ctx, filter)
+}
+
+func TestScheduleListsOncePerInterval(t *testing.T) {
+	store := &countingStore{Store: db.NewMemStore()}
+	registerInstance(t, store, "http://127.0.0.1:1", nil)
+
+	monitor := NewMonitor(store, Options{Interval: 10 * time.Second, Timeout: 100 * time.Millisecond})
+	ctx := context.Background()
+	start := time.Now()
+
+	for tick := 0; tick < 5; tick++ {
+		assert.NoError(t, monitor.schedule(ctx, start.Add(time.Duration(tick)*time.Second)))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&store.listings), "ticks within the interval should not list")
+
+	assert.NoError(t, monitor.schedule(ctx, start.Add(10*time.Second)))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&store.listings))
+	monitor.wg.Wait()
+}
+
+func TestCheckDoesNotOverlapScheduledCheck(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-release }),
+	)
+	defer server.Close()
+
+	store := db.NewMemStore()
+	instance := registerInstance(t, store, server.URL, nil)
+	monitor := NewMonitor(store, Options{Timeout: 5 * time.Second, HealthyThreshold: 1})
+	ctx := context.Background()
+
+	assert.NoError(t, monitor.schedule(ctx, time.Now()))
+	_, err := monitor.Check(ctx, instance.InstanceID)
+	assert.ErrorIs(t, err, ErrCheckRunning, "a manual check should not overlap the scheduled one")
+	assert.ErrorIs(t, err, db.ErrConflict)
+
+	close(release)
+	monitor.wg.Wait()
+
+	status, err := monitor.Check(ctx, instance.InstanceID)
+	assert.NoError(t, err, "the check can run once the scheduled one is done")
+	assert.Equal(t, models.HealthUp, status)
+}
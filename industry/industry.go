@@ -0,0 +1,114 @@
+// Package industry resolves the industry categories of services against the
+// NAICS 2022 and ISIC Rev.4 classifications, which are embedded in the
+// binary.
+//
+// A category is written SYSTEM:CODE, as in "NAICS:522320" or "ISIC:J". The
+// system is matched ignoring case, and a bare number such as "5415" is a
+// NAICS code. The canonical form has the system in upper case.
+package industry
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// System is an industry classification.
+type System string
+
+const (
+	NAICS System = "NAICS" // North American Industry Classification System, 2022
+	ISIC  System = "ISIC"  // International Standard Industrial Classification, Rev.4
+)
+
+// ErrUnknown is returned for categories that are not in the tables.
+var ErrUnknown = errors.New("unknown industry category")
+
+// Category is an entry of a classification.
+type Category struct {
+	System System
+	Code   string // within the system, such as "522320" or "J"
+	Parent string // code of the enclosing category, empty at the top level
+	Title  string
+}
+
+// ID returns the canonical form of the category, such as "NAICS:522320".
+func (c Category) ID() string {
+	return string(c.System) + ":" + c.Code
+}
+
+var (
+	//go:embed naics.csv
+	naicsCSV []byte
+	//go:embed isic.csv
+	isicCSV []byte
+)
+
+// categories maps canonical IDs to categories; children maps them to the IDs
+// of the categories directly below, in table order.
+var categories, children = load()
+
+func load() (map[string]Category, map[string][]string) {
+	categories := make(map[string]Category)
+	children := make(map[string][]string)
+	for system, data := range map[System][]byte{NAICS: naicsCSV, ISIC: isicCSV} {
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			panic(fmt.Sprintf("industry: reading the %s table: %v", system, err))
+		}
+		for _, record := range records[1:] { // skip the header
+			c := Category{System: system, Code: record[0], Parent: record[1], Title: record[2]}
+			categories[c.ID()] = c
+			if c.Parent != "" {
+				parent := string(system) + ":" + c.Parent
+				children[parent] = append(children[parent], c.ID())
+			}
+		}
+	}
+	return categories, children
+}
+
+// Lookup returns the category s names. It fails with ErrUnknown when s is
+// malformed or not in the tables.
+func Lookup(s string) (Category, error) {
+	system, code, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		system, code = string(NAICS), system
+		if code == "" || strings.Trim(code, "0123456789-") != "" {
+			return Category{}, fmt.Errorf("%w %q: expected SYSTEM:CODE, such as NAICS:5415", ErrUnknown, s)
+		}
+	}
+
+	c, ok := categories[strings.ToUpper(system)+":"+strings.ToUpper(code)]
+	if !ok {
+		return Category{}, fmt.Errorf("%w %q", ErrUnknown, s)
+	}
+	return c, nil
+}
+
+// Normalize returns the canonical form of the category s names, or s
+// unchanged when it names none.
+func Normalize(s string) string {
+	if c, err := Lookup(s); err == nil {
+		return c.ID()
+	}
+	return s
+}
+
+// Title returns the title of the category s names, or "" when it names none.
+func Title(s string) string {
+	c, _ := Lookup(s)
+	return c.Title
+}
+
+// Subtree returns the canonical IDs of c and of every category below it.
+func Subtree(c Category) []string {
+	ids := []string{c.ID()}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids
+}
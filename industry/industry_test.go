@@ -0,0 +1,105 @@
+package industry
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	for _, s := range []string{"NAICS:522320", "naics:522320", " 522320 ", "Naics:522320"} {
+		c, err := Lookup(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, "NAICS:522320", c.ID(), s)
+		assert.Equal(t, "Financial Transactions Processing, Reserve, and Clearinghouse Activities", c.Title)
+		assert.Equal(t, "52232", c.Parent)
+	}
+
+	c, err := Lookup("isic:j")
+	require.NoError(t, err)
+	assert.Equal(t, Category{System: ISIC, Code: "J", Title: "Information and communication"}, c)
+
+	c, err = Lookup("31-33")
+	require.NoError(t, err)
+	assert.Equal(t, "Manufacturing", c.Title)
+
+	// Codes from every part of the tables, not just the software and finance
+	// industries
+	for s, title := range map[string]string{
+		"NAICS:541330": "Engineering Services",
+		"NAICS:111110": "Soybean Farming",
+		"NAICS:336111": "",
+		"NAICS:336110": "Automobile and Light Duty Motor Vehicle Manufacturing",
+		"NAICS:928120": "International Affairs",
+		"ISIC:0111":    "Growing of cereals (except rice), leguminous crops and oil seeds",
+		"ISIC:4711":    "Retail sale in non-specialized stores with food, beverages or tobacco predominating",
+		"ISIC:8610":    "Hospital activities",
+		"ISIC:9900":    "Activities of extraterritorial organizations and bodies",
+	} {
+		c, err := Lookup(s)
+		if title == "" { // retired before NAICS 2022
+			assert.ErrorIs(t, err, ErrUnknown, s)
+			continue
+		}
+		require.NoError(t, err, s)
+		assert.Equal(t, title, c.Title, s)
+	}
+
+	for _, s := range []string{"", "Finance", "NAICS:", "NAICS:999999", "ISIC:Z", "SIC:7372", "J", "NAICS:52:1"} {
+		_, err := Lookup(s)
+		assert.ErrorIs(t, err, ErrUnknown, s)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "NAICS:5415", Normalize("5415"))
+	assert.Equal(t, "ISIC:6201", Normalize("isic:6201"))
+	assert.Equal(t, "Finance", Normalize("Finance"))
+	assert.Equal(t, "Computer programming activities", Title("ISIC:6201"))
+	assert.Equal(t, "", Title("Finance"))
+}
+
+func TestSubtree(t *testing.T) {
+	c, _ := Lookup("NAICS:5415")
+	assert.Equal(
+		t, []string{
+			"NAICS:5415", "NAICS:54151", "NAICS:541511", "NAICS:541512", "NAICS:541513", "NAICS:541519",
+		}, Subtree(c),
+	)
+
+	// Sectors spanning several two digit codes, and ISIC sections, hold
+	// subsectors with other codes
+	c, _ = Lookup("NAICS:44-45")
+	assert.Contains(t, Subtree(c), "NAICS:459")
+	c, _ = Lookup("ISIC:K")
+	subtree := Subtree(c)
+	assert.Contains(t, subtree, "ISIC:64")
+	assert.Contains(t, subtree, "ISIC:6630")
+	assert.NotContains(t, subtree, "ISIC:62")
+}
+
+func TestTables(t *testing.T) {
+	for id, c := range categories {
+		if c.Parent != "" {
+			assert.Contains(t, categories, string(c.System)+":"+c.Parent, "parent of %s", id)
+		}
+		assert.NotEmpty(t, c.Title, id)
+	}
+
+	// The number of categories at each level, as published for NAICS 2022
+	// and ISIC Rev.4
+	levels := make(map[string]int)
+	for _, c := range categories {
+		level := len(c.Code)
+		if c.Parent == "" {
+			level = 0
+		}
+		levels[fmt.Sprintf("%s/%d", c.System, level)]++
+	}
+	assert.Equal(t, map[string]int{
+		"NAICS/0": 20, "NAICS/3": 96, "NAICS/4": 308, "NAICS/5": 689, "NAICS/6": 1012,
+		"ISIC/0": 21, "ISIC/2": 88, "ISIC/3": 238, "ISIC/4": 419,
+	}, levels)
+}
@@ -0,0 +1,291 @@
+// Package labels validates the key/value labels of services and instances and
+// parses the label selectors that filter them.
+//
+// A selector is a comma separated list of requirements, all of which must
+// hold:
+//
+//	env=prod                     the label has the value (== is a synonym)
+//	env!=prod                    the label is missing or has another value
+//	region in (eu-west,eu-north) the label has one of the values
+//	region notin (us-east)       the label is missing or has none of the values
+//	canary                       the label is present
+//	!canary                      the label is missing
+package labels
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MaxNameLength bounds label values and the name part of label keys.
+const MaxNameLength = 63
+
+// maxPrefixLength bounds the optional DNS prefix of a label key.
+const maxPrefixLength = 253
+
+// Operator is the test a Requirement applies to a label.
+type Operator string
+
+const (
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+)
+
+// Requirement is a test of one label.
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string // one for Equals and NotEquals, none for Exists and DoesNotExist
+}
+
+// Matches reports whether the labels satisfy the requirement.
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	case Equals, In:
+		return ok && slices.Contains(r.Values, value)
+	case NotEquals, NotIn:
+		return !ok || !slices.Contains(r.Values, value)
+	default:
+		return false
+	}
+}
+
+// Selector selects labels that satisfy all of its requirements. The empty
+// selector selects everything.
+type Selector []Requirement
+
+// Matches reports whether the labels satisfy every requirement.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidKey reports whether key is a valid label key: a name of up to 63
+// letters, digits, '-', '_' and '.', beginning and ending with a letter or
+// digit, optionally prefixed by a DNS subdomain and a '/', as in
+// "example.com/team".
+func ValidKey(key string) bool {
+	prefix, name, ok := strings.Cut(key, "/")
+	if !ok {
+		return validName(key)
+	}
+	return validPrefix(prefix) && validName(name)
+}
+
+// ValidValue reports whether value is a valid label value: empty, or a name
+// as in ValidKey.
+func ValidValue(value string) bool {
+	return value == "" || validName(value)
+}
+
+// Validate checks the keys and values of labels.
+func Validate(labels map[string]string) error {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys) // report the same error every time
+
+	for _, key := range keys {
+		if !ValidKey(key) {
+			return fmt.Errorf("invalid label key %q", key)
+		}
+		if !ValidValue(labels[key]) {
+			return fmt.Errorf("invalid value %q of label %q", labels[key], key)
+		}
+	}
+	return nil
+}
+
+// validName reports whether s is a label name.
+func validName(s string) bool {
+	if s == "" || len(s) > MaxNameLength || !isAlphanumeric(s[0]) || !isAlphanumeric(s[len(s)-1]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isAlphanumeric(s[i]) && s[i] != '-' && s[i] != '_' && s[i] != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// validPrefix reports whether s is a lower case DNS subdomain.
+func validPrefix(s string) bool {
+	if s == "" || len(s) > maxPrefixLength {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// Parse parses a selector such as "env=prod,region in (eu-west,eu-north),!canary".
+// The empty string parses to the empty selector.
+func Parse(s string) (Selector, error) {
+	p := parser{input: s}
+	var selector Selector
+
+	if p.peek() == "" {
+		return nil, nil
+	}
+	for {
+		r, err := p.requirement()
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		selector = append(selector, r)
+
+		switch token := p.next(); token {
+		case "":
+			return selector, nil
+		case ",":
+		default:
+			return nil, fmt.Errorf("invalid selector %q: expected , before %q", s, token)
+		}
+	}
+}
+
+// parser splits a selector into tokens: the punctuation "(", ")", ",", "!",
+// "=", "==" and "!=", and words made of anything else but spaces.
+type parser struct {
+	input string
+	pos   int
+}
+
+// next returns the next token and consumes it, or "" at the end of input.
+func (p *parser) next() string {
+	token := p.peek()
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+	p.pos += len(token)
+	return token
+}
+
+// peek returns the next token without consuming it.
+func (p *parser) peek() string {
+	rest := strings.TrimLeft(p.input[p.pos:], " ")
+	switch {
+	case rest == "":
+		return ""
+	case strings.HasPrefix(rest, "=="), strings.HasPrefix(rest, "!="):
+		return rest[:2]
+	case strings.ContainsRune("(),!=", rune(rest[0])):
+		return rest[:1]
+	}
+
+	end := strings.IndexAny(rest, " (),!=")
+	if end < 0 {
+		end = len(rest)
+	}
+	return rest[:end]
+}
+
+// requirement parses one requirement.
+func (p *parser) requirement() (Requirement, error) {
+	if p.peek() == "!" {
+		p.next()
+		key, err := p.key()
+		return Requirement{Key: key, Operator: DoesNotExist}, err
+	}
+
+	key, err := p.key()
+	if err != nil {
+		return Requirement{}, err
+	}
+
+	switch op := p.peek(); op {
+	case "", ",":
+		return Requirement{Key: key, Operator: Exists}, nil
+	case "=", "==", "!=":
+		p.next()
+		value := p.peek()
+		if value == "," || value == "" {
+			value = "" // an empty value, as in "env="
+		} else {
+			p.next()
+		}
+		if !ValidValue(value) {
+			return Requirement{}, fmt.Errorf("invalid value %q", value)
+		}
+		operator := Equals
+		if op == "!=" {
+			operator = NotEquals
+		}
+		return Requirement{Key: key, Operator: operator, Values: []string{value}}, nil
+	case "in", "notin":
+		p.next()
+		values, err := p.values()
+		operator := In
+		if op == "notin" {
+			operator = NotIn
+		}
+		return Requirement{Key: key, Operator: operator, Values: values}, err
+	default:
+		return Requirement{}, fmt.Errorf("unexpected %q after key %q", op, key)
+	}
+}
+
+// key parses a label key.
+func (p *parser) key() (string, error) {
+	key := p.next()
+	if !ValidKey(key) {
+		if key == "" {
+			return "", fmt.Errorf("missing key")
+		}
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return key, nil
+}
+
+// values parses a parenthesised, comma separated list of values.
+func (p *parser) values() ([]string, error) {
+	if token := p.next(); token != "(" {
+		return nil, fmt.Errorf("expected ( instead of %q", token)
+	}
+
+	var values []string
+	for {
+		value := p.next()
+		if value == "" || !ValidValue(value) {
+			return nil, fmt.Errorf("invalid value %q", value)
+		}
+		values = append(values, value)
+
+		switch token := p.next(); token {
+		case ")":
+			return values, nil
+		case ",":
+		default:
+			return nil, fmt.Errorf("expected , or ) instead of %q", token)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	selector, err := Parse("env=prod, region in (eu-west, eu-north),!canary,tier!=db,example.com/team,zone notin (a)")
+	assert.NoError(t, err)
+	assert.Equal(
+		t, Selector{
+			{Key: "env", Operator: Equals, Values: []string{"prod"}},
+			{Key: "region", Operator: In, Values: []string{"eu-west", "eu-north"}},
+			{Key: "canary", Operator: DoesNotExist},
+			{Key: "tier", Operator: NotEquals, Values: []string{"db"}},
+			{Key: "example.com/team", Operator: Exists},
+			{Key: "zone", Operator: NotIn, Values: []string{"a"}},
+		}, selector,
+	)
+
+	selector, err = Parse("env==prod,owner=")
+	assert.NoError(t, err)
+	assert.Equal(
+		t, Selector{
+			{Key: "env", Operator: Equals, Values: []string{"prod"}},
+			{Key: "owner", Operator: Equals, Values: []string{""}},
+		}, selector,
+	)
+
+	selector, err = Parse("  ")
+	assert.NoError(t, err)
+	assert.Empty(t, selector)
+
+	for _, s := range []string{
+		"env=prod,", ",env", "env=prod prod", "region in eu-west", "region in ()", "region in (a,",
+		"!", "-env", "env=(", "env=a=b", "Example.com/team", "env > 1", "env in (a) b",
+	} {
+		_, err := Parse(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{"env": "prod", "region": "eu-west", "canary": ""}
+
+	for s, want := range map[string]bool{
+		"":                              true,
+		"env=prod":                      true,
+		"env=dev":                       false,
+		"env!=dev":                      true,
+		"tier!=db":                      true,
+		"region in (eu-west,eu-north)":  true,
+		"region notin (eu-west)":        false,
+		"tier notin (db)":               true,
+		"canary":                        true,
+		"!canary":                       false,
+		"env=prod,region in (eu-north)": false,
+	} {
+		selector, err := Parse(s)
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, want, selector.Matches(labels), s)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(map[string]string{"env": "prod", "example.com/team": "payments", "flag": ""}))
+	assert.Error(t, Validate(map[string]string{"env": "has space"}))
+	assert.Error(t, Validate(map[string]string{"-env": "prod"}))
+	assert.Error(t, Validate(map[string]string{"a/b/c": "prod"}))
+}
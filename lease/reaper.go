@@ -0,0 +1,64 @@
+package lease
+
+import (
+	"DirectoryService/db"
+	"DirectoryService/models"
+	"context"
+	"log"
+	"time"
+)
+
+// DefaultInterval is how often a Reaper looks for expired leases when no
+// interval is configured.
+const DefaultInterval = 5 * time.Second
+
+// Reaper removes instances whose heartbeat lease has expired. Expired
+// instances are archived to the history with the reason "lease expired".
+type Reaper struct {
+	store    db.Store
+	interval time.Duration
+}
+
+// NewReaper creates a Reaper for store that runs every interval. A
+// non-positive interval uses DefaultInterval.
+func NewReaper(store db.Store, interval time.Duration) *Reaper {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	return &Reaper{store: store, interval: interval}
+}
+
+// Run reaps expired leases until ctx is cancelled.
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := r.Reap(ctx, now); err != nil {
+				log.Printf("lease reaper: %v", err)
+			}
+		}
+	}
+}
+
+// Reap archives every instance whose lease expired before now and returns them.
+func (r *Reaper) Reap(ctx context.Context, now time.Time) ([]models.ServiceInstance, error) {
+	expired, err := r.store.ExpireLeases(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, instance := range expired {
+		log.Printf(
+			"lease reaper: removed instance %s of service %s (lease expired at %s)",
+			instance.InstanceID, instance.ServiceID, instance.LeaseExpiresAt.Format(time.RFC3339),
+		)
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,82 @@
+package lease
+
+import (
+	"DirectoryService/db"
+	"DirectoryService/models"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReaperRemovesExpiredInstances(t *testing.T) {
+	store := db.NewMemStore()
+	ctx := context.Background()
+
+	service, err := store.RegisterService(ctx, models.Service{Name: "Test Service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance, err := store.CreateServiceInstance(
+		ctx, models.ServiceInstance{
+			ServiceID: service.ServiceID,
+			Version:   "1.0.0",
+			LeaseTTL:  models.Duration(time.Second),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reaper := NewReaper(store, 0)
+	reaped, err := reaper.Reap(ctx, instance.CreatedAt)
+	assert.NoError(t, err)
+	assert.Empty(t, reaped, "a live lease should not be reaped")
+
+	reaped, err = reaper.Reap(ctx, instance.CreatedAt.Add(2*time.Second))
+	assert.NoError(t, err)
+	assert.Len(t, reaped, 1, "the expired lease should be reaped")
+
+	history := store.History()
+	assert.Len(t, history, 1, "reaped instance should be archived")
+	assert.Equal(t, db.ReasonLeaseExpired, history[0].Metrics["reason"])
+}
+
+func TestReaperRunStopsOnCancel(t *testing.T) {
+	store := db.NewMemStore()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	service, err := store.RegisterService(ctx, models.Service{Name: "Test Service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.CreateServiceInstance(
+		ctx, models.ServiceInstance{
+			ServiceID: service.ServiceID,
+			Version:   "1.0.0",
+			LeaseTTL:  models.Duration(time.Millisecond),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		NewReaper(store, 10*time.Millisecond).Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(
+		t, func() bool { return len(store.History()) == 1 }, time.Second, 10*time.Millisecond,
+		"the reaper should remove the expired instance",
+	)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
@@ -3,10 +3,16 @@ package main
 import (
 	"DirectoryService/cfg"
 	"DirectoryService/handlers"
+	"DirectoryService/health"
+	"DirectoryService/lease"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"DirectoryService/db"
@@ -19,16 +25,68 @@ func main() {
 		log.Fatalf("Failed to load cfg: %v", err)
 	}
 
-	pool, err := db.ConnectDB(config)
+	// "migrate up|down|status" manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(config, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	backend, err := db.NewStore(config)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
-	defer pool.Close() // Ensure connection pool is closed when the service shuts down
+	defer backend.Close() // Ensure the store is closed when the service shuts down
+
+	// Every change, including those made by the background workers, is
+	// published to watchers, numbered on from the persisted registry index
+	index, err := backend.Index(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to read the registry index: %v", err)
+	}
+	feed := db.NewChangeFeed(config.Watch.Buffer)
+	feed.SkipTo(index)
+	store := db.NewWatchedStore(backend, feed)
+
+	fmt.Printf("Registry store ready (driver: %s)\n", config.DB.Driver)
+
+	// Background workers stop when the registry receives SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var workers sync.WaitGroup
+
+	// The monitor also serves on-demand checks when periodic checks are disabled
+	monitor := health.NewMonitor(
+		store, health.Options{
+			Interval:           config.Health.Interval,
+			Timeout:            config.Health.Timeout,
+			HealthyThreshold:   config.Health.HealthyThreshold,
+			UnhealthyThreshold: config.Health.UnhealthyThreshold,
+			Concurrency:        config.Health.Concurrency,
+		},
+	)
+	if config.Health.Enabled {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			monitor.Run(ctx)
+		}()
+		log.Printf("Health monitor started")
+	}
 
-	fmt.Println("Database connection established")
+	reaper := lease.NewReaper(store, config.Lease.ReapInterval)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		reaper.Run(ctx)
+	}()
 
-	// Create DbCtx instance and inject into DbCtx
-	server := handlers.NewServer(pool) // Inject DbCtx into DbCtx struct
+	// Inject the Store into the Server
+	server := handlers.NewServer(store)
+	server.DefaultLeaseTTL = config.Lease.DefaultTTL
+	server.Checker = monitor
+	server.MaxBodyBytes = config.Server.MaxBodyBytes
 
 	// Set up HTTP routes
 	router := server.NewRouter()
@@ -47,8 +105,82 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Registry shutdown error: %v", err)
+		}
+	}()
+
 	log.Printf("Registry running on port %s", port)
 	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Registry error: %v", err)
 	}
+
+	stop()
+	workers.Wait()
+}
+
+// runMigrate implements the "migrate up|down|status" subcommand.
+func runMigrate(config *cfg.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s migrate up|down|status", os.Args[0])
+	}
+
+	// The subcommand is the only thing that should touch the schema
+	config.DB.AutoMigrate = false
+
+	store, err := db.NewStore(config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	migrator, err := db.NewMigrator(store)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		m, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			fmt.Println("no migrations to roll back")
+		} else {
+			fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = "applied " + st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", st.Version, st.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+
+	return nil
 }
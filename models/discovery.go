@@ -0,0 +1,8 @@
+package models
+
+// DiscoveredInstance is a service instance returned by the discovery API.
+type DiscoveredInstance struct {
+	ServiceInstance
+	ServiceName string   `json:"service_name"`
+	DistanceKm  *float64 `json:"distance_km,omitempty"` // set when the caller gave lat/lon
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// EventType identifies the kind of registry change an Event describes.
+type EventType string
+
+const (
+	EventServiceRegistered     EventType = "service.registered"
+	EventServiceUpdated        EventType = "service.updated"
+	EventServiceDeleted        EventType = "service.deleted"
+	EventInstanceAdded         EventType = "instance.added"
+	EventInstanceRemoved       EventType = "instance.removed"
+	EventInstanceHealthChanged EventType = "instance.health_changed"
+
+	// EventReset tells a watcher that it missed changes and should re-list
+	// the registry.
+	EventReset EventType = "reset"
+)
+
+// Event is one change to the registry. Index increases by one with every
+// change, so a watcher can resume from the last index it saw.
+type Event struct {
+	Index       uint64           `json:"index"`
+	Type        EventType        `json:"type"`
+	ServiceID   uuid.UUID        `json:"service_id"`
+	ServiceName string           `json:"service_name"`
+	Service     *Service         `json:"service,omitempty"`
+	Instance    *ServiceInstance `json:"instance,omitempty"`
+
+	// PreviousHealth is set on instance.health_changed events.
+	PreviousHealth HealthStatus `json:"previous_health_status,omitempty"`
+
+	// Reason is set on instance.removed events, e.g. "deregistered" or "lease expired".
+	Reason string `json:"reason,omitempty"`
+
+	Time time.Time `json:"time"`
+}
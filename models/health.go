@@ -1,4 +1,65 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 // HealthStatus represents the health status of a service.
 type HealthStatus string
+
+const (
+	HealthStarting HealthStatus = "starting" // registered, not yet checked
+	HealthUp       HealthStatus = "up"
+	HealthDown     HealthStatus = "down"
+	HealthUnknown  HealthStatus = "unknown" // the instance could not be checked
+)
+
+// Health check modes.
+const (
+	HealthCheckHTTP = "http"
+	HealthCheckTCP  = "tcp"
+)
+
+// HealthCheck configures how the registry probes the instances of a service.
+// Zero values fall back to the registry defaults.
+type HealthCheck struct {
+	Mode               string   `json:"mode,omitempty" validate:"oneof=http tcp"`
+	Path               string   `json:"path,omitempty" validate:"max=2048"` // HTTP path, relative to the instance URL
+	Interval           Duration `json:"interval,omitempty" validate:"min=0"`
+	Timeout            Duration `json:"timeout,omitempty" validate:"min=0"`
+	HealthyThreshold   int      `json:"healthy_threshold,omitempty" validate:"min=0"`   // successes before up
+	UnhealthyThreshold int      `json:"unhealthy_threshold,omitempty" validate:"min=0"` // failures before down
+}
+
+// Duration is a time.Duration that is encoded in JSON as a string such as "10s".
+type Duration time.Duration
+
+// MarshalJSON encodes the duration as a Go duration string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON accepts a duration string ("1m30s") or a number of seconds.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(value * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", value, err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", b)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package models
+
+import "DirectoryService/validate"
+
+// ErrorCode is the stable, machine-readable code of an error response. Clients
+// should branch on the code rather than on the status or the detail text.
+type ErrorCode string
+
+const (
+	CodeBadRequest       ErrorCode = "bad_request"        // 400: invalid path or query parameter
+	CodeInvalidPayload   ErrorCode = "invalid_payload"    // 400: the body is not the expected JSON
+	CodePayloadTooLarge  ErrorCode = "payload_too_large"  // 413
+	CodeNotFound         ErrorCode = "not_found"          // 404
+	CodeMethodNotAllowed ErrorCode = "method_not_allowed" // 405
+	CodeConflict         ErrorCode = "conflict"           // 409: duplicate or still referenced
+	CodeInvalidValue     ErrorCode = "invalid_value"      // 422: a value the registry rejects
+	CodeInvalidReference ErrorCode = "invalid_reference"  // 422: refers to a service that does not exist
+	CodeUnavailable      ErrorCode = "unavailable"        // 503: the feature is not enabled
+	CodeInternal         ErrorCode = "internal"           // 500
+)
+
+// ProblemContentType is the media type of error responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details error response, extended with the
+// error code and the ID of the request that failed.
+type Problem struct {
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Status    int       `json:"status"`
+	Detail    string    `json:"detail,omitempty"`
+	Instance  string    `json:"instance,omitempty"`
+	Code      ErrorCode `json:"code"`
+	RequestID string    `json:"request_id"`
+
+	// Errors lists the invalid fields of a rejected payload.
+	Errors validate.Errors `json:"errors,omitempty"`
+}
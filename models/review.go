@@ -0,0 +1,26 @@
+package models
+
+import (
+	"DirectoryService/validate"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Review is a client's star rating of a service, with an optional comment.
+// A service has at most one review per reviewer; reviewing it again edits
+// that review.
+type Review struct {
+	ReviewID  uuid.UUID `json:"review_id"`
+	ServiceID uuid.UUID `json:"service_id"`
+	Reviewer  string    `json:"reviewer" validate:"required,max=200"` // identity of the client
+	Rating    int       `json:"rating" validate:"required,min=1,max=5"`
+	Comment   string    `json:"comment,omitempty" validate:"max=4000"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// Validate checks the review against the rules in its validate tags and
+// returns validate.Errors listing the invalid fields.
+func (r Review) Validate() error {
+	return validate.Struct(r)
+}
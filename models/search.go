@@ -0,0 +1,12 @@
+package models
+
+// SearchResult is a service returned by the search API.
+type SearchResult struct {
+	Service
+	Score float64 `json:"score"` // relevance; higher is better
+
+	// Highlights maps the JSON name of each matching field to a snippet of it
+	// with the matching words wrapped in <mark></mark>. Snippets are HTML
+	// escaped.
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
@@ -1,20 +1,32 @@
 package models
 
 import (
+	"DirectoryService/validate"
 	"github.com/google/uuid"
 	"time"
 )
 
 // Service represents a service entity in the database.
 type Service struct {
-	ServiceID        uuid.UUID `json:"service_id"`
-	Name             string    `json:"name"`
-	Description      string    `json:"description"`
-	OwnerInfo        string    `json:"owner_info"`
-	IndustryCategory string    `json:"industry_category"`
-	ClientRating     float64   `json:"client_rating"`
-	TransactionCount int64     `json:"transaction_count,omitempty"`
-	AvgResponseTime  float64   `json:"average_response_time,omitempty"`
-	CreatedAt        time.Time `json:"created_at,omitempty"`
-	UpdatedAt        time.Time `json:"updated_at,omitempty"`
+	ServiceID            uuid.UUID         `json:"service_id"`
+	Name                 string            `json:"name" validate:"required,max=200"`
+	Description          string            `json:"description" validate:"max=4000"`
+	OwnerInfo            string            `json:"owner_info" validate:"max=500"`
+	IndustryCategory     string            `json:"industry_category" validate:"max=200,industry"`
+	IndustryCategoryName string            `json:"industry_category_name,omitempty"` // title of IndustryCategory, set by the store
+	Tags                 []string          `json:"tags,omitempty" validate:"max=32"`
+	Labels               map[string]string `json:"labels,omitempty" validate:"max=64,labels"`
+	ClientRating         float64           `json:"client_rating" validate:"min=0,max=5"` // from reviews; 0 without any
+	ReviewCount          int64             `json:"review_count"`
+	HealthCheck          *HealthCheck      `json:"health_check,omitempty"`
+	TransactionCount     int64             `json:"transaction_count,omitempty" validate:"min=0"`
+	AvgResponseTime      float64           `json:"average_response_time,omitempty" validate:"min=0"`
+	CreatedAt            time.Time         `json:"created_at,omitempty"`
+	UpdatedAt            time.Time         `json:"updated_at,omitempty"`
+}
+
+// Validate checks the service against the rules in its validate tags and
+// returns validate.Errors listing the invalid fields.
+func (s Service) Validate() error {
+	return validate.Struct(s)
 }
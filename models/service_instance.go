@@ -1,22 +1,93 @@
 package models
 
 import (
+	"DirectoryService/validate"
+	"errors"
 	"github.com/google/uuid"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // ServiceInstance represents an instance of a service.
 type ServiceInstance struct {
-	ServiceID    uuid.UUID    `json:"service_id"`
-	InstanceID   uuid.UUID    `json:"instance_id"`
-	Version      string       `json:"version"`
-	Host         string       `json:"host"`
-	Port         int          `json:"port"`
-	Url          string       `json:"url"`
-	Latitude     float64      `json:"latitude"`
-	Longitude    float64      `json:"longitude"`
-	HealthStatus HealthStatus `json:"health_status"`
-	ApiSpec      string       `json:"api_spec"`
-	CreatedAt    time.Time    `json:"created_at"`
-	LastChecked  time.Time    `json:"last_checked"`
+	ServiceID    uuid.UUID         `json:"service_id" validate:"required"`
+	InstanceID   uuid.UUID         `json:"instance_id"`
+	Version      string            `json:"version" validate:"required,semver"`
+	Host         string            `json:"host" validate:"hostname"`
+	Port         int               `json:"port" validate:"min=0,max=65535"`
+	Url          string            `json:"url" validate:"url,max=2048"`
+	Scheme       string            `json:"scheme,omitempty" validate:"oneof=http https"` // for the derived URL; default http
+	BasePath     string            `json:"base_path,omitempty" validate:"max=2048"`      // for the derived URL
+	Endpoints    *Endpoints        `json:"endpoints,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty" validate:"max=64,labels"`
+	Latitude     float64           `json:"latitude" validate:"min=-90,max=90"`
+	Longitude    float64           `json:"longitude" validate:"min=-180,max=180"`
+	HealthStatus HealthStatus      `json:"health_status"`
+	ApiSpec      string            `json:"api_spec" validate:"max=65536"`
+	CreatedAt    time.Time         `json:"created_at"`
+	LastChecked  time.Time         `json:"last_checked"`
+
+	// LeaseTTL enables a heartbeat lease: an instance that does not renew its
+	// lease within the TTL is removed by the registry.
+	LeaseTTL       Duration   `json:"lease_ttl,omitempty" validate:"min=0"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+}
+
+// Endpoints are the named addresses an instance serves, beyond its URL.
+type Endpoints struct {
+	HTTP    string `json:"http,omitempty" validate:"url,max=2048"`
+	HTTPS   string `json:"https,omitempty" validate:"url,max=2048"`
+	GRPC    string `json:"grpc,omitempty" validate:"url,max=2048"`
+	Metrics string `json:"metrics,omitempty" validate:"url,max=2048"`
+	Health  string `json:"health,omitempty" validate:"url,max=2048"`
+}
+
+// IsZero reports whether no endpoint is set.
+func (e *Endpoints) IsZero() bool {
+	return e == nil || *e == Endpoints{}
+}
+
+// CanonicalURL returns the instance URL. When none was given it is derived
+// from the scheme, host, port and base path, e.g. https://api.example.com/v1;
+// the port is left out when it is the scheme's default. An instance without
+// a URL or host has no canonical URL.
+func (i ServiceInstance) CanonicalURL() string {
+	if i.Url != "" || i.Host == "" {
+		return i.Url
+	}
+
+	scheme := i.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	host := i.Host
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	if i.Port > 0 && !(scheme == "http" && i.Port == 80) && !(scheme == "https" && i.Port == 443) {
+		host = net.JoinHostPort(i.Host, strconv.Itoa(i.Port))
+	}
+
+	u := url.URL{Scheme: scheme, Host: host}
+	if path := strings.Trim(i.BasePath, "/"); path != "" {
+		u.Path = "/" + path
+	}
+	return u.String()
+}
+
+// ErrNoAddress is returned by ServiceInstance.Validate for an instance that
+// gives neither a host nor a URL, which clients could not reach.
+var ErrNoAddress = errors.New("host or url is required")
+
+// Validate checks the instance against the rules in its validate tags and
+// returns validate.Errors listing the invalid fields, or ErrNoAddress when the
+// instance has no address.
+func (i ServiceInstance) Validate() error {
+	if i.Host == "" && i.Url == "" {
+		return ErrNoAddress
+	}
+	return validate.Struct(i)
 }
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// ServiceInstanceHistory represents an archived service instance.
+type ServiceInstanceHistory struct {
+	HistoryID  uuid.UUID              `json:"history_id"`
+	InstanceID uuid.UUID              `json:"instance_id"`
+	ServiceID  uuid.UUID              `json:"service_id"`
+	Version    string                 `json:"version"`
+	Url        string                 `json:"url"`
+	Metrics    map[string]interface{} `json:"metrics,omitempty"`
+	StartedAt  time.Time              `json:"started_at"`
+	StoppedAt  time.Time              `json:"stopped_at"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "github.com/google/uuid"
+
+// ServiceStatistics summarises the usage and instances of a service.
+type ServiceStatistics struct {
+	ServiceID        uuid.UUID              `json:"service_id"`
+	TransactionCount int64                  `json:"transaction_count"`
+	AvgResponseTime  float64                `json:"average_response_time"` // in milliseconds
+	Details          map[string]interface{} `json:"details,omitempty"`
+}
@@ -0,0 +1,227 @@
+package _go
+
+import (
+	"DirectoryService/models"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Defaults for AnnounceOptions.
+const (
+	DefaultLeaseTTL          = 30 * time.Second
+	DefaultDeregisterTimeout = 5 * time.Second
+)
+
+// AnnounceOptions describe the service instance to announce and how to keep
+// it registered.
+type AnnounceOptions struct {
+	Client *RegistryClient // registry to announce to; required
+
+	// Instance is registered as given; ServiceID and Version are required.
+	// The registry assigns the InstanceID.
+	Instance models.ServiceInstance
+
+	// LeaseTTL is the lease the registry holds the instance for without a
+	// heartbeat. Defaults to DefaultLeaseTTL.
+	LeaseTTL time.Duration
+
+	// HeartbeatInterval is how often the lease is renewed and the health
+	// check runs. Defaults to a third of LeaseTTL.
+	HeartbeatInterval time.Duration
+
+	// HealthCheck reports the instance's health: nil means up, an error means
+	// down. When it is nil the instance is reported up once registered.
+	HealthCheck func(ctx context.Context) error
+
+	// Signals deregister the instance when received. Defaults to SIGTERM and
+	// os.Interrupt.
+	Signals []os.Signal
+
+	// DeregisterTimeout bounds the deregistration request made on shutdown.
+	// Defaults to DefaultDeregisterTimeout.
+	DeregisterTimeout time.Duration
+
+	// OnError is called with errors from the background heartbeat and health
+	// reports, which are otherwise retried silently on the next interval.
+	OnError func(error)
+}
+
+// Announcement is a service instance kept registered by Announce.
+type Announcement struct {
+	opts AnnounceOptions
+	done chan struct{}
+
+	mu       sync.Mutex
+	instance models.ServiceInstance
+	reported HealthStatus
+	err      error
+}
+
+// Announce registers a service instance and keeps it registered in the
+// background: it renews the instance's lease with heartbeats and reports the
+// result of the health check. When ctx is cancelled or one of the signals
+// arrives, the instance is deregistered and Done is closed.
+//
+// While the announcement runs, the signals are delivered to it instead of
+// terminating the process; the caller should wait on Done and then exit.
+func Announce(ctx context.Context, opts AnnounceOptions) (*Announcement, error) {
+	if opts.Client == nil {
+		return nil, errors.New("registry: announce requires a Client")
+	}
+	if opts.LeaseTTL <= 0 {
+		opts.LeaseTTL = DefaultLeaseTTL
+	}
+	if opts.HeartbeatInterval <= 0 {
+		opts.HeartbeatInterval = opts.LeaseTTL / 3
+	}
+	if len(opts.Signals) == 0 {
+		opts.Signals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+	if opts.DeregisterTimeout <= 0 {
+		opts.DeregisterTimeout = DefaultDeregisterTimeout
+	}
+
+	a := &Announcement{opts: opts, done: make(chan struct{})}
+	if err := a.register(ctx); err != nil {
+		return nil, err
+	}
+	a.report(ctx)
+
+	sigCtx, stop := signal.NotifyContext(ctx, opts.Signals...)
+	go func() {
+		defer close(a.done)
+		defer stop()
+		a.run(sigCtx)
+	}()
+
+	return a, nil
+}
+
+// InstanceID returns the ID of the registered instance. It changes when the
+// instance has to be registered again after its lease was lost.
+func (a *Announcement) InstanceID() uuid.UUID {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.instance.InstanceID
+}
+
+// Done is closed once the instance has been deregistered.
+func (a *Announcement) Done() <-chan struct{} {
+	return a.done
+}
+
+// Err returns the deregistration error, if any, once Done is closed.
+func (a *Announcement) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.err
+}
+
+// register registers the instance with its lease.
+func (a *Announcement) register(ctx context.Context) error {
+	instance := a.opts.Instance
+	instance.LeaseTTL = models.Duration(a.opts.LeaseTTL)
+
+	registered, err := a.opts.Client.RegisterInstance(ctx, instance)
+	if err != nil {
+		return fmt.Errorf("registry: failed to announce instance: %w", err)
+	}
+
+	a.mu.Lock()
+	a.instance = *registered
+	a.reported = HealthStatus(registered.HealthStatus)
+	a.mu.Unlock()
+
+	return nil
+}
+
+// run keeps the instance registered until ctx is done and then deregisters it.
+func (a *Announcement) run(ctx context.Context) {
+	ticker := time.NewTicker(a.opts.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			a.deregister()
+			return
+		case <-ticker.C:
+			a.heartbeat(ctx)
+			a.report(ctx)
+		}
+	}
+}
+
+// heartbeat renews the lease, registering the instance again when the
+// registry has already dropped it.
+func (a *Announcement) heartbeat(ctx context.Context) {
+	err := a.opts.Client.Heartbeat(ctx, a.InstanceID().String(), 0)
+	if errors.Is(err, ErrNotFound) {
+		err = a.register(ctx)
+	}
+	if err != nil && ctx.Err() == nil {
+		a.fail(err)
+	}
+}
+
+// report runs the health check and reports the status when it changed.
+func (a *Announcement) report(ctx context.Context) {
+	status := Up
+	if a.opts.HealthCheck != nil {
+		checkCtx, cancel := context.WithTimeout(ctx, a.opts.HeartbeatInterval)
+		if err := a.opts.HealthCheck(checkCtx); err != nil {
+			status = Down
+		}
+		cancel()
+	}
+
+	a.mu.Lock()
+	unchanged := status == a.reported
+	a.mu.Unlock()
+	if unchanged {
+		return
+	}
+
+	if err := a.opts.Client.UpdateServiceHealth(ctx, a.InstanceID().String(), status); err != nil {
+		if ctx.Err() == nil {
+			a.fail(err)
+		}
+		return
+	}
+
+	a.mu.Lock()
+	a.reported = status
+	a.mu.Unlock()
+}
+
+// deregister removes the instance from the registry.
+func (a *Announcement) deregister() {
+	ctx, cancel := context.WithTimeout(context.Background(), a.opts.DeregisterTimeout)
+	defer cancel()
+
+	err := a.opts.Client.DeregisterInstance(ctx, a.InstanceID().String())
+	if errors.Is(err, ErrNotFound) {
+		err = nil
+	}
+
+	a.mu.Lock()
+	a.err = err
+	a.mu.Unlock()
+}
+
+// fail passes a background error to OnError.
+func (a *Announcement) fail(err error) {
+	if a.opts.OnError != nil {
+		a.opts.OnError(err)
+	}
+}
@@ -0,0 +1,126 @@
+package _go
+
+import (
+	"DirectoryService/db"
+	"DirectoryService/models"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnounceKeepsInstanceRegistered(t *testing.T) {
+	client, store := setupTestRegistry(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	service, err := store.RegisterService(ctx, models.Service{Name: "Payments"})
+	assert.NoError(t, err)
+
+	var healthy atomic.Bool
+	healthy.Store(true)
+	announcement, err := Announce(
+		ctx, AnnounceOptions{
+			Client:            client,
+			Instance:          models.ServiceInstance{ServiceID: service.ServiceID, Version: "1.0.0", Host: "127.0.0.1"},
+			LeaseTTL:          time.Second,
+			HeartbeatInterval: 10 * time.Millisecond,
+			HealthCheck: func(context.Context) error {
+				if healthy.Load() {
+					return nil
+				}
+				return errors.New("draining")
+			},
+		},
+	)
+	assert.NoError(t, err, "Announce should not return an error")
+
+	instanceHealth := func() models.HealthStatus {
+		instance, err := store.GetServiceInstance(ctx, announcement.InstanceID())
+		if err != nil {
+			return ""
+		}
+		return instance.HealthStatus
+	}
+	assert.Equal(t, models.HealthUp, instanceHealth(), "a healthy instance is reported up at once")
+
+	instance, err := store.GetServiceInstance(ctx, announcement.InstanceID())
+	assert.NoError(t, err)
+	assert.Equal(t, models.Duration(time.Second), instance.LeaseTTL, "the instance should hold a lease")
+	firstExpiry := *instance.LeaseExpiresAt
+	assert.Eventually(
+		t, func() bool {
+			instance, err := store.GetServiceInstance(ctx, announcement.InstanceID())
+			return err == nil && instance.LeaseExpiresAt.After(firstExpiry)
+		}, time.Second, 10*time.Millisecond, "heartbeats should renew the lease",
+	)
+
+	healthy.Store(false)
+	assert.Eventually(
+		t, func() bool { return instanceHealth() == models.HealthDown }, time.Second, 10*time.Millisecond,
+		"a failing health check should be reported",
+	)
+
+	cancel()
+	select {
+	case <-announcement.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the announcement did not stop after cancel")
+	}
+	assert.NoError(t, announcement.Err())
+
+	instances, err := store.ListServiceInstances(context.Background(), db.InstanceFilter{ServiceID: service.ServiceID})
+	assert.NoError(t, err)
+	assert.Empty(t, instances, "the instance should be deregistered")
+}
+
+func TestAnnounceRegistersAgainAfterLosingLease(t *testing.T) {
+	client, store := setupTestRegistry(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	service, err := store.RegisterService(ctx, models.Service{Name: "Payments"})
+	assert.NoError(t, err)
+
+	announcement, err := Announce(
+		ctx, AnnounceOptions{
+			Client:            client,
+			Instance:          models.ServiceInstance{ServiceID: service.ServiceID, Version: "1.0.0", Host: "127.0.0.1"},
+			HeartbeatInterval: 10 * time.Millisecond,
+		},
+	)
+	assert.NoError(t, err)
+
+	lost := announcement.InstanceID()
+	_, err = store.ExpireLeases(ctx, time.Now().Add(time.Hour))
+	assert.NoError(t, err, "expire the lease as the reaper would")
+
+	assert.Eventually(
+		t, func() bool {
+			id := announcement.InstanceID()
+			_, err := store.GetServiceInstance(ctx, id)
+			return id != lost && err == nil
+		}, time.Second, 10*time.Millisecond, "the instance should be registered again",
+	)
+
+	cancel()
+	<-announcement.Done()
+}
+
+func TestAnnounceRequiresRegistration(t *testing.T) {
+	client, _ := setupTestRegistry(t)
+
+	_, err := Announce(
+		context.Background(), AnnounceOptions{
+			Client:   client,
+			Instance: models.ServiceInstance{Version: "1.0.0"},
+		},
+	)
+	assert.ErrorIs(t, err, ErrInvalid, "announcing an unknown service should fail")
+
+	_, err = Announce(context.Background(), AnnounceOptions{})
+	assert.Error(t, err, "a client is required")
+}
@@ -0,0 +1,43 @@
+//go:build unix
+
+package _go
+
+import (
+	"DirectoryService/db"
+	"DirectoryService/models"
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnounceDeregistersOnSignal(t *testing.T) {
+	client, store := setupTestRegistry(t)
+	ctx := context.Background()
+
+	service, err := store.RegisterService(ctx, models.Service{Name: "Payments"})
+	assert.NoError(t, err)
+
+	announcement, err := Announce(
+		ctx, AnnounceOptions{
+			Client:   client,
+			Instance: models.ServiceInstance{ServiceID: service.ServiceID, Version: "1.0.0", Host: "127.0.0.1"},
+			Signals:  []os.Signal{syscall.SIGUSR1},
+		},
+	)
+	assert.NoError(t, err)
+
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	select {
+	case <-announcement.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the announcement did not stop on the signal")
+	}
+
+	instances, err := store.ListServiceInstances(ctx, db.InstanceFilter{ServiceID: service.ServiceID})
+	assert.NoError(t, err)
+	assert.Empty(t, instances, "the instance should be deregistered")
+}
@@ -0,0 +1,177 @@
+package _go
+
+import (
+	"DirectoryService/models"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// indexHeader carries the registry change index on read responses.
+const indexHeader = "X-Registry-Index"
+
+// Location is a point on the earth in decimal degrees.
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// DiscoverQuery selects the instances returned by Discover. Name or
+// ServiceID is required.
+type DiscoverQuery struct {
+	Name         string
+	ServiceID    string
+	Version      string // "1", "1.2" or "1.2.3": that version or newer
+	PreRelease   bool   // include pre-release versions
+	HealthStatus HealthStatus
+	Selector     string // label selector such as "env=prod,region in (eu-west,eu-north),!canary"
+
+	// Near orders the instances by distance from the location, nearest first.
+	Near          *Location
+	MaxDistanceKm float64 // with Near, drops instances that are further away
+
+	// A non-zero Wait makes the call block until the instances change past
+	// Index, the index returned by an earlier call, or until Wait elapses.
+	Index uint64
+	Wait  time.Duration
+}
+
+// values encodes the query parameters of the discovery endpoint.
+func (q DiscoverQuery) values() url.Values {
+	v := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	set("name", q.Name)
+	set("service_id", q.ServiceID)
+	set("version", q.Version)
+	set("health_status", string(q.HealthStatus))
+	set("selector", q.Selector)
+	if q.PreRelease {
+		v.Set("prerelease", "true")
+	}
+	if q.Near != nil {
+		v.Set("lat", strconv.FormatFloat(q.Near.Latitude, 'f', -1, 64))
+		v.Set("lon", strconv.FormatFloat(q.Near.Longitude, 'f', -1, 64))
+		if q.MaxDistanceKm > 0 {
+			v.Set("max_distance_km", strconv.FormatFloat(q.MaxDistanceKm, 'f', -1, 64))
+		}
+	}
+	if q.Wait > 0 {
+		v.Set("index", strconv.FormatUint(q.Index, 10))
+		v.Set("wait", q.Wait.String())
+	}
+	return v
+}
+
+// Discover returns the instances matching q and the registry index they
+// reflect, which a later call can pass as q.Index to wait for a change.
+func (c *RegistryClient) Discover(ctx context.Context, q DiscoverQuery) (
+	[]models.DiscoveredInstance, uint64, error,
+) {
+	instances, index, _, err := c.discover(ctx, q)
+	return instances, index, err
+}
+
+// discover is Discover that also reports whether the registry returned an
+// index, i.e. whether it supports blocking queries.
+func (c *RegistryClient) discover(ctx context.Context, q DiscoverQuery) (
+	[]models.DiscoveredInstance, uint64, bool, error,
+) {
+	var instances []models.DiscoveredInstance
+	header, err := c.send(ctx, http.MethodGet, "/discover?"+q.values().Encode(), nil, &instances)
+
+	raw := header.Get(indexHeader)
+	index, _ := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return nil, index, raw != "", err
+	}
+
+	return instances, index, raw != "", nil
+}
+
+// WatchQuery selects the changes streamed by Watch. Zero values watch the
+// whole registry.
+type WatchQuery struct {
+	Name      string
+	ServiceID string
+
+	// Resume starts the stream after the change with index LastEventID
+	// instead of at the next change. A non-zero LastEventID implies Resume.
+	Resume      bool
+	LastEventID uint64
+}
+
+// Watch streams registry changes to fn until ctx is done, the stream ends,
+// or fn returns an error. An event of type models.EventReset means changes
+// were missed and the caller should re-read the registry.
+//
+// The stream is long-lived, so the client's HTTPClient must not set a Timeout.
+func (c *RegistryClient) Watch(
+	ctx context.Context, q WatchQuery, fn func(models.Event) error,
+) error {
+	v := url.Values{}
+	if q.Name != "" {
+		v.Set("name", q.Name)
+	}
+	if q.ServiceID != "" {
+		v.Set("service_id", q.ServiceID)
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, "/watch?"+v.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if q.Resume || q.LastEventID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(q.LastEventID, 10))
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("registry: watch: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return newAPIError(req, resp)
+	}
+
+	var eventType, data string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if eventType == "" {
+				continue
+			}
+			var event models.Event
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				return fmt.Errorf("registry: watch: failed to decode event: %w", err)
+			}
+			event.Type = models.EventType(eventType)
+			if err := fn(event); err != nil {
+				return err
+			}
+			eventType, data = "", ""
+		case strings.HasPrefix(line, "event:"):
+			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("registry: watch: %w", err)
+	}
+
+	return ctx.Err()
+}
@@ -0,0 +1,105 @@
+package _go
+
+import (
+	"DirectoryService/models"
+	"DirectoryService/validate"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors matched by *APIError through errors.Is.
+var (
+	ErrNotFound    = errors.New("registry: not found")       // 404
+	ErrConflict    = errors.New("registry: conflict")        // 409
+	ErrInvalid     = errors.New("registry: invalid request") // 400 and 422
+	ErrUnavailable = errors.New("registry: unavailable")     // 503
+	ErrServer      = errors.New("registry: server error")    // any 5xx
+)
+
+// maxErrorBody caps how much of an error response is kept as the message.
+const maxErrorBody = 4 << 10
+
+// APIError is returned when the registry answers with a 4xx or 5xx status.
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Message    string // the problem detail, or the response body trimmed
+
+	// Code and RequestID are set when the registry answered with a problem
+	// response; Errors lists the invalid fields of a rejected payload.
+	Code      models.ErrorCode
+	RequestID string
+	Errors    validate.Errors
+}
+
+// newAPIError builds the APIError for an error response.
+func newAPIError(req *http.Request, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+
+	apiErr := &APIError{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	var problem models.Problem
+	if mediaType == models.ProblemContentType && json.Unmarshal(body, &problem) == nil {
+		apiErr.Message = problem.Detail
+		if apiErr.Message == "" {
+			apiErr.Message = problem.Title
+		}
+		apiErr.Code, apiErr.RequestID, apiErr.Errors = problem.Code, problem.RequestID, problem.Errors
+	}
+
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("registry: %s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Code != "" {
+		msg += " (" + string(e.Code) + ")"
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += " [request " + e.RequestID + "]"
+	}
+	return msg
+}
+
+// IsClientError reports whether the registry rejected the request (4xx).
+func (e *APIError) IsClientError() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
+// IsServerError reports whether the registry failed to handle the request (5xx).
+func (e *APIError) IsServerError() bool {
+	return e.StatusCode >= 500
+}
+
+// Is matches the sentinel error for the status code.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrInvalid:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	case ErrUnavailable:
+		return e.StatusCode == http.StatusServiceUnavailable
+	case ErrServer:
+		return e.IsServerError()
+	default:
+		return false
+	}
+}
@@ -1,32 +1,52 @@
 package _go
 
+import (
+	"DirectoryService/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
 // ServiceRegistry defines the interface for managing service lifecycle, health checks, and statistics.
 type ServiceRegistry interface {
-	// RegisterService registers the service in the registry.
+	// RegisterService registers the service in the registry and returns it with
+	// the ID the registry assigned. serviceID must be empty; it is kept for
+	// compatibility.
 	RegisterService(
+		ctx context.Context,
 		serviceID, name, description, ownerInfo, industryCategory string, clientRating float64,
-	) error
+	) (*models.Service, error)
 
 	// DeregisterService removes the service from the registry.
-	DeregisterService(serviceID string) error
+	DeregisterService(ctx context.Context, serviceID string) error
 
-	// UpdateServiceHealth updates the health status of the service in the registry.
-	UpdateServiceHealth(serviceID string, status HealthStatus) error
+	// UpdateServiceHealth reports the health status of a service instance to the
+	// registry. The registry tracks health per instance, so instanceID is the ID
+	// returned when the instance was registered.
+	UpdateServiceHealth(ctx context.Context, instanceID string, status HealthStatus) error
 
-	// PerformHealthCheck performs a health check for the given service.
-	PerformHealthCheck(serviceID string) (HealthStatus, error)
+	// PerformHealthCheck asks the registry to probe the service instance now and
+	// returns the resulting health status.
+	PerformHealthCheck(ctx context.Context, instanceID string) (HealthStatus, error)
 
 	// RetrieveStatistics retrieves the statistics for the given service in JSON format.
-	RetrieveStatistics(serviceID string) (ServiceStatistics, error)
+	RetrieveStatistics(ctx context.Context, serviceID string) (ServiceStatistics, error)
 }
 
 // HealthStatus represents the health status of a service.
 type HealthStatus string
 
 const (
-	Up      HealthStatus = "up"
-	Down    HealthStatus = "down"
-	Unknown HealthStatus = "unknown"
+	Starting HealthStatus = "starting"
+	Up       HealthStatus = "up"
+	Down     HealthStatus = "down"
+	Unknown  HealthStatus = "unknown"
 )
 
 // ServiceStatistics represents performance and usage statistics for a service.
@@ -39,12 +59,186 @@ type ServiceStatistics struct {
 
 // RegistryClient is an implementation of ServiceRegistry for interacting with the directory service.
 type RegistryClient struct {
-	BaseURL string // Base URL of the directory service
+	BaseURL    string       // Base URL of the directory service
+	HTTPClient *http.Client // Client used for requests; nil uses http.DefaultClient
 }
 
+// RegistryClient must satisfy ServiceRegistry.
+var _ ServiceRegistry = (*RegistryClient)(nil)
+
 // NewRegistryClient creates a new RegistryClient instance.
 func NewRegistryClient(baseURL string) *RegistryClient {
 	return &RegistryClient{BaseURL: baseURL}
 }
 
-// Implement the methods for RegistryClient...
+// RegisterService registers the service in the registry and returns it with
+// the ID assigned by the registry. The registry assigns service IDs and rates
+// services from their reviews, so a non-empty serviceID fails with ErrInvalid
+// and clientRating is ignored; both are kept for compatibility.
+func (c *RegistryClient) RegisterService(
+	ctx context.Context,
+	serviceID, name, description, ownerInfo, industryCategory string, clientRating float64,
+) (*models.Service, error) {
+	service := models.Service{
+		Name:             name,
+		Description:      description,
+		OwnerInfo:        ownerInfo,
+		IndustryCategory: industryCategory,
+		ClientRating:     clientRating,
+	}
+	if serviceID != "" {
+		return nil, fmt.Errorf("%w: service IDs are assigned by the registry", ErrInvalid)
+	}
+
+	var registered models.Service
+	if err := c.do(ctx, http.MethodPost, "/services", service, &registered); err != nil {
+		return nil, err
+	}
+
+	return &registered, nil
+}
+
+// DeregisterService removes the service from the registry. The registry
+// refuses to remove a service that still has instances.
+func (c *RegistryClient) DeregisterService(ctx context.Context, serviceID string) error {
+	return c.do(ctx, http.MethodDelete, "/services/"+url.PathEscape(serviceID), nil, nil)
+}
+
+// UpdateServiceHealth reports the health status of a service instance.
+func (c *RegistryClient) UpdateServiceHealth(
+	ctx context.Context, instanceID string, status HealthStatus,
+) error {
+	report := map[string]HealthStatus{"health_status": status}
+	path := "/service-instances/" + url.PathEscape(instanceID) + "/health"
+
+	return c.do(ctx, http.MethodPut, path, report, nil)
+}
+
+// PerformHealthCheck asks the registry to probe the service instance now.
+func (c *RegistryClient) PerformHealthCheck(ctx context.Context, instanceID string) (
+	HealthStatus, error,
+) {
+	var report struct {
+		HealthStatus HealthStatus `json:"health_status"`
+	}
+	path := "/service-instances/" + url.PathEscape(instanceID) + "/health-check"
+	if err := c.do(ctx, http.MethodPost, path, nil, &report); err != nil {
+		return "", err
+	}
+
+	return report.HealthStatus, nil
+}
+
+// RetrieveStatistics retrieves the statistics for the given service.
+func (c *RegistryClient) RetrieveStatistics(ctx context.Context, serviceID string) (
+	ServiceStatistics, error,
+) {
+	var stats ServiceStatistics
+	path := "/services/" + url.PathEscape(serviceID) + "/statistics"
+	if err := c.do(ctx, http.MethodGet, path, nil, &stats); err != nil {
+		return ServiceStatistics{}, err
+	}
+
+	return stats, nil
+}
+
+// RegisterInstance registers an instance of an existing service and returns
+// it with the ID and lease assigned by the registry.
+func (c *RegistryClient) RegisterInstance(
+	ctx context.Context, instance models.ServiceInstance,
+) (*models.ServiceInstance, error) {
+	var registered models.ServiceInstance
+	if err := c.do(ctx, http.MethodPost, "/service-instances", instance, &registered); err != nil {
+		return nil, err
+	}
+
+	return &registered, nil
+}
+
+// Heartbeat renews the lease of a service instance. A non-zero ttl replaces
+// the instance's lease TTL.
+func (c *RegistryClient) Heartbeat(ctx context.Context, instanceID string, ttl time.Duration) error {
+	var heartbeat any
+	if ttl > 0 {
+		heartbeat = map[string]models.Duration{"ttl": models.Duration(ttl)}
+	}
+	path := "/service-instances/" + url.PathEscape(instanceID) + "/heartbeat"
+
+	return c.do(ctx, http.MethodPut, path, heartbeat, nil)
+}
+
+// DeregisterInstance removes a service instance from the registry.
+func (c *RegistryClient) DeregisterInstance(ctx context.Context, instanceID string) error {
+	return c.do(ctx, http.MethodDelete, "/service-instances/"+url.PathEscape(instanceID), nil, nil)
+}
+
+// httpClient returns the client used for requests.
+func (c *RegistryClient) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// do sends in as the JSON body of a request to path and decodes the JSON
+// response into out. Either may be nil. Error responses are returned as
+// *APIError.
+func (c *RegistryClient) do(ctx context.Context, method, path string, in, out any) error {
+	_, err := c.send(ctx, method, path, in, out)
+	return err
+}
+
+// send is do that also returns the response headers, including those of an
+// error response.
+func (c *RegistryClient) send(ctx context.Context, method, path string, in, out any) (
+	http.Header, error,
+) {
+	req, err := c.newRequest(ctx, method, path, in)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("registry: %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp.Header, newAPIError(req, resp)
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.Header, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.Header, fmt.Errorf("registry: %s %s: failed to decode response: %w", method, path, err)
+	}
+
+	return resp.Header, nil
+}
+
+// newRequest builds a request to path with in, if not nil, as its JSON body.
+func (c *RegistryClient) newRequest(ctx context.Context, method, path string, in any) (
+	*http.Request, error,
+) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("registry: failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.BaseURL, "/")+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("registry: %w", err)
+	}
+	req.Header.Set("Accept", "application/json, "+models.ProblemContentType)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return req, nil
+}
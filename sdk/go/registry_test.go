@@ -0,0 +1,159 @@
+package _go
+
+import (
+	"DirectoryService/db"
+	"DirectoryService/handlers"
+	"DirectoryService/health"
+	"DirectoryService/models"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTestRegistry serves the registry API from an in-memory store.
+func setupTestRegistry(t *testing.T) (*RegistryClient, db.Store) {
+	store := db.NewWatchedStore(db.NewMemStore(), db.NewChangeFeed(0))
+	server := handlers.NewServer(store)
+	server.Checker = health.NewMonitor(store, health.Options{HealthyThreshold: 1})
+
+	ts := httptest.NewServer(server.NewRouter())
+	t.Cleanup(ts.Close)
+
+	return NewRegistryClient(ts.URL), store
+}
+
+func TestRegistryClientServiceLifecycle(t *testing.T) {
+	client, store := setupTestRegistry(t)
+	ctx := context.Background()
+
+	service, err := client.RegisterService(ctx, "", "Payments", "Card payments", "team-pay", "522320", 4.5)
+	if !assert.NoError(t, err, "RegisterService should not return an error") {
+		return
+	}
+	assert.NotEqual(t, uuid.Nil, service.ServiceID, "the registry should assign an ID")
+	assert.Equal(t, "Payments", service.Name)
+	serviceID := service.ServiceID
+
+	stored, err := store.GetService(ctx, serviceID)
+	assert.NoError(t, err, "the service should be stored under the returned ID")
+	assert.Equal(t, "Payments", stored.Name)
+
+	_, err = client.RegisterService(ctx, uuid.New().String(), "Payments", "", "", "", 0)
+	assert.ErrorIs(t, err, ErrInvalid, "the registry assigns service IDs")
+
+	stats, err := client.RetrieveStatistics(ctx, serviceID.String())
+	assert.NoError(t, err, "RetrieveStatistics should not return an error")
+	assert.Equal(t, serviceID.String(), stats.ServiceID)
+	assert.Equal(t, float64(0), stats.Details["instance_count"])
+
+	assert.NoError(t, client.DeregisterService(ctx, serviceID.String()))
+	err = client.DeregisterService(ctx, serviceID.String())
+	assert.ErrorIs(t, err, ErrNotFound, "the service should be gone")
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr), "errors should be *APIError")
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.True(t, apiErr.IsClientError())
+}
+
+func TestRegistryClientInstanceHealth(t *testing.T) {
+	client, store := setupTestRegistry(t)
+	ctx := context.Background()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	u, _ := url.Parse(target.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	service, err := store.RegisterService(ctx, models.Service{Name: "Payments"})
+	assert.NoError(t, err)
+	instance, err := store.CreateServiceInstance(
+		ctx, models.ServiceInstance{
+			ServiceID: service.ServiceID, Version: "1.0.0", Host: u.Hostname(), Port: port,
+		},
+	)
+	assert.NoError(t, err)
+
+	status, err := client.PerformHealthCheck(ctx, instance.InstanceID.String())
+	assert.NoError(t, err, "PerformHealthCheck should not return an error")
+	assert.Equal(t, Up, status)
+
+	assert.NoError(t, client.UpdateServiceHealth(ctx, instance.InstanceID.String(), Down))
+	stored, err := store.GetServiceInstance(ctx, instance.InstanceID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.HealthDown, stored.HealthStatus)
+
+	err = client.UpdateServiceHealth(ctx, instance.InstanceID.String(), "sideways")
+	assert.ErrorIs(t, err, ErrInvalid, "an unknown status should be rejected")
+
+	_, err = client.PerformHealthCheck(ctx, uuid.New().String())
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	var apiErr *APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, models.CodeNotFound, apiErr.Code, "the problem code should be decoded")
+		assert.NotEmpty(t, apiErr.RequestID)
+		assert.Contains(t, err.Error(), apiErr.RequestID)
+	}
+}
+
+func TestRegistryClientServerErrors(t *testing.T) {
+	var calls int
+	ts := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+			},
+		),
+	)
+	defer ts.Close()
+
+	client := NewRegistryClient(ts.URL + "/")
+	client.HTTPClient = &http.Client{Timeout: time.Second}
+
+	_, err := client.RetrieveStatistics(context.Background(), uuid.New().String())
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.ErrorIs(t, err, ErrServer)
+	assert.Contains(t, err.Error(), "database unavailable")
+	assert.Equal(t, 1, calls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = client.DeregisterService(ctx, uuid.New().String())
+	assert.ErrorIs(t, err, context.Canceled, "a cancelled context should abort the request")
+}
+
+func TestRegistryClientDiscoverSelector(t *testing.T) {
+	client, store := setupTestRegistry(t)
+	ctx := context.Background()
+
+	service, err := store.RegisterService(ctx, models.Service{Name: "Payments"})
+	assert.NoError(t, err)
+	for _, env := range []string{"prod", "staging"} {
+		_, err := store.CreateServiceInstance(
+			ctx, models.ServiceInstance{
+				ServiceID: service.ServiceID, Version: "1.0.0", Host: "localhost",
+				Labels: map[string]string{"env": env},
+			},
+		)
+		assert.NoError(t, err)
+	}
+
+	instances, _, err := client.Discover(ctx, DiscoverQuery{Name: "Payments", Selector: "env=prod"})
+	assert.NoError(t, err)
+	if assert.Len(t, instances, 1) {
+		assert.Equal(t, "prod", instances[0].Labels["env"])
+	}
+
+	_, _, err = client.Discover(ctx, DiscoverQuery{Name: "Payments", Selector: "env in prod"})
+	assert.ErrorIs(t, err, ErrInvalid, "a malformed selector should be rejected")
+}
@@ -0,0 +1,377 @@
+package _go
+
+import (
+	"DirectoryService/models"
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Strategy selects one of the cached instances of a Resolver.
+type Strategy int
+
+const (
+	RoundRobin   Strategy = iota // each instance in turn
+	Random                       // uniformly at random
+	LeastLatency                 // lowest observed latency; unmeasured instances first
+	Nearest                      // shortest distance from DiscoverQuery.Near
+)
+
+// RefreshMode is how a Resolver keeps its cache current.
+type RefreshMode int
+
+const (
+	RefreshPoll  RefreshMode = iota // blocking discovery queries
+	RefreshWatch                    // the /watch event stream
+)
+
+// Defaults for ResolverOptions.
+const (
+	DefaultPollWait      = 30 * time.Second
+	DefaultRetryInterval = time.Second
+	DefaultEjectAfter    = 3
+	DefaultEjectFor      = 30 * time.Second
+)
+
+// latencyWeight is the weight of a new sample in the latency moving average.
+const latencyWeight = 0.3
+
+// ErrNoInstances is returned by Pick when no instance can be selected.
+var ErrNoInstances = errors.New("registry: no instances available")
+
+// ResolverOptions configure a Resolver.
+type ResolverOptions struct {
+	Client *RegistryClient // registry to resolve against; required
+
+	// Query selects the instances to cache; Name or ServiceID is required.
+	// Its Index and Wait are managed by the Resolver.
+	Query DiscoverQuery
+
+	Strategy Strategy
+	Refresh  RefreshMode
+
+	// PollWait is how long each blocking query waits for a change. Defaults
+	// to DefaultPollWait.
+	PollWait time.Duration
+
+	// RetryInterval is the pause after a failed refresh. Defaults to
+	// DefaultRetryInterval.
+	RetryInterval time.Duration
+
+	// EjectAfter consecutive failures reported for an instance eject it from
+	// selection for EjectFor. Default to DefaultEjectAfter and DefaultEjectFor.
+	EjectAfter int
+	EjectFor   time.Duration
+
+	// OnError is called with refresh errors, which are otherwise retried.
+	OnError func(error)
+}
+
+// instanceStats is what a Resolver has observed of one instance.
+type instanceStats struct {
+	failures     int
+	ejectedUntil time.Time
+	latency      time.Duration // moving average
+	measured     bool
+}
+
+// Resolver caches the instances of a service, keeps them current in the
+// background and selects one per call. Callers report the outcome of each
+// call so that failing instances are ejected for a while (passive outlier
+// detection) and LeastLatency has latencies to compare.
+type Resolver struct {
+	opts   ResolverOptions
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu        sync.Mutex
+	instances []models.DiscoveredInstance
+	index     uint64
+	blocking  bool // the registry supports blocking queries
+	stats     map[uuid.UUID]*instanceStats
+	next      int
+}
+
+// NewResolver loads the instances selected by opts.Query and keeps them
+// current until ctx is cancelled or Close is called.
+func NewResolver(ctx context.Context, opts ResolverOptions) (*Resolver, error) {
+	if opts.Client == nil {
+		return nil, errors.New("registry: resolver requires a Client")
+	}
+	if opts.Query.Name == "" && opts.Query.ServiceID == "" {
+		return nil, errors.New("registry: resolver requires a service name or ID")
+	}
+	if opts.Strategy == Nearest && opts.Query.Near == nil {
+		return nil, errors.New("registry: the Nearest strategy requires Query.Near")
+	}
+	if opts.PollWait <= 0 {
+		opts.PollWait = DefaultPollWait
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = DefaultRetryInterval
+	}
+	if opts.EjectAfter <= 0 {
+		opts.EjectAfter = DefaultEjectAfter
+	}
+	if opts.EjectFor <= 0 {
+		opts.EjectFor = DefaultEjectFor
+	}
+
+	r := &Resolver{
+		opts:  opts,
+		done:  make(chan struct{}),
+		stats: make(map[uuid.UUID]*instanceStats),
+	}
+	if err := r.refresh(ctx, false); err != nil {
+		return nil, err
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	go func() {
+		defer close(r.done)
+		if opts.Refresh == RefreshWatch {
+			r.watch(ctx)
+		} else {
+			r.poll(ctx)
+		}
+	}()
+
+	return r, nil
+}
+
+// Close stops refreshing the cache.
+func (r *Resolver) Close() {
+	r.cancel()
+	<-r.done
+}
+
+// Instances returns the cached instances.
+func (r *Resolver) Instances() []models.DiscoveredInstance {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.instances)
+}
+
+// Pick selects an instance with the configured strategy, skipping instances
+// that are down, ejected or listed in exclude. When every instance that is
+// not down has been ejected, the ejected instances are used rather than none.
+func (r *Resolver) Pick(exclude ...uuid.UUID) (models.DiscoveredInstance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	candidates := r.candidates(time.Now(), exclude)
+	if len(candidates) == 0 {
+		return models.DiscoveredInstance{}, ErrNoInstances
+	}
+
+	start := r.next % len(candidates)
+	r.next++
+
+	switch r.opts.Strategy {
+	case Random:
+		return candidates[rand.IntN(len(candidates))], nil
+	case LeastLatency:
+		return r.best(candidates, start, func(i models.DiscoveredInstance) float64 {
+			if st := r.stats[i.InstanceID]; st != nil && st.measured {
+				return float64(st.latency)
+			}
+			return 0
+		}), nil
+	case Nearest:
+		return r.best(candidates, start, func(i models.DiscoveredInstance) float64 {
+			if i.DistanceKm == nil {
+				return math.Inf(1)
+			}
+			return *i.DistanceKm
+		}), nil
+	default:
+		return candidates[start], nil
+	}
+}
+
+// Report records the outcome of a call to an instance. A nil err records a
+// success and its latency; an error counts towards ejecting the instance.
+func (r *Resolver) Report(instanceID uuid.UUID, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st := r.stats[instanceID]
+	if st == nil {
+		st = &instanceStats{}
+		r.stats[instanceID] = st
+	}
+
+	if err != nil {
+		st.failures++
+		if st.failures >= r.opts.EjectAfter {
+			st.ejectedUntil = time.Now().Add(r.opts.EjectFor)
+			st.failures = 0
+		}
+		return
+	}
+
+	st.failures = 0
+	if st.measured {
+		st.latency = time.Duration(
+			latencyWeight*float64(latency) + (1-latencyWeight)*float64(st.latency),
+		)
+	} else {
+		st.latency, st.measured = latency, true
+	}
+}
+
+// candidates returns the instances Pick may select; the caller holds the lock.
+func (r *Resolver) candidates(now time.Time, exclude []uuid.UUID) []models.DiscoveredInstance {
+	var live, ejected []models.DiscoveredInstance
+	for _, instance := range r.instances {
+		if instance.HealthStatus == models.HealthDown || slices.Contains(exclude, instance.InstanceID) {
+			continue
+		}
+		if st := r.stats[instance.InstanceID]; st != nil && now.Before(st.ejectedUntil) {
+			ejected = append(ejected, instance)
+			continue
+		}
+		live = append(live, instance)
+	}
+
+	if len(live) == 0 {
+		return ejected
+	}
+	return live
+}
+
+// best returns the candidate with the lowest cost, breaking ties in
+// round-robin order from start.
+func (r *Resolver) best(
+	candidates []models.DiscoveredInstance, start int, cost func(models.DiscoveredInstance) float64,
+) models.DiscoveredInstance {
+	best := candidates[start]
+	bestCost := cost(best)
+	for n := 1; n < len(candidates); n++ {
+		candidate := candidates[(start+n)%len(candidates)]
+		if c := cost(candidate); c < bestCost {
+			best, bestCost = candidate, c
+		}
+	}
+	return best
+}
+
+// refresh reloads the cache. With block the call waits until the instances
+// change past the cached index or PollWait elapses. An unknown service
+// empties the cache, as it may still be registered later.
+func (r *Resolver) refresh(ctx context.Context, block bool) error {
+	q := r.opts.Query
+	q.Index, q.Wait = 0, 0
+	if block {
+		q.Index, q.Wait = r.currentIndex(), r.opts.PollWait
+	}
+
+	instances, index, blocking, err := r.opts.Client.discover(ctx, q)
+	if errors.Is(err, ErrNotFound) {
+		instances, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.instances, r.index, r.blocking = instances, index, blocking
+	for id := range r.stats {
+		if !slices.ContainsFunc(instances, func(i models.DiscoveredInstance) bool { return i.InstanceID == id }) {
+			delete(r.stats, id)
+		}
+	}
+	return nil
+}
+
+// canBlock reports whether the registry supports blocking queries, and so
+// returns the index a watch can resume from.
+func (r *Resolver) canBlock() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.blocking
+}
+
+// currentIndex returns the index the cache reflects.
+func (r *Resolver) currentIndex() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.index
+}
+
+// poll refreshes the cache with blocking queries until ctx is done.
+func (r *Resolver) poll(ctx context.Context) {
+	for ctx.Err() == nil {
+		block := r.canBlock()
+		if !block && !sleep(ctx, r.opts.PollWait) {
+			// without blocking queries the registry is polled every PollWait
+			return
+		}
+		if err := r.refresh(ctx, block); err != nil && ctx.Err() == nil {
+			r.fail(err)
+			sleep(ctx, r.opts.RetryInterval)
+		}
+	}
+}
+
+// watch refreshes the cache on every change streamed by the registry until
+// ctx is done. The stream resumes from the index of the cached result, so no
+// change between the two is missed.
+func (r *Resolver) watch(ctx context.Context) {
+	q := WatchQuery{Name: r.opts.Query.Name, ServiceID: r.opts.Query.ServiceID}
+
+	for ctx.Err() == nil {
+		q.Resume, q.LastEventID = r.canBlock(), r.currentIndex()
+		err := r.opts.Client.Watch(
+			ctx, q, func(event models.Event) error {
+				if event.Type != models.EventReset && event.Index <= r.currentIndex() {
+					return nil // already reflected in the cache
+				}
+				return r.refresh(ctx, false)
+			},
+		)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			r.fail(err)
+		}
+		if !sleep(ctx, r.opts.RetryInterval) {
+			return
+		}
+		if err := r.refresh(ctx, false); err != nil && ctx.Err() == nil {
+			r.fail(err)
+		}
+	}
+}
+
+// fail passes a background error to OnError.
+func (r *Resolver) fail(err error) {
+	if r.opts.OnError != nil {
+		r.opts.OnError(err)
+	}
+}
+
+// sleep waits for d and reports false if ctx was done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
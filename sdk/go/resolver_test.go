@@ -0,0 +1,176 @@
+package _go
+
+import (
+	"DirectoryService/db"
+	"DirectoryService/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// registerResolverInstances registers Payments with an instance in each of
+// Paris, London and New York, in that order.
+func registerResolverInstances(t *testing.T, store db.Store) (models.Service, []models.ServiceInstance) {
+	ctx := context.Background()
+	service, err := store.RegisterService(ctx, models.Service{Name: "Payments"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var instances []models.ServiceInstance
+	for _, location := range []Location{{48.8566, 2.3522}, {51.5074, -0.1278}, {40.7128, -74.0060}} {
+		instance, err := store.CreateServiceInstance(
+			ctx, models.ServiceInstance{
+				ServiceID: service.ServiceID,
+				Version:   "1.0.0",
+				Latitude:  location.Latitude,
+				Longitude: location.Longitude,
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instances = append(instances, *instance)
+	}
+
+	return *service, instances
+}
+
+func newTestResolver(t *testing.T, client *RegistryClient, opts ResolverOptions) *Resolver {
+	opts.Client = client
+	if opts.Query.Name == "" {
+		opts.Query.Name = "payments"
+	}
+	resolver, err := NewResolver(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(resolver.Close)
+
+	return resolver
+}
+
+func TestResolverStrategies(t *testing.T) {
+	client, store := setupTestRegistry(t)
+	_, instances := registerResolverInstances(t, store)
+	paris, london, newYork := instances[0].InstanceID, instances[1].InstanceID, instances[2].InstanceID
+
+	roundRobin := newTestResolver(t, client, ResolverOptions{Strategy: RoundRobin})
+	assert.Len(t, roundRobin.Instances(), 3)
+	seen := make(map[uuid.UUID]bool)
+	for range 3 {
+		instance, err := roundRobin.Pick()
+		assert.NoError(t, err)
+		seen[instance.InstanceID] = true
+	}
+	assert.Len(t, seen, 3, "round-robin should visit every instance")
+
+	random := newTestResolver(t, client, ResolverOptions{Strategy: Random})
+	instance, err := random.Pick(paris, london)
+	assert.NoError(t, err)
+	assert.Equal(t, newYork, instance.InstanceID, "excluded instances should be skipped")
+
+	nearest := newTestResolver(
+		t, client, ResolverOptions{
+			Strategy: Nearest,
+			Query:    DiscoverQuery{Name: "payments", Near: &Location{51.4816, -3.1791}}, // Cardiff
+		},
+	)
+	instance, err = nearest.Pick()
+	assert.NoError(t, err)
+	assert.Equal(t, london, instance.InstanceID, "London is nearest to Cardiff")
+
+	leastLatency := newTestResolver(t, client, ResolverOptions{Strategy: LeastLatency})
+	leastLatency.Report(paris, 30*time.Millisecond, nil)
+	leastLatency.Report(london, 10*time.Millisecond, nil)
+	leastLatency.Report(newYork, 90*time.Millisecond, nil)
+	for range 3 {
+		instance, err = leastLatency.Pick()
+		assert.NoError(t, err)
+		assert.Equal(t, london, instance.InstanceID, "the fastest instance should be picked")
+	}
+
+	_, err = NewResolver(context.Background(), ResolverOptions{Client: client, Strategy: Nearest, Query: DiscoverQuery{Name: "payments"}})
+	assert.Error(t, err, "Nearest requires a location")
+}
+
+func TestResolverEjectsFailingInstances(t *testing.T) {
+	client, store := setupTestRegistry(t)
+	_, instances := registerResolverInstances(t, store)
+	paris := instances[0].InstanceID
+
+	resolver := newTestResolver(t, client, ResolverOptions{EjectAfter: 2, EjectFor: time.Hour})
+
+	failure := errors.New("connection refused")
+	resolver.Report(paris, 0, failure)
+	resolver.Report(paris, 0, failure)
+	for range 6 {
+		instance, err := resolver.Pick()
+		assert.NoError(t, err)
+		assert.NotEqual(t, paris, instance.InstanceID, "an ejected instance should not be picked")
+	}
+
+	for _, instance := range instances[1:] {
+		resolver.Report(instance.InstanceID, 0, failure)
+		resolver.Report(instance.InstanceID, 0, failure)
+	}
+	_, err := resolver.Pick()
+	assert.NoError(t, err, "ejected instances are used when nothing else is left")
+}
+
+func TestResolverRefresh(t *testing.T) {
+	for name, mode := range map[string]RefreshMode{"poll": RefreshPoll, "watch": RefreshWatch} {
+		t.Run(
+			name, func(t *testing.T) {
+				client, store := setupTestRegistry(t)
+				ctx := context.Background()
+
+				resolver := newTestResolver(t, client, ResolverOptions{Refresh: mode})
+				_, err := resolver.Pick()
+				assert.ErrorIs(t, err, ErrNoInstances, "the service is not registered yet")
+
+				service, instances := registerResolverInstances(t, store)
+				assert.Eventually(
+					t, func() bool { return len(resolver.Instances()) == 3 }, 2*time.Second, 10*time.Millisecond,
+					"new instances should be picked up",
+				)
+
+				// only London stays up
+				for _, instance := range []models.ServiceInstance{instances[0], instances[2]} {
+					_, err := store.UpdateServiceInstanceHealth(ctx, instance.InstanceID, models.HealthDown, time.Now())
+					assert.NoError(t, err)
+				}
+				assert.Eventually(
+					t, func() bool {
+						instance, err := resolver.Pick()
+						return err == nil && instance.InstanceID == instances[1].InstanceID &&
+							len(resolver.Instances()) == 3 && countDown(resolver.Instances()) == 2
+					}, 2*time.Second, 10*time.Millisecond, "instances that are down should be skipped",
+				)
+
+				assert.NoError(t, store.RemoveServiceInstance(ctx, instances[1].InstanceID))
+				assert.Eventually(
+					t, func() bool { return len(resolver.Instances()) == 2 }, 2*time.Second, 10*time.Millisecond,
+					"removed instances should be dropped",
+				)
+				_, err = resolver.Pick()
+				assert.ErrorIs(t, err, ErrNoInstances)
+				assert.Equal(t, service.ServiceID, resolver.Instances()[0].ServiceID)
+			},
+		)
+	}
+}
+
+func countDown(instances []models.DiscoveredInstance) int {
+	n := 0
+	for _, instance := range instances {
+		if instance.HealthStatus == models.HealthDown {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,322 @@
+package _go
+
+import (
+	"DirectoryService/models"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scheme is the URL scheme handled by Transport.
+const Scheme = "registry"
+
+// Defaults for Transport.
+const (
+	DefaultMaxAttempts = 3
+	DefaultRetryRatio  = 0.2
+	DefaultRetryBurst  = 10
+)
+
+// Transport is an http.RoundTripper for registry URLs such as
+// registry://payments@1.2/charge, which names version 1.2 (or newer) of the
+// payments service; registry://payments/charge accepts any version. Each
+// request is sent to an instance picked by a Resolver, with the scheme, host
+// and base path of the instance's URL.
+//
+// A request that fails to connect or receives a 5xx response is retried on
+// another instance, up to MaxAttempts attempts. Retries are limited by a
+// budget: every request earns RetryRatio retries, up to RetryBurst saved, so
+// that retries cannot multiply the load on a failing service.
+//
+// To make http.Get understand registry URLs, register the transport with the
+// default transport:
+//
+//	http.DefaultTransport.(*http.Transport).RegisterProtocol("registry", transport)
+type Transport struct {
+	Client *RegistryClient // registry to resolve against; required
+
+	// Base sends the rewritten requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+
+	// Resolver configures the resolver created for each service; its Client
+	// and the name and version of its Query are set by the Transport.
+	Resolver ResolverOptions
+
+	// MaxAttempts bounds the attempts per request. Defaults to DefaultMaxAttempts.
+	MaxAttempts int
+
+	// RetryRatio and RetryBurst size the retry budget. Default to
+	// DefaultRetryRatio and DefaultRetryBurst.
+	RetryRatio float64
+	RetryBurst int
+
+	mu        sync.Mutex
+	resolvers map[string]*Resolver
+	creating  map[string]*resolverCall // resolvers being created, by key
+	budget    *retryBudget
+}
+
+// resolverCall is the creation of a resolver, which requests for the same
+// service wait for. done is closed once resolver and err are set.
+type resolverCall struct {
+	done     chan struct{}
+	resolver *Resolver
+	err      error
+}
+
+// NewTransport creates a Transport that resolves services with client.
+func NewTransport(client *RegistryClient) *Transport {
+	return &Transport{Client: client}
+}
+
+// Close stops the resolvers the transport has created.
+func (t *Transport) Close() {
+	t.mu.Lock()
+	resolvers := t.resolvers
+	t.resolvers = nil
+	t.mu.Unlock()
+
+	for _, resolver := range resolvers {
+		resolver.Close()
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		// the body is replaced on every attempt
+		defer req.Body.Close()
+	}
+	if req.URL.Scheme != Scheme {
+		return nil, fmt.Errorf("registry: unsupported scheme %q", req.URL.Scheme)
+	}
+
+	name, version := serviceOf(req.URL)
+	resolver, err := t.resolver(req.Context(), name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	maxAttempts := t.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		maxAttempts = 1 // the body cannot be sent twice
+	}
+	budget := t.retryBudget()
+	budget.deposit()
+
+	instance, err := resolver.Pick()
+	if err != nil {
+		return nil, fmt.Errorf("registry: %s: %w", req.URL.Host, err)
+	}
+	tried := []uuid.UUID{instance.InstanceID}
+
+	for attempt := 1; ; attempt++ {
+		outReq, err := rewrite(req, instance)
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		resp, err := t.base().RoundTrip(outReq)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			resolver.Report(instance.InstanceID, time.Since(start), nil)
+			return resp, nil
+		}
+
+		failure := err
+		if failure == nil {
+			failure = fmt.Errorf("registry: %s", resp.Status)
+		}
+		resolver.Report(instance.InstanceID, time.Since(start), failure)
+
+		// retry on an instance not tried yet, while attempts and budget last
+		if attempt >= maxAttempts || req.Context().Err() != nil {
+			return resp, err
+		}
+		next, pickErr := resolver.Pick(tried...)
+		if pickErr != nil || !budget.withdraw() {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+		}
+		instance = next
+		tried = append(tried, instance.InstanceID)
+	}
+}
+
+// serviceOf returns the service name and version named by a registry URL.
+// In registry://payments@1.2 the name parses as the user and the version as
+// the host.
+func serviceOf(u *url.URL) (name, version string) {
+	if u.User != nil {
+		return u.User.Username(), u.Host
+	}
+	return u.Host, ""
+}
+
+// resolver returns the resolver for a service and version, creating it on
+// first use. The resolver's first lookup runs without holding t.mu, and
+// concurrent requests for the same service wait for it instead of starting
+// their own.
+func (t *Transport) resolver(ctx context.Context, name, version string) (*Resolver, error) {
+	key := name + "@" + version
+
+	t.mu.Lock()
+	if resolver, ok := t.resolvers[key]; ok {
+		t.mu.Unlock()
+		return resolver, nil
+	}
+	call, ok := t.creating[key]
+	if !ok {
+		call = &resolverCall{done: make(chan struct{})}
+		if t.creating == nil {
+			t.creating = make(map[string]*resolverCall)
+		}
+		t.creating[key] = call
+	}
+	t.mu.Unlock()
+
+	if !ok {
+		t.createResolver(ctx, key, name, version, call)
+	}
+
+	select {
+	case <-call.done:
+		return call.resolver, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// createResolver creates the resolver of call and stores it under key.
+func (t *Transport) createResolver(
+	ctx context.Context, key, name, version string, call *resolverCall,
+) {
+	defer close(call.done)
+
+	opts := t.Resolver
+	opts.Client = t.Client
+	opts.Query.Name, opts.Query.ServiceID, opts.Query.Version = name, "", version
+
+	// The resolver outlives the request that created it
+	call.resolver, call.err = NewResolver(context.WithoutCancel(ctx), opts)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.creating, key)
+	if call.err != nil {
+		return
+	}
+	if t.resolvers == nil {
+		t.resolvers = make(map[string]*Resolver)
+	}
+	t.resolvers[key] = call.resolver
+}
+
+// rewrite returns a copy of req addressed to instance.
+func rewrite(req *http.Request, instance models.DiscoveredInstance) (*http.Request, error) {
+	base, err := instanceURL(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	out := req.Clone(req.Context())
+	out.URL.Scheme = base.Scheme
+	out.URL.Host = base.Host
+	out.URL.User = nil
+	out.URL.Path = strings.TrimRight(base.Path, "/") + req.URL.Path
+	out.URL.RawPath = ""
+	out.Host = ""
+	if req.GetBody != nil {
+		if out.Body, err = req.GetBody(); err != nil {
+			return nil, fmt.Errorf("registry: failed to rewind request body: %w", err)
+		}
+	}
+
+	return out, nil
+}
+
+// instanceURL returns the base URL of an instance: its registered URL, or
+// http://host:port when it has none.
+func instanceURL(instance models.DiscoveredInstance) (*url.URL, error) {
+	if instance.Url != "" {
+		u, err := url.Parse(instance.Url)
+		if err != nil {
+			return nil, fmt.Errorf("registry: instance %s has an invalid url: %w", instance.InstanceID, err)
+		}
+		return u, nil
+	}
+	if instance.Host == "" {
+		return nil, fmt.Errorf("registry: instance %s has no address", instance.InstanceID)
+	}
+
+	return &url.URL{Scheme: "http", Host: net.JoinHostPort(instance.Host, strconv.Itoa(instance.Port))}, nil
+}
+
+// base returns the transport that sends rewritten requests.
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// retryBudget returns the transport's retry budget, creating it on first use.
+func (t *Transport) retryBudget() *retryBudget {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.budget == nil {
+		ratio, burst := t.RetryRatio, t.RetryBurst
+		if ratio <= 0 {
+			ratio = DefaultRetryRatio
+		}
+		if burst <= 0 {
+			burst = DefaultRetryBurst
+		}
+		t.budget = &retryBudget{tokens: float64(burst), max: float64(burst), ratio: ratio}
+	}
+	return t.budget
+}
+
+// retryBudget is a token bucket: each request deposits ratio tokens and each
+// retry withdraws one.
+type retryBudget struct {
+	mu     sync.Mutex
+	tokens float64
+	max    float64
+	ratio  float64
+}
+
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.max, b.tokens+b.ratio)
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
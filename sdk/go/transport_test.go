@@ -0,0 +1,203 @@
+package _go
+
+import (
+	"DirectoryService/db"
+	"DirectoryService/models"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// registerBackend registers an instance of service served by handler.
+func registerBackend(
+	t *testing.T, store db.Store, service models.Service, version string, handler http.HandlerFunc,
+) *httptest.Server {
+	backend := httptest.NewServer(handler)
+	t.Cleanup(backend.Close)
+
+	_, err := store.CreateServiceInstance(
+		context.Background(), models.ServiceInstance{
+			ServiceID: service.ServiceID, Version: version, Url: backend.URL + "/api",
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return backend
+}
+
+func newTestTransport(t *testing.T, client *RegistryClient) (*Transport, *http.Client) {
+	transport := NewTransport(client)
+	t.Cleanup(transport.Close)
+
+	return transport, &http.Client{Transport: transport}
+}
+
+func TestTransportRewritesAndRetries(t *testing.T) {
+	client, store := setupTestRegistry(t)
+	service, err := store.RegisterService(context.Background(), models.Service{Name: "Payments"})
+	assert.NoError(t, err)
+
+	var failing, healthy atomic.Int32
+	registerBackend(
+		t, store, *service, "1.2.0", func(w http.ResponseWriter, r *http.Request) {
+			failing.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	)
+	registerBackend(
+		t, store, *service, "1.3.0", func(w http.ResponseWriter, r *http.Request) {
+			healthy.Add(1)
+			body, _ := io.ReadAll(r.Body)
+			_, _ = io.WriteString(w, r.Method+" "+r.URL.RequestURI()+" "+string(body))
+		},
+	)
+	registerBackend(
+		t, store, *service, "1.1.0", func(w http.ResponseWriter, r *http.Request) {
+			t.Error("version 1.1.0 does not satisfy 1.2")
+		},
+	)
+
+	_, httpClient := newTestTransport(t, client)
+	for range 4 {
+		resp, err := httpClient.Post(
+			"registry://payments@1.2/charge?amount=10", "text/plain", strings.NewReader("card"),
+		)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "POST /api/charge?amount=10 card", string(body), "the URL should be rewritten")
+	}
+	assert.Equal(t, int32(4), healthy.Load())
+	assert.Positive(t, failing.Load(), "the failing instance should have been tried and retried")
+}
+
+func TestTransportRetriesConnectionFailures(t *testing.T) {
+	client, store := setupTestRegistry(t)
+	service, err := store.RegisterService(context.Background(), models.Service{Name: "Payments"})
+	assert.NoError(t, err)
+
+	down := registerBackend(t, store, *service, "1.0.0", func(http.ResponseWriter, *http.Request) {})
+	down.Close()
+	registerBackend(t, store, *service, "1.0.0", func(http.ResponseWriter, *http.Request) {})
+
+	_, httpClient := newTestTransport(t, client)
+	for range 3 {
+		resp, err := httpClient.Get("registry://payments/ping")
+		assert.NoError(t, err, "the request should fail over to the live instance")
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+func TestTransportRetryBudget(t *testing.T) {
+	client, store := setupTestRegistry(t)
+	service, err := store.RegisterService(context.Background(), models.Service{Name: "Payments"})
+	assert.NoError(t, err)
+
+	var calls atomic.Int32
+	for range 3 {
+		registerBackend(
+			t, store, *service, "1.0.0", func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+		)
+	}
+
+	transport, httpClient := newTestTransport(t, client)
+	transport.RetryBurst = 2
+	transport.RetryRatio = 0.01
+
+	resp, err := httpClient.Get("registry://payments/ping")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "the last 5xx is returned")
+	assert.Equal(t, int32(3), calls.Load(), "the first request may retry on both other instances")
+
+	resp, err = httpClient.Get("registry://payments/ping")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(4), calls.Load(), "the exhausted budget should stop retries")
+
+	_, err = httpClient.Get("registry://ledger/ping")
+	assert.ErrorIs(t, err, ErrNoInstances, "an unknown service has no instances")
+}
+
+// heldLookups is a registry transport that holds the first lookups of one
+// service, those without an index, until release is closed.
+type heldLookups struct {
+	name    string
+	release chan struct{}
+	lookups atomic.Int32
+}
+
+func (h *heldLookups) RoundTrip(req *http.Request) (*http.Response, error) {
+	query := req.URL.Query()
+	if req.URL.Path == "/discover" && query.Get("name") == h.name && query.Get("index") == "" {
+		h.lookups.Add(1)
+		<-h.release
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestTransportCreatesResolversOutsideLock(t *testing.T) {
+	client, store := setupTestRegistry(t)
+	ctx := context.Background()
+	for _, name := range []string{"Payments", "Ledger"} {
+		service, err := store.RegisterService(ctx, models.Service{Name: name})
+		assert.NoError(t, err)
+		registerBackend(t, store, *service, "1.0.0", func(http.ResponseWriter, *http.Request) {})
+	}
+
+	held := &heldLookups{name: "payments", release: make(chan struct{})}
+	client.HTTPClient = &http.Client{Transport: held}
+	_, httpClient := newTestTransport(t, client)
+
+	// Requests for Payments wait for the one lookup in flight
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := httpClient.Get("registry://payments/ping")
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+			}
+		}()
+	}
+	assert.Eventually(
+		t, func() bool { return held.lookups.Load() > 0 }, 5*time.Second, time.Millisecond,
+	)
+
+	// Meanwhile requests for Ledger go ahead
+	ledger := make(chan error, 1)
+	go func() {
+		resp, err := httpClient.Get("registry://ledger/ping")
+		if err == nil {
+			resp.Body.Close()
+		}
+		ledger <- err
+	}()
+	select {
+	case err := <-ledger:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Error("the Payments lookup should not hold up Ledger")
+	}
+
+	close(held.release)
+	wg.Wait()
+	assert.Equal(t, int32(1), held.lookups.Load(), "concurrent requests should share the lookup")
+}
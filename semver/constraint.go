@@ -0,0 +1,51 @@
+package semver
+
+import "strconv"
+
+// Constraint is a client version request as described in ServiceRegistry.md:
+// "1" matches 1.0.0 or newer, "1.2" matches 1.2.0 or newer and "1.2.3" matches
+// 1.2.3 or newer.
+//
+// Pre-release versions only match when the constraint is itself a pre-release
+// of the same MAJOR.MINOR.PATCH (so "1.2.3-beta" matches "1.2.3-beta.2" but not
+// "1.3.0-alpha"), or when pre-releases are explicitly allowed.
+type Constraint struct {
+	Min        Version
+	Components int // how many of MAJOR.MINOR.PATCH were given
+}
+
+// ParseConstraint parses "1", "1.2", "1.2.3" or a full version with a pre-release.
+func ParseConstraint(s string) (Constraint, error) {
+	v, parts, err := parse(s)
+	if err != nil {
+		return Constraint{}, err
+	}
+
+	return Constraint{Min: v, Components: parts}, nil
+}
+
+// Match reports whether v satisfies the constraint. allowPreRelease admits
+// every pre-release that is at or above the minimum.
+func (c Constraint) Match(v Version, allowPreRelease bool) bool {
+	if v.Compare(c.Min) < 0 {
+		return false
+	}
+	if !v.IsPreRelease() || allowPreRelease {
+		return true
+	}
+
+	return c.Min.IsPreRelease() &&
+		v.Major == c.Min.Major && v.Minor == c.Min.Minor && v.Patch == c.Min.Patch
+}
+
+// String formats the constraint the way it was given.
+func (c Constraint) String() string {
+	switch c.Components {
+	case 1:
+		return strconv.Itoa(c.Min.Major)
+	case 2:
+		return strconv.Itoa(c.Min.Major) + "." + strconv.Itoa(c.Min.Minor)
+	default:
+		return c.Min.String()
+	}
+}
@@ -0,0 +1,205 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed Semantic Version (https://semver.org).
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease []string // dot separated pre-release identifiers, e.g. ["beta", "2"]
+	Build      string   // build metadata; ignored for precedence
+}
+
+// Parse parses a full semantic version such as "1.2.3", "1.2.3-rc.1" or
+// "v1.2.3+build.7". A leading "v" is accepted.
+func Parse(s string) (Version, error) {
+	v, parts, err := parse(s)
+	if err != nil {
+		return Version{}, err
+	}
+	if parts != 3 {
+		return Version{}, fmt.Errorf("invalid semantic version %q: expected MAJOR.MINOR.PATCH", s)
+	}
+
+	return v, nil
+}
+
+// parse parses a version with one to three numeric components and returns how
+// many were present.
+func parse(s string) (Version, int, error) {
+	var v Version
+
+	str := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if str == "" {
+		return v, 0, fmt.Errorf("invalid semantic version %q: empty", s)
+	}
+
+	if i := strings.IndexByte(str, '+'); i >= 0 {
+		v.Build = str[i+1:]
+		str = str[:i]
+		if !validIdentifiers(v.Build, false) {
+			return v, 0, fmt.Errorf("invalid semantic version %q: bad build metadata", s)
+		}
+	}
+
+	if i := strings.IndexByte(str, '-'); i >= 0 {
+		pre := str[i+1:]
+		str = str[:i]
+		if !validIdentifiers(pre, true) {
+			return v, 0, fmt.Errorf("invalid semantic version %q: bad pre-release", s)
+		}
+		v.PreRelease = strings.Split(pre, ".")
+	}
+
+	fields := strings.Split(str, ".")
+	if len(fields) > 3 {
+		return v, 0, fmt.Errorf("invalid semantic version %q: too many components", s)
+	}
+
+	numbers := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, field := range fields {
+		n, err := parseNumber(field)
+		if err != nil {
+			return v, 0, fmt.Errorf("invalid semantic version %q: %w", s, err)
+		}
+		*numbers[i] = n
+	}
+
+	if len(fields) < 3 && (v.PreRelease != nil || v.Build != "") {
+		return v, 0, fmt.Errorf(
+			"invalid semantic version %q: pre-release requires MAJOR.MINOR.PATCH", s,
+		)
+	}
+
+	return v, len(fields), nil
+}
+
+// parseNumber parses a numeric component without leading zeros.
+func parseNumber(s string) (int, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty component")
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("component %q has a leading zero", s)
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("component %q is not numeric", s)
+		}
+	}
+
+	return strconv.Atoi(s)
+}
+
+// validIdentifiers checks dot separated identifiers of [0-9A-Za-z-]. Numeric
+// pre-release identifiers must not have leading zeros.
+func validIdentifiers(s string, preRelease bool) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		numeric := true
+		for _, c := range id {
+			switch {
+			case c >= '0' && c <= '9':
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '-':
+				numeric = false
+			default:
+				return false
+			}
+		}
+		if preRelease && numeric && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String formats the version.
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.PreRelease) > 0 {
+		s += "-" + strings.Join(v.PreRelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+
+	return s
+}
+
+// IsPreRelease reports whether the version has pre-release identifiers.
+func (v Version) IsPreRelease() bool {
+	return len(v.PreRelease) > 0
+}
+
+// Compare returns -1, 0 or +1 depending on whether v has lower, equal or higher
+// precedence than o. Build metadata is ignored.
+func (v Version) Compare(o Version) int {
+	if c := compareInt(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+
+	// A pre-release has lower precedence than the associated normal version
+	switch {
+	case len(v.PreRelease) == 0 && len(o.PreRelease) == 0:
+		return 0
+	case len(v.PreRelease) == 0:
+		return 1
+	case len(o.PreRelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.PreRelease) && i < len(o.PreRelease); i++ {
+		if c := compareIdentifier(v.PreRelease[i], o.PreRelease[i]); c != 0 {
+			return c
+		}
+	}
+
+	return compareInt(len(v.PreRelease), len(o.PreRelease))
+}
+
+// compareIdentifier compares pre-release identifiers: numeric identifiers
+// compare numerically and sort before alphanumeric ones.
+func compareIdentifier(a, b string) int {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareInt(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
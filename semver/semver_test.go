@@ -0,0 +1,98 @@
+package semver
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	v, err := Parse("v1.2.3-rc.1+build.7")
+	assert.NoError(t, err, "Parse should not return an error")
+	assert.Equal(t, 1, v.Major)
+	assert.Equal(t, 2, v.Minor)
+	assert.Equal(t, 3, v.Patch)
+	assert.Equal(t, []string{"rc", "1"}, v.PreRelease)
+	assert.Equal(t, "build.7", v.Build)
+	assert.Equal(t, "1.2.3-rc.1+build.7", v.String())
+
+	invalid := []string{
+		"", "1", "1.2", "1.2.3.4", "01.2.3", "1.2.x", "1.2.3-", "1.2.3-01", "1.2.3-a..b",
+	}
+	for _, invalid := range invalid {
+		_, err := Parse(invalid)
+		assert.Error(t, err, "Parse(%q) should fail", invalid)
+	}
+}
+
+func TestCompareOrdersBySemVerPrecedence(t *testing.T) {
+	// Precedence example from the SemVer specification
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2",
+		"1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.2.0", "1.10.0", "2.0.0",
+	}
+
+	versions := make([]Version, len(ordered))
+	for i := range ordered {
+		// parse in reverse so sorting has work to do
+		v, err := Parse(ordered[len(ordered)-1-i])
+		assert.NoError(t, err)
+		versions[i] = v
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Compare(versions[j]) < 0 })
+
+	for i, v := range versions {
+		assert.Equal(t, ordered[i], v.String())
+	}
+
+	a, _ := Parse("1.0.0+build.1")
+	b, _ := Parse("1.0.0+build.2")
+	assert.Equal(t, 0, a.Compare(b), "build metadata should not affect precedence")
+}
+
+func TestConstraintMatch(t *testing.T) {
+	cases := []struct {
+		constraint string
+		version    string
+		pre        bool
+		want       bool
+	}{
+		{"1", "1.0.0", false, true},
+		{"1", "1.9.3", false, true},
+		{"1", "2.0.0", false, true},
+		{"1", "0.9.0", false, false},
+		{"1.2", "1.2.0", false, true},
+		{"1.2", "1.1.9", false, false},
+		{"1.2", "3.0.0", false, true},
+		{"1.2.3", "1.2.3", false, true},
+		{"1.2.3", "1.2.2", false, false},
+		{"1.2.3", "1.2.4-beta", false, false},
+		{"1.2.3", "1.2.4-beta", true, true},
+		{"1.2.3-beta", "1.2.3-beta.2", false, true},
+		{"1.2.3-beta", "1.2.3", false, true},
+		{"1.2.3-beta", "1.3.0-alpha", false, false},
+		{"1.2.3-beta", "1.2.3-alpha", false, false},
+	}
+
+	for _, c := range cases {
+		constraint, err := ParseConstraint(c.constraint)
+		assert.NoError(t, err)
+		v, err := Parse(c.version)
+		assert.NoError(t, err)
+		assert.Equal(
+			t, c.want, constraint.Match(v, c.pre),
+			"%s matching %s (pre-release allowed: %v)", c.constraint, c.version, c.pre,
+		)
+	}
+}
+
+func TestParseConstraint(t *testing.T) {
+	constraint, err := ParseConstraint("1.2")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, constraint.Components)
+	assert.Equal(t, "1.2", constraint.String())
+
+	_, err = ParseConstraint("1.2-beta")
+	assert.Error(t, err, "a pre-release needs a full version")
+}
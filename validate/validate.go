@@ -0,0 +1,234 @@
+package validate
+
+import (
+	"DirectoryService/industry"
+	"DirectoryService/labels"
+	"DirectoryService/semver"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes a field that failed one of its rules.
+type FieldError struct {
+	Field   string `json:"field"` // JSON name, dotted for nested fields
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors lists every field of a value that failed validation.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + " " + fe.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Struct checks the exported fields of the struct v against the rules in
+// their `validate` tags, descending into nested structs, and returns Errors
+// listing every failure, or nil. Rules are separated by commas:
+//
+//	required   the field must not be its zero value
+//	min=N      numbers must be >= N, strings at least N characters long and
+//	           slices and maps at least N entries long
+//	max=N      numbers must be <= N, strings at most N characters long and
+//	           slices and maps at most N entries long
+//	oneof=A B  the string must be one of the space separated values
+//	semver     a semantic version such as 1.2.3
+//	url        an absolute URL with a scheme and host
+//	hostname   a DNS hostname or an IP address
+//	labels     a map[string]string of valid label keys and values
+//	industry   a NAICS or ISIC category such as NAICS:5415 or ISIC:6201
+//
+// Apart from required, min and max, rules accept an empty value. Struct panics
+// on a malformed tag, which is a programming error.
+func Struct(v any) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: %T is not a struct", v))
+	}
+
+	var errs Errors
+	checkStruct(value, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// checkStruct validates the fields of value, naming them under prefix.
+func checkStruct(value reflect.Value, prefix string, errs *Errors) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := prefix + fieldName(field)
+		fv := value.Field(i)
+		if tag := field.Tag.Get("validate"); tag != "" && tag != "-" {
+			for _, rule := range strings.Split(tag, ",") {
+				if fe := check(fv, name, rule); fe != nil {
+					*errs = append(*errs, *fe)
+					break // one error per field
+				}
+			}
+		}
+
+		// nested structs carry their own rules
+		if fv.Kind() == reflect.Pointer && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			checkStruct(fv, name+".", errs)
+		}
+	}
+}
+
+// fieldName returns the JSON name of a field.
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// check applies one rule to a field value and returns the failure, if any.
+func check(v reflect.Value, field, rule string) *FieldError {
+	name, arg, _ := strings.Cut(rule, "=")
+	fail := func(format string, args ...any) *FieldError {
+		return &FieldError{Field: field, Rule: name, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if name == "required" {
+		if v.IsZero() {
+			return fail("is required")
+		}
+		return nil
+	}
+
+	if name == "min" || name == "max" {
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validate: %s: invalid rule %q", field, rule))
+		}
+		n, kind := size(v, field)
+		tooSmall, tooLarge := "must be at least %s", "must be at most %s"
+		switch kind {
+		case reflect.String:
+			tooSmall, tooLarge = "must be at least %s characters long", "must be at most %s characters long"
+		case reflect.Slice:
+			tooSmall, tooLarge = "must have at least %s entries", "must have at most %s entries"
+		}
+		switch {
+		case name == "min" && (n < limit || math.IsNaN(n)):
+			return fail(tooSmall, arg)
+		case name == "max" && (n > limit || math.IsNaN(n)):
+			return fail(tooLarge, arg)
+		}
+		return nil
+	}
+
+	if name == "labels" {
+		m, ok := v.Interface().(map[string]string)
+		if !ok {
+			panic(fmt.Sprintf("validate: %s: rule %q applies to map[string]string only", field, rule))
+		}
+		if err := labels.Validate(m); err != nil {
+			return fail("has an %v", err)
+		}
+		return nil
+	}
+
+	if v.Kind() != reflect.String {
+		panic(fmt.Sprintf("validate: %s: rule %q applies to strings only", field, rule))
+	}
+	s := v.String()
+	if s == "" {
+		return nil
+	}
+
+	switch name {
+	case "oneof":
+		allowed := strings.Fields(arg)
+		for _, a := range allowed {
+			if s == a {
+				return nil
+			}
+		}
+		return fail("must be one of %s", strings.Join(allowed, ", "))
+	case "semver":
+		if _, err := semver.Parse(s); err != nil {
+			return fail("must be a semantic version such as 1.2.3")
+		}
+	case "url":
+		if u, err := url.Parse(s); err != nil || u.Scheme == "" || u.Host == "" {
+			return fail("must be an absolute URL such as https://host:8443/api")
+		}
+	case "hostname":
+		if !isHostname(s) {
+			return fail("must be a hostname or an IP address")
+		}
+	case "industry":
+		if _, err := industry.Lookup(s); err != nil {
+			return fail("must be a NAICS or ISIC category such as NAICS:5415 or ISIC:6201")
+		}
+	default:
+		panic(fmt.Sprintf("validate: %s: unknown rule %q", field, rule))
+	}
+
+	return nil
+}
+
+// size returns the number min and max compare: a number's value, a string's
+// length in characters or the length of a slice or map, and the kind of value
+// measured, reflect.Slice for maps too.
+func size(v reflect.Value, field string) (n float64, kind reflect.Kind) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), reflect.String
+	case reflect.Slice, reflect.Map:
+		return float64(v.Len()), reflect.Slice
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), reflect.Int
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), reflect.Uint
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), reflect.Float64
+	default:
+		panic(fmt.Sprintf("validate: %s: min and max do not apply to %s", field, v.Kind()))
+	}
+}
+
+// isHostname reports whether s is an IP address or an RFC 1123 hostname.
+func isHostname(s string) bool {
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	if len(s) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(strings.TrimSuffix(s, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
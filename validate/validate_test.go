@@ -0,0 +1,88 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type address struct {
+	Host string `json:"host" validate:"required,hostname"`
+	Port int    `json:"port" validate:"min=1,max=65535"`
+}
+
+type record struct {
+	Name    string            `json:"name" validate:"required,max=5"`
+	Rating  float64           `json:"rating" validate:"min=0,max=5"`
+	Version string            `json:"version" validate:"semver"`
+	URL     string            `json:"url" validate:"url"`
+	Mode    string            `json:"mode,omitempty" validate:"oneof=http tcp"`
+	Tags    []string          `json:"tags,omitempty" validate:"max=2"`
+	Labels  map[string]string `json:"labels,omitempty" validate:"max=2,labels"`
+	Sector  string            `json:"sector" validate:"industry"`
+	Primary address           `json:"primary"`
+	Backup  *address          `json:"backup,omitempty"`
+	Note    string            // no rules
+}
+
+func TestStruct(t *testing.T) {
+	valid := record{
+		Name: "ok", Rating: 4.5, Version: "1.2.3", URL: "https://example.com", Sector: "naics:5415",
+		Primary: address{Host: "10.0.0.1", Port: 80},
+	}
+	assert.NoError(t, Struct(valid))
+	assert.NoError(t, Struct(&valid), "pointers are dereferenced")
+
+	err := Struct(
+		record{
+			Name: "too long", Rating: 9, Version: "1.2", URL: "example.com", Mode: "udp",
+			Tags:    []string{"a", "b", "c"},
+			Labels:  map[string]string{"env": "not valid"},
+			Sector:  "Finance",
+			Primary: address{Host: "-bad-", Port: 0},
+			Backup:  &address{Port: 70000},
+		},
+	)
+
+	var errs Errors
+	if !assert.ErrorAs(t, err, &errs) {
+		return
+	}
+	rules := make(map[string]string)
+	for _, fe := range errs {
+		rules[fe.Field] = fe.Rule
+	}
+	assert.Equal(
+		t, map[string]string{
+			"name": "max", "rating": "max", "version": "semver", "url": "url", "mode": "oneof",
+			"tags": "max", "labels": "labels", "sector": "industry",
+			"primary.host": "hostname", "primary.port": "min",
+			"backup.host": "required", "backup.port": "max",
+		}, rules,
+	)
+	assert.Contains(t, err.Error(), "name must be at most 5 characters long")
+	assert.Contains(t, err.Error(), "tags must have at most 2 entries")
+	assert.Contains(t, err.Error(), `labels has an invalid value "not valid" of label "env"`)
+}
+
+func TestIsHostname(t *testing.T) {
+	for _, host := range []string{"localhost", "api.example.com", "api.example.com.", "::1", "192.168.0.1", "a-b"} {
+		assert.True(t, isHostname(host), host)
+	}
+	for _, host := range []string{"bad host", "-a", "a-", "a..b", "under_score", "http://x"} {
+		assert.False(t, isHostname(host), host)
+	}
+}
+
+func TestStructPanicsOnMalformedRules(t *testing.T) {
+	assert.Panics(t, func() {
+		_ = Struct(struct {
+			N int `validate:"semver"`
+		}{})
+	})
+	assert.Panics(t, func() {
+		_ = Struct(struct {
+			S string `validate:"max=many"`
+		}{})
+	})
+}